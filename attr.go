@@ -1,5 +1,15 @@
 package dot
 
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
 // HTML renders the provided content as graphviz HTML. Use of this
 // type is only valid for some attributes, like the 'label' attribute.
 type HTML string
@@ -18,6 +28,32 @@ type Literal string
 // AttributesMap holds attribute=value pairs.
 type AttributesMap struct {
 	attributes map[string]interface{}
+	// mu is shared with the graph if it was created with the Concurrent option
+	mu *sync.RWMutex
+}
+
+func (a AttributesMap) lock() {
+	if a.mu != nil {
+		a.mu.Lock()
+	}
+}
+
+func (a AttributesMap) unlock() {
+	if a.mu != nil {
+		a.mu.Unlock()
+	}
+}
+
+func (a AttributesMap) rlock() {
+	if a.mu != nil {
+		a.mu.RLock()
+	}
+}
+
+func (a AttributesMap) runlock() {
+	if a.mu != nil {
+		a.mu.RUnlock()
+	}
 }
 
 // Attrs sets multiple values for attributes (unless empty) taking a label,value list
@@ -38,6 +74,8 @@ func (a AttributesMap) Attr(label string, value interface{}) {
 	if len(label) == 0 || value == nil {
 		return
 	}
+	a.lock()
+	defer a.unlock()
 	if s, ok := value.(string); ok {
 		if len(s) > 0 {
 			a.attributes[label] = s
@@ -49,10 +87,76 @@ func (a AttributesMap) Attr(label string, value interface{}) {
 
 // Value return the value added for this label.
 func (a AttributesMap) Value(label string) interface{} {
+	a.rlock()
+	defer a.runlock()
 	return a.attributes[label]
 }
 
 // Delete removes the attribute value at key, if any
 func (a AttributesMap) Delete(key string) {
+	a.lock()
+	defer a.unlock()
 	delete(a.attributes, key)
 }
+
+// floatValue returns the value as a float64 if it is a number or a string with a number.
+func floatValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case nil:
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.Trim(fmt.Sprintf("%v", value), `"`), 64)
+	return f, err == nil
+}
+
+// positionValue returns the x and y of a pos attribute like "1,2" or "1,2!".
+func positionValue(value interface{}) (float64, float64, bool) {
+	if value == nil {
+		return 0, 0, false
+	}
+	fields := strings.Split(strings.TrimSuffix(strings.Trim(fmt.Sprintf("%v", value), `"`), "!"), ",")
+	if len(fields) < 2 {
+		return 0, 0, false
+	}
+	x, xerr := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+	y, yerr := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	return x, y, xerr == nil && yerr == nil
+}
+
+// sortedNames returns the keys of a map in ascending order.
+func sortedNames(attrs map[string]interface{}) []string {
+	list := []string{}
+	for each := range attrs {
+		list = append(list, each)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// styleValues returns the comma separated values of a style attribute.
+func styleValues(value interface{}) []string {
+	if value == nil {
+		return nil
+	}
+	list := []string{}
+	for _, each := range strings.Split(fmt.Sprintf("%v", value), ",") {
+		list = append(list, strings.ToLower(strings.TrimSpace(each)))
+	}
+	return list
+}
+
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// htmlText returns the text of an HTML label ; line breaks become newlines and the other tags are removed.
+func htmlText(s string) string {
+	s = strings.NewReplacer("<br/>", "\n", "<br>", "\n", "<BR/>", "\n", "<BR>", "\n").Replace(s)
+	return html.UnescapeString(htmlTagPattern.ReplaceAllString(s, ""))
+}
@@ -0,0 +1,143 @@
+package dot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// x11Colors has the rgb values of commonly used color names of the Graphviz X11 color scheme.
+var x11Colors = map[string][3]uint8{
+	"black":       {0, 0, 0},
+	"white":       {255, 255, 255},
+	"red":         {255, 0, 0},
+	"green":       {0, 255, 0},
+	"blue":        {0, 0, 255},
+	"yellow":      {255, 255, 0},
+	"cyan":        {0, 255, 255},
+	"magenta":     {255, 0, 255},
+	"gray":        {190, 190, 190},
+	"grey":        {190, 190, 190},
+	"lightgray":   {211, 211, 211},
+	"lightgrey":   {211, 211, 211},
+	"darkgray":    {169, 169, 169},
+	"darkgrey":    {169, 169, 169},
+	"orange":      {255, 165, 0},
+	"purple":      {160, 32, 240},
+	"pink":        {255, 192, 203},
+	"brown":       {165, 42, 42},
+	"navy":        {0, 0, 128},
+	"gold":        {255, 215, 0},
+	"maroon":      {176, 48, 96},
+	"violet":      {238, 130, 238},
+	"orchid":      {218, 112, 214},
+	"salmon":      {250, 128, 114},
+	"tomato":      {255, 99, 71},
+	"turquoise":   {64, 224, 208},
+	"beige":       {245, 245, 220},
+	"khaki":       {240, 230, 140},
+	"lavender":    {230, 230, 250},
+	"lightblue":   {173, 216, 230},
+	"lightyellow": {255, 255, 224},
+	"lightgreen":  {144, 238, 144},
+	"lightpink":   {255, 182, 193},
+	"darkblue":    {0, 0, 139},
+	"darkgreen":   {0, 100, 0},
+	"darkred":     {139, 0, 0},
+	"steelblue":   {70, 130, 180},
+	"skyblue":     {135, 206, 235},
+	"forestgreen": {34, 139, 34},
+	"crimson":     {220, 20, 60},
+	"transparent": {255, 255, 254},
+}
+
+// colorRGBA returns the red, green and blue components and the alpha (0..1) of a Graphviz color value.
+// It understands #rrggbb, #rrggbbaa, "H,S,V" and the names in x11Colors ; the first color of a color list is taken.
+func colorRGBA(value interface{}) (r, g, b uint8, a float64, ok bool) {
+	if value == nil {
+		return 0, 0, 0, 0, false
+	}
+	s := strings.ToLower(strings.Trim(fmt.Sprintf("%v", value), `"`))
+	// a color list takes the first color
+	if i := strings.IndexAny(s, ":;"); i != -1 {
+		s = s[:i]
+	}
+	// a color scheme like /x11/red
+	if i := strings.LastIndex(s, "/"); i != -1 {
+		s = s[i+1:]
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "#") && (len(s) == 7 || len(s) == 9) {
+		v, err := strconv.ParseUint(s[1:], 16, 32)
+		if err != nil {
+			return 0, 0, 0, 0, false
+		}
+		if len(s) == 9 {
+			return uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), float64(uint8(v)) / 255, true
+		}
+		return uint8(v >> 16), uint8(v >> 8), uint8(v), 1, true
+	}
+	if fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }); len(fields) == 3 {
+		hsv := []float64{}
+		for _, each := range fields {
+			f, err := strconv.ParseFloat(each, 64)
+			if err != nil {
+				return 0, 0, 0, 0, false
+			}
+			hsv = append(hsv, f)
+		}
+		r, g, b := hsvToRGB(hsv[0], hsv[1], hsv[2])
+		return r, g, b, 1, true
+	}
+	if rgb, found := x11Colors[s]; found {
+		if s == "transparent" {
+			return rgb[0], rgb[1], rgb[2], 0, true
+		}
+		return rgb[0], rgb[1], rgb[2], 1, true
+	}
+	return 0, 0, 0, 0, false
+}
+
+// hsvToRGB converts a hue, saturation, value color (each 0..1) to red, green and blue.
+func hsvToRGB(h, s, v float64) (uint8, uint8, uint8) {
+	h = math.Mod(h, 1) * 6
+	i := math.Floor(h)
+	f := h - i
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch int(i) {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	return uint8(r*255 + 0.5), uint8(g*255 + 0.5), uint8(b*255 + 0.5)
+}
+
+// cssColor returns a CSS color for the first color of a color list.
+// Known names are converted to #rrggbb because some X11 colors differ from the CSS colors with that name.
+func cssColor(value interface{}) string {
+	s := strings.Trim(fmt.Sprintf("%v", value), `"`)
+	if i := strings.IndexAny(s, ":;"); i != -1 {
+		s = s[:i]
+	}
+	if i := strings.LastIndex(s, "/"); i != -1 {
+		s = s[i+1:]
+	}
+	if r, g, b, a, ok := colorRGBA(s); ok && !(strings.HasPrefix(s, "#") && len(s) == 7) {
+		if a < 1 {
+			return fmt.Sprintf("rgba(%d,%d,%d,%g)", r, g, b, a)
+		}
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	}
+	return s
+}
@@ -0,0 +1,299 @@
+package dot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CytoscapeElement is a node or edge in the elements JSON of Cytoscape.js.
+type CytoscapeElement struct {
+	// Group is "nodes" or "edges".
+	Group string                 `json:"group"`
+	Data  map[string]interface{} `json:"data"`
+	// Position is set for nodes with a pos attribute.
+	Position *CytoscapePosition     `json:"position,omitempty"`
+	Style    map[string]interface{} `json:"style,omitempty"`
+}
+
+// CytoscapePosition is the model position of a node.
+type CytoscapePosition struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// CytoscapeElements returns the elements of the graph for Cytoscape.js, parents before children.
+//
+// Subgraphs become compound (parent) nodes with the id of the subgraph (e.g. "cluster_s1").
+// Nodes have the id as written in dot notation (see NodeIDOption) and edges have the id "e<seq>".
+// All attributes, including node and edge defaults, are copied into data ; edges also have sourcePort and targetPort in data if set.
+// The label, color, fillcolor, fontcolor, shape, style, penwidth, width, height, dir, arrowhead and arrowtail
+// attributes are mapped to style properties. The pos attribute (in points) is mapped to position ;
+// its y is negated because the y axis of Cytoscape grows downwards.
+func CytoscapeElements(g *Graph) []CytoscapeElement {
+	g.rlock()
+	defer g.runlock()
+	list := []CytoscapeElement{}
+	g.cytoscapeNodes(&list, "")
+	var edges []Edge
+	g.collectEdges(&edges)
+	for _, each := range edges {
+		list = append(list, cytoscapeEdge(g, each))
+	}
+	return list
+}
+
+// CytoscapeJSON returns the elements of the graph as JSON ; see CytoscapeElements.
+func CytoscapeJSON(g *Graph) string {
+	b := new(bytes.Buffer)
+	WriteCytoscapeJSON(b, g)
+	return b.String()
+}
+
+// WriteCytoscapeJSON writes the elements of the graph as JSON to w, one element at a time.
+// It returns the number of bytes written and the first error of w, if any.
+func WriteCytoscapeJSON(w io.Writer, g *Graph) (int64, error) {
+	out := NewIndentWriter(w)
+	list := CytoscapeElements(g)
+	if len(list) == 0 {
+		out.WriteString("[]")
+		return out.Written(), out.Err()
+	}
+	out.WriteString("[\n  ")
+	for i, each := range list {
+		if i > 0 {
+			out.WriteString(",\n  ")
+		}
+		data, err := json.MarshalIndent(each, "  ", "  ")
+		if err != nil {
+			return out.Written(), err
+		}
+		out.Write(data)
+	}
+	out.WriteString("\n]")
+	return out.Written(), out.Err()
+}
+
+// cytoscapeNodes adds the compound nodes of the subgraphs and the nodes of the graph.
+func (g *Graph) cytoscapeNodes(list *[]CytoscapeElement, parent string) {
+	for _, key := range g.orderedNodesKeys() {
+		each := g.nodes[key]
+		id := fmt.Sprintf("n%d", each.seq)
+		if g.Root().useNodeIDs {
+			id = each.id
+		}
+		attributes := g.effectiveAttributes(each.attributes, true)
+		elem := CytoscapeElement{Group: "nodes", Data: cytoscapeData(attributes), Style: map[string]interface{}{}}
+		elem.Data["id"] = id
+		if parent != "" {
+			elem.Data["parent"] = parent
+		}
+		if x, y, ok := positionValue(attributes["pos"]); ok {
+			elem.Position = &CytoscapePosition{X: x, Y: -y}
+		}
+		cytoscapeNodeStyle(elem.Style, attributes)
+		*list = append(*list, cytoscapeCompact(elem))
+	}
+	for _, key := range g.orderedSubgraphsKeys() {
+		sub := g.subgraphs[key]
+		elem := CytoscapeElement{Group: "nodes", Data: cytoscapeData(sub.attributes), Style: map[string]interface{}{}}
+		elem.Data["id"] = sub.id
+		if parent != "" {
+			elem.Data["parent"] = parent
+		}
+		cytoscapeNodeStyle(elem.Style, sub.attributes)
+		if v, ok := sub.attributes["bgcolor"]; ok {
+			elem.Style["background-color"] = cssColor(v)
+		}
+		*list = append(*list, cytoscapeCompact(elem))
+		sub.cytoscapeNodes(list, sub.id)
+	}
+}
+
+func cytoscapeEdge(g *Graph, e Edge) CytoscapeElement {
+	attributes := e.graph.effectiveAttributes(e.attributes, false)
+	elem := CytoscapeElement{Group: "edges", Data: cytoscapeData(attributes), Style: map[string]interface{}{}}
+	elem.Data["id"] = fmt.Sprintf("e%d", e.seq)
+	elem.Data["source"] = fmt.Sprintf("n%d", e.from.seq)
+	elem.Data["target"] = fmt.Sprintf("n%d", e.to.seq)
+	if g.Root().useNodeIDs {
+		elem.Data["source"], elem.Data["target"] = e.from.id, e.to.id
+	}
+	if e.fromPort != "" {
+		elem.Data["sourcePort"] = e.fromPort
+	}
+	if e.toPort != "" {
+		elem.Data["targetPort"] = e.toPort
+	}
+	style := elem.Style
+	// arrows are only drawn on bezier edges
+	style["curve-style"] = "bezier"
+	if v, ok := attributes["label"]; ok {
+		style["label"] = cytoscapeLabel(v)
+	}
+	if v, ok := attributes["color"]; ok {
+		color := cssColor(v)
+		style["line-color"], style["target-arrow-color"], style["source-arrow-color"] = color, color, color
+	}
+	if v, ok := attributes["fontcolor"]; ok {
+		style["color"] = cssColor(v)
+	}
+	if f, ok := floatValue(attributes["penwidth"]); ok {
+		style["width"] = f
+	}
+	for _, each := range styleValues(attributes["style"]) {
+		switch each {
+		case "dashed", "dotted", "solid":
+			style["line-style"] = each
+		case "bold":
+			style["width"] = 2
+		case "invis":
+			style["visibility"] = "hidden"
+		}
+	}
+	dir := "forward"
+	if !g.IsDirected() {
+		dir = "none"
+	}
+	if v, ok := attributes["dir"]; ok {
+		dir = fmt.Sprintf("%v", v)
+	}
+	if dir == "forward" || dir == "both" {
+		style["target-arrow-shape"] = cytoscapeArrow(attributes["arrowhead"])
+	}
+	if dir == "back" || dir == "both" {
+		style["source-arrow-shape"] = cytoscapeArrow(attributes["arrowtail"])
+	}
+	return cytoscapeCompact(elem)
+}
+
+// cytoscapeCompact removes an empty style.
+func cytoscapeCompact(elem CytoscapeElement) CytoscapeElement {
+	if len(elem.Style) == 0 {
+		elem.Style = nil
+	}
+	return elem
+}
+
+// cytoscapeData copies the attributes ; HTML and Literal values become strings.
+func cytoscapeData(attributes map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{}
+	for k, v := range attributes {
+		switch t := v.(type) {
+		case string, bool, int, int64, float32, float64:
+			data[k] = v
+		default:
+			data[k] = fmt.Sprintf("%v", t)
+		}
+	}
+	return data
+}
+
+func cytoscapeNodeStyle(style map[string]interface{}, attributes map[string]interface{}) {
+	if v, ok := attributes["label"]; ok {
+		style["label"] = cytoscapeLabel(v)
+	}
+	if v, ok := attributes["color"]; ok {
+		style["border-color"] = cssColor(v)
+	}
+	if v, ok := attributes["fillcolor"]; ok {
+		style["background-color"] = cssColor(v)
+	}
+	if v, ok := attributes["fontcolor"]; ok {
+		style["color"] = cssColor(v)
+	}
+	if f, ok := floatValue(attributes["penwidth"]); ok {
+		style["border-width"] = f
+	}
+	if f, ok := floatValue(attributes["width"]); ok {
+		style["width"] = f * 72
+	}
+	if f, ok := floatValue(attributes["height"]); ok {
+		style["height"] = f * 72
+	}
+	if v, ok := attributes["shape"]; ok {
+		if shape, ok := cytoscapeShapes[strings.ToLower(fmt.Sprintf("%v", v))]; ok {
+			style["shape"] = shape
+		}
+	}
+	for _, each := range styleValues(attributes["style"]) {
+		switch each {
+		case "dashed", "dotted", "solid":
+			style["border-style"] = each
+		case "bold":
+			style["border-width"] = 2
+		case "rounded":
+			style["shape"] = "round-rectangle"
+		case "filled":
+			// Graphviz fills with the color if there is no fillcolor
+			if _, ok := attributes["fillcolor"]; !ok {
+				if v, ok := attributes["color"]; ok {
+					style["background-color"] = cssColor(v)
+				}
+			}
+		case "invis":
+			style["visibility"] = "hidden"
+		}
+	}
+}
+
+// cytoscapeShapes maps Graphviz node shapes to Cytoscape node shapes.
+var cytoscapeShapes = map[string]string{
+	"box":           "rectangle",
+	"rect":          "rectangle",
+	"rectangle":     "rectangle",
+	"square":        "rectangle",
+	"plaintext":     "rectangle",
+	"plain":         "rectangle",
+	"none":          "rectangle",
+	"record":        "rectangle",
+	"mrecord":       "round-rectangle",
+	"ellipse":       "ellipse",
+	"oval":          "ellipse",
+	"circle":        "ellipse",
+	"doublecircle":  "ellipse",
+	"point":         "ellipse",
+	"diamond":       "diamond",
+	"mdiamond":      "diamond",
+	"triangle":      "triangle",
+	"pentagon":      "pentagon",
+	"hexagon":       "hexagon",
+	"octagon":       "octagon",
+	"parallelogram": "rhomboid",
+	"cylinder":      "barrel",
+	"star":          "star",
+	"invtriangle":   "vee",
+}
+
+// cytoscapeArrow maps a Graphviz arrow type to a Cytoscape arrow shape.
+func cytoscapeArrow(value interface{}) string {
+	if value == nil {
+		return "triangle"
+	}
+	s := strings.ToLower(fmt.Sprintf("%v", value))
+	switch {
+	case s == "none":
+		return "none"
+	case strings.HasSuffix(s, "dot"):
+		return "circle"
+	case strings.HasSuffix(s, "diamond"):
+		return "diamond"
+	case strings.HasSuffix(s, "box"):
+		return "square"
+	case strings.HasSuffix(s, "tee"):
+		return "tee"
+	case strings.HasSuffix(s, "vee"):
+		return "vee"
+	case strings.HasSuffix(s, "inv"):
+		return "triangle-backcurve"
+	}
+	return "triangle"
+}
+
+// cytoscapeLabel returns the label text ; escaped newlines become real ones.
+func cytoscapeLabel(value interface{}) string {
+	s := fmt.Sprintf("%v", value)
+	return strings.NewReplacer(`\n`, "\n", `\l`, "\n", `\r`, "\n").Replace(s)
+}
@@ -0,0 +1,111 @@
+package dot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCytoscapeElements(t *testing.T) {
+	g := NewGraph(Directed)
+	g.NodeDefaults().Attr("shape", "box")
+	c := g.Subgraph("api", ClusterOption{})
+	c.Attrs("color", "blue", "style", "dashed")
+	a := c.Node("a").Attr("fillcolor", "lightgrey").Attr("style", "filled,bold").Attr("pos", "10,20!")
+	b := g.Node("b").Attr("shape", "diamond").Attr("color", "#ff000080")
+	g.EdgeWithPorts(a, b, "p1", "p2").Label("calls").Attr("style", "dotted").Attr("dir", "both").Attr("arrowtail", "odot")
+	list := CytoscapeElements(g)
+	if got, want := len(list), 4; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	node := list[0]
+	if got, want := node.Data["id"], "n3"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := node.Style["shape"], "diamond"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	cluster := list[1]
+	if got, want := cluster.Data["id"], "cluster_s1"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := cluster.Style["border-style"], "dashed"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := cluster.Style["border-color"], "#0000ff"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	member := list[2]
+	if got, want := member.Data["parent"], "cluster_s1"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// the shape is a node default
+	if got, want := member.Style["shape"], "rectangle"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := member.Style["background-color"], "#d3d3d3"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := member.Style["border-width"], 2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := *member.Position, (CytoscapePosition{X: 10, Y: -20}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := node.Style["border-color"], "rgba(255,0,0,0.5019607843137255)"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	edge := list[3]
+	for k, v := range map[string]interface{}{"id": "e1", "source": "n2", "target": "n3", "sourcePort": "p1", "targetPort": "p2", "label": "calls"} {
+		if got, want := edge.Data[k], v; got != want {
+			t.Errorf("%s: got [%v] want [%v]", k, got, want)
+		}
+	}
+	for k, v := range map[string]interface{}{"label": "calls", "line-style": "dotted", "target-arrow-shape": "triangle", "source-arrow-shape": "circle", "curve-style": "bezier"} {
+		if got, want := edge.Style[k], v; got != want {
+			t.Errorf("%s: got [%v] want [%v]", k, got, want)
+		}
+	}
+}
+
+func TestCytoscapeJSON(t *testing.T) {
+	g := NewGraph(Undirected, NodeIDOption{})
+	g.Node("a").Edge(g.Node("b"))
+	out := CytoscapeJSON(g)
+	var list []CytoscapeElement
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list[2].Data["source"], "a"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if _, ok := list[2].Style["target-arrow-shape"]; ok {
+		t.Errorf("undirected edge must have no arrow in %s", out)
+	}
+	buf := new(bytes.Buffer)
+	n, err := WriteCytoscapeJSON(buf, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(len(out)); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	data, _ := json.MarshalIndent(CytoscapeElements(g), "", "  ")
+	if got, want := out, string(data); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := CytoscapeJSON(NewGraph()), "[]"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	n, err = WriteCytoscapeJSON(&failingWriter{limit: 10}, g)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if got, want := n, int64(10); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if !strings.Contains(out, `"group": "nodes"`) {
+		t.Errorf("missing group in %s", out)
+	}
+}
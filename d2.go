@@ -0,0 +1,239 @@
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// D2 returns the graph in the notation of D2 (https://d2lang.com).
+//
+// Subgraphs become containers with the id of the subgraph (e.g. "cluster_s1") as key and nodes have the key
+// as written in dot notation (see NodeIDOption) ; keys that are not plain identifiers are quoted.
+// Node shapes are mapped to D2 shapes, e.g. ellipse becomes oval and cylinder stays cylinder ; nodes without shape
+// get the default shape of D2 (rectangle). Edges are written as ->, <-, <-> (dir=both) or -- (undirected or dir=none).
+// The fillcolor, color, fontcolor, penwidth and style (filled, dashed, dotted, bold, invis) attributes
+// become fill, stroke, font-color, stroke-width, stroke-dash and opacity styles.
+func D2(g *Graph) string {
+	b := new(bytes.Buffer)
+	WriteD2(b, g)
+	return b.String()
+}
+
+// WriteD2 writes the graph in D2 notation to w and returns the number of bytes written.
+// Nothing is written after the first error of w, which is returned.
+func WriteD2(w io.Writer, g *Graph) (int64, error) {
+	g.rlock()
+	defer g.runlock()
+	out := NewIndentWriter(w)
+	switch strings.ToUpper(fmt.Sprintf("%v", g.attributes["rankdir"])) {
+	case "LR":
+		out.WriteString("direction: right\n")
+	case "RL":
+		out.WriteString("direction: left\n")
+	case "BT":
+		out.WriteString("direction: up\n")
+	}
+	if label, ok := g.attributes["label"]; ok {
+		fmt.Fprintf(out, "title: %s {\n  shape: text\n  near: top-center\n}\n", d2String(label))
+	}
+	paths := map[int]string{}
+	d2Graph(g, out, "", "", paths)
+	var edges []Edge
+	g.collectEdges(&edges)
+	for _, each := range edges {
+		d2Edge(g, each, out, paths)
+	}
+	return out.Written(), out.Err()
+}
+
+// d2Shapes maps Graphviz node shapes to D2 shapes.
+var d2Shapes = map[string]string{
+	"box":           "rectangle",
+	"rect":          "rectangle",
+	"rectangle":     "rectangle",
+	"square":        "square",
+	"ellipse":       "oval",
+	"oval":          "oval",
+	"circle":        "circle",
+	"doublecircle":  "circle",
+	"point":         "circle",
+	"diamond":       "diamond",
+	"cylinder":      "cylinder",
+	"hexagon":       "hexagon",
+	"parallelogram": "parallelogram",
+	"note":          "page",
+	"folder":        "package",
+	"tab":           "package",
+	"plaintext":     "text",
+	"plain":         "text",
+	"none":          "text",
+	"cds":           "step",
+}
+
+// d2Graph writes the nodes and containers of the graph ; paths is filled with the path of each node by seq.
+func d2Graph(g *Graph, out *IndentWriter, indent, prefix string, paths map[int]string) {
+	for _, key := range g.orderedNodesKeys() {
+		each := g.nodes[key]
+		attributes := g.effectiveAttributes(each.attributes, true)
+		id := d2NodeKey(g, each)
+		paths[each.seq] = prefix + id
+		fmt.Fprintf(out, "%s%s", indent, id)
+		if label, ok := attributes["label"]; ok {
+			fmt.Fprintf(out, ": %s", d2String(label))
+		}
+		props := []string{}
+		if s, ok := attributes["shape"]; ok {
+			name := strings.ToLower(fmt.Sprintf("%v", s))
+			if shape, ok := d2Shapes[name]; ok {
+				props = append(props, "shape: "+shape)
+			}
+			if name == "box3d" {
+				props = append(props, "style.3d: true")
+			}
+		}
+		props = append(props, d2Styles(attributes, true)...)
+		d2Block(out, indent, props, nil)
+	}
+	for _, key := range g.orderedSubgraphsKeys() {
+		each := g.subgraphs[key]
+		fmt.Fprintf(out, "%s%s", indent, each.id)
+		if label, ok := each.attributes["label"]; ok {
+			fmt.Fprintf(out, ": %s", d2String(label))
+		}
+		props := d2Styles(each.attributes, true)
+		if v, ok := each.attributes["bgcolor"]; ok {
+			props = append(props, fmt.Sprintf("style.fill: %q", cssColor(v)))
+		}
+		d2Block(out, indent, props, func() {
+			d2Graph(each, out, indent+"  ", prefix+each.id+".", paths)
+		})
+	}
+}
+
+// d2Block writes the properties and the content within braces, if any.
+func d2Block(out *IndentWriter, indent string, props []string, content func()) {
+	if len(props) == 0 && content == nil {
+		out.WriteString("\n")
+		return
+	}
+	out.WriteString(" {\n")
+	for _, each := range props {
+		fmt.Fprintf(out, "%s  %s\n", indent, each)
+	}
+	if content != nil {
+		content()
+	}
+	fmt.Fprintf(out, "%s}\n", indent)
+}
+
+func d2Edge(g *Graph, e Edge, out *IndentWriter, paths map[int]string) {
+	attributes := e.graph.effectiveAttributes(e.attributes, false)
+	dir := "forward"
+	if !g.IsDirected() {
+		dir = "none"
+	}
+	if v, ok := attributes["dir"]; ok {
+		dir = fmt.Sprintf("%v", v)
+	}
+	arrow := "->"
+	switch dir {
+	case "both":
+		arrow = "<->"
+	case "back":
+		arrow = "<-"
+	case "none":
+		arrow = "--"
+	}
+	fmt.Fprintf(out, "%s %s %s", paths[e.from.seq], arrow, paths[e.to.seq])
+	if label, ok := attributes["label"]; ok {
+		fmt.Fprintf(out, ": %s", d2String(label))
+	}
+	d2Block(out, "", d2Styles(attributes, false), nil)
+}
+
+// d2Styles returns the style properties of a node, container or edge.
+func d2Styles(attributes map[string]interface{}, filled bool) []string {
+	props := []string{}
+	if v, ok := attributes["fillcolor"]; ok && filled {
+		props = append(props, fmt.Sprintf("style.fill: %q", cssColor(v)))
+	}
+	if v, ok := attributes["color"]; ok {
+		props = append(props, fmt.Sprintf("style.stroke: %q", cssColor(v)))
+	}
+	if v, ok := attributes["fontcolor"]; ok {
+		props = append(props, fmt.Sprintf("style.font-color: %q", cssColor(v)))
+	}
+	if f, ok := floatValue(attributes["penwidth"]); ok {
+		props = append(props, fmt.Sprintf("style.stroke-width: %g", f))
+	}
+	for _, each := range styleValues(attributes["style"]) {
+		switch each {
+		case "filled":
+			// Graphviz fills with the color if there is no fillcolor
+			if _, ok := attributes["fillcolor"]; !ok && filled {
+				if v, ok := attributes["color"]; ok {
+					props = append(props, fmt.Sprintf("style.fill: %q", cssColor(v)))
+				}
+			}
+		case "dashed":
+			props = append(props, "style.stroke-dash: 5")
+		case "dotted":
+			props = append(props, "style.stroke-dash: 1")
+		case "bold":
+			props = append(props, "style.stroke-width: 3")
+		case "rounded":
+			props = append(props, "style.border-radius: 8")
+		case "invis":
+			props = append(props, "style.opacity: 0")
+		}
+	}
+	return props
+}
+
+var d2IDPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// d2NodeKey returns the key of the node ; n<seq> unless the root graph has the NodeIDOption.
+func d2NodeKey(g *Graph, n Node) string {
+	if !g.Root().useNodeIDs {
+		return fmt.Sprintf("n%d", n.seq)
+	}
+	return d2Key(n.id)
+}
+
+// d2Key returns the key as is if it is a plain identifier and not a reserved keyword, double-quoted otherwise.
+func d2Key(key string) string {
+	if d2IDPattern.MatchString(key) && !isD2Keyword(key) {
+		return key
+	}
+	return d2Quote(key)
+}
+
+func isD2Keyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "label", "shape", "style", "direction", "icon", "near", "width", "height", "tooltip", "link",
+		"constraint", "class", "classes", "vars", "layers", "scenarios", "steps", "source-arrowhead",
+		"target-arrowhead", "top", "left", "grid-rows", "grid-columns", "grid-gap", "null", "_":
+		return true
+	}
+	return false
+}
+
+// d2Quote returns the string double-quoted with backslash escapes.
+func d2Quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// d2String returns a label as double-quoted string ; HTML tags are removed and escaped line breaks become \n.
+func d2String(label interface{}) string {
+	s := fmt.Sprintf("%v", label)
+	switch label.(type) {
+	case HTML:
+		return d2Quote(htmlText(s))
+	case Literal:
+		s = strings.Replace(strings.Trim(s, `"`), `\"`, `"`, -1)
+	}
+	return d2Quote(strings.NewReplacer(`\n`, "\n", `\l`, "\n", `\r`, "\n").Replace(s))
+}
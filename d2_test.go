@@ -0,0 +1,81 @@
+package dot
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestD2(t *testing.T) {
+	g := NewGraph(Directed)
+	g.Attr("rankdir", "LR")
+	c := g.Subgraph("backend", ClusterOption{})
+	c.Attrs("color", "blue", "style", "dashed")
+	inner := c.Subgraph("storage")
+	db := inner.Node("db").Attr("shape", "cylinder").Attr("fillcolor", "lightgrey")
+	api := c.Node("api").Attr("shape", "box").Attr("style", "filled").Attr("color", "#ff0000")
+	web := g.Node("web").Label(`web "ui"\nfrontend`)
+	web.Edge(api, "calls").Attr("style", "dotted")
+	api.Edge(db).Attr("dir", "both").Attr("color", "red").Attr("penwidth", 2)
+	db.Edge(web).Attr("dir", "none")
+	if got, want := D2(g), `direction: right
+n5: "web \"ui\"\nfrontend"
+cluster_s1: "backend" {
+  style.stroke: "#0000ff"
+  style.stroke-dash: 5
+  n4: "api" {
+    shape: rectangle
+    style.stroke: "#ff0000"
+    style.fill: "#ff0000"
+  }
+  s2: "storage" {
+    n3: "db" {
+      shape: cylinder
+      style.fill: "#d3d3d3"
+    }
+  }
+}
+cluster_s1.s2.n3 -- n5
+n5 -> cluster_s1.n4: "calls" {
+  style.stroke-dash: 1
+}
+cluster_s1.n4 <-> cluster_s1.s2.n3 {
+  style.stroke: "#ff0000"
+  style.stroke-width: 2
+}
+`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestD2NodeIDs(t *testing.T) {
+	g := NewGraph(Undirected, NodeIDOption{})
+	g.Label("Overview")
+	a := g.Node("a.b").Attr("label", HTML("<b>A</b>"))
+	b := g.Node("label")
+	c := g.Node("c_1")
+	a.Edge(b)
+	b.Edge(c)
+	buf := new(bytes.Buffer)
+	if _, err := WriteD2(buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), `title: "Overview" {
+  shape: text
+  near: top-center
+}
+"a.b": "A"
+c_1: "c_1"
+"label": "label"
+"a.b" -- "label"
+"label" -- c_1
+`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	n, err := WriteD2(&failingWriter{limit: 10}, g)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if got, want := n, int64(10); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -138,6 +138,15 @@ func TestAttrOnSubsystem(t *testing.T) {
 	}
 }
 
+func TestMermaidClickOnExportName(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	s := NewComposite("my subsystem", g, ExternalGraph)
+	s.ExportName("my subsystem")
+	if got, want := dot.MermaidFlowchart(g, dot.MermaidTopDown), `click n1 href "my_subsystem.svg"`; !strings.Contains(got, want) {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
 func TestWarninOnExport(t *testing.T) {
 	s := NewComposite("/////fail", dot.NewGraph(), SameGraph)
 	s.Export(func(g *dot.Graph) {})
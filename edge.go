@@ -6,6 +6,8 @@ type Edge struct {
 	graph            *Graph
 	from, to         Node
 	fromPort, toPort string
+	// seq is the creation sequence, used for InsertionOrder
+	seq int
 }
 
 // Attr sets key=value and returns the Edge.
@@ -59,7 +61,22 @@ func (e Edge) EdgesTo(to Node) []Edge {
 
 // GetAttr returns the value stored by a name. Returns nil if missing.
 func (e Edge) GetAttr(name string) interface{} {
-	return e.attributes[name]
+	return e.AttributesMap.Value(name)
+}
+
+// EffectiveValue returns the value of the attribute of the edge. If absent then the default value
+// is taken from the EdgeDefaults of its graph or one of its parents. Returns nil if not set.
+func (e Edge) EffectiveValue(name string) interface{} {
+	e.rlock()
+	defer e.runlock()
+	if v, ok := e.attributes[name]; ok {
+		return v
+	}
+	if e.graph == nil {
+		return nil
+	}
+	v, _ := e.graph.inheritedEdgeDefault(name)
+	return v
 }
 
 // From returns the Node that this edge is pointing from.
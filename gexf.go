@@ -0,0 +1,388 @@
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GEXFSnapshot is the state of a graph at a point in time, used to write a dynamic GEXF file.
+type GEXFSnapshot struct {
+	// Time is the start of the period for this snapshot ; the period ends at the Time of the next snapshot.
+	Time  time.Time
+	Graph *Graph
+}
+
+// GEXF returns the graph in GEXF 1.3 notation, e.g. for Gephi.
+// Nodes of all subgraphs are written ; nodes are identified by their id.
+// The attributes are written as GEXF attributes, except label which becomes the label of the node or edge.
+// Visual attributes are mapped too:
+//
+//	color, fillcolor (node) -> viz:color
+//	width, height (node, in inches) -> viz:size (in points)
+//	shape (node) -> viz:shape (disc, square, triangle, diamond)
+//	pos (node, in points) -> viz:position
+//	penwidth (edge) -> viz:thickness
+//	style (edge, dashed or dotted) -> viz:shape
+//	weight (edge) -> weight
+func GEXF(g *Graph) string {
+	b := new(bytes.Buffer)
+	WriteGEXF(b, g)
+	return b.String()
+}
+
+// WriteGEXF writes the graph in GEXF notation to w, element by element.
+// It returns the number of bytes written and stops at the first error of w.
+func WriteGEXF(w io.Writer, g *Graph) (int64, error) {
+	out := NewIndentWriter(w)
+	newGEXFWriter([]GEXFSnapshot{{Graph: g}}, false).write(out)
+	return out.Written(), out.Err()
+}
+
+// DynamicGEXF returns a dynamic GEXF 1.3 graph that merges the snapshots, which must be ordered by Time.
+// Nodes are matched by id ; edges are matched by the ids of their nodes.
+// Nodes and edges get a spell for each period they are present and attribute values get a period for each change.
+// The visual attributes (see GEXF) are taken from the last snapshot in which the node or edge is present.
+func DynamicGEXF(snapshots []GEXFSnapshot) string {
+	b := new(bytes.Buffer)
+	WriteDynamicGEXF(b, snapshots)
+	return b.String()
+}
+
+// WriteDynamicGEXF is WriteGEXF for the dynamic graph of DynamicGEXF.
+func WriteDynamicGEXF(w io.Writer, snapshots []GEXFSnapshot) (int64, error) {
+	out := NewIndentWriter(w)
+	newGEXFWriter(snapshots, true).write(out)
+	return out.Written(), out.Err()
+}
+
+// gexfElement is a node or edge with its attributes per snapshot ; nil if absent.
+type gexfElement struct {
+	id             string
+	source, target string
+	attributes     []map[string]interface{}
+}
+
+type gexfAttribute struct {
+	name, kind string
+}
+
+type gexfWriter struct {
+	snapshots  []GEXFSnapshot
+	dynamic    bool
+	directed   bool
+	nodes      []*gexfElement
+	edges      []*gexfElement
+	nodeAttrs  []gexfAttribute
+	edgeAttrs  []gexfAttribute
+	nodeByID   map[string]*gexfElement
+	edgeByKey  map[string]*gexfElement
+	attrsIndex map[string]int
+}
+
+func newGEXFWriter(snapshots []GEXFSnapshot, dynamic bool) *gexfWriter {
+	w := &gexfWriter{
+		snapshots:  snapshots,
+		dynamic:    dynamic,
+		directed:   true,
+		nodeByID:   map[string]*gexfElement{},
+		edgeByKey:  map[string]*gexfElement{},
+		attrsIndex: map[string]int{},
+	}
+	if len(snapshots) > 0 && snapshots[0].Graph != nil {
+		w.directed = snapshots[0].Graph.IsDirected()
+	}
+	for i, each := range snapshots {
+		if each.Graph == nil {
+			continue
+		}
+		each.Graph.rlock()
+		w.collect(i, each.Graph, map[string]int{})
+		each.Graph.runlock()
+	}
+	return w
+}
+
+// collect adds the nodes and edges of a graph and its subgraphs for the snapshot at index i.
+// pairs counts the edges between two nodes to match parallel edges.
+func (w *gexfWriter) collect(i int, g *Graph, pairs map[string]int) {
+	for _, key := range g.orderedNodesKeys() {
+		each := g.nodes[key]
+		elem, ok := w.nodeByID[each.id]
+		if !ok {
+			elem = &gexfElement{id: each.id, attributes: make([]map[string]interface{}, len(w.snapshots))}
+			w.nodeByID[each.id] = elem
+			w.nodes = append(w.nodes, elem)
+		}
+		attrs := g.effectiveAttributes(each.attributes, true)
+		elem.attributes[i] = attrs
+		w.declare("node", attrs)
+	}
+	for _, key := range g.orderedSubgraphsKeys() {
+		w.collect(i, g.subgraphs[key], pairs)
+	}
+	for _, each := range g.orderedEdges() {
+		pair := each.from.id + "\x00" + each.to.id
+		key := fmt.Sprintf("%s\x00%d", pair, pairs[pair])
+		pairs[pair]++
+		elem, ok := w.edgeByKey[key]
+		if !ok {
+			elem = &gexfElement{
+				id:         strconv.Itoa(len(w.edges)),
+				source:     each.from.id,
+				target:     each.to.id,
+				attributes: make([]map[string]interface{}, len(w.snapshots))}
+			w.edgeByKey[key] = elem
+			w.edges = append(w.edges, elem)
+		}
+		attrs := g.effectiveAttributes(each.attributes, false)
+		elem.attributes[i] = attrs
+		w.declare("edge", attrs)
+	}
+}
+
+// gexfKind returns the GEXF attribute type of a value.
+func gexfKind(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case int:
+		return "integer"
+	case int64:
+		return "long"
+	case float32:
+		return "float"
+	case float64:
+		return "double"
+	}
+	return "string"
+}
+
+// declare adds the attributes, other than label, to the attribute declarations of the class.
+// An attribute with values of different types is declared as string.
+func (w *gexfWriter) declare(class string, attrs map[string]interface{}) {
+	for _, name := range sortedNames(attrs) {
+		value := attrs[name]
+		if name == "label" {
+			continue
+		}
+		key := class + "\x00" + name
+		if index, ok := w.attrsIndex[key]; ok {
+			list := w.nodeAttrs
+			if class == "edge" {
+				list = w.edgeAttrs
+			}
+			if list[index].kind != gexfKind(value) {
+				list[index].kind = "string"
+			}
+			continue
+		}
+		if class == "node" {
+			w.attrsIndex[key] = len(w.nodeAttrs)
+			w.nodeAttrs = append(w.nodeAttrs, gexfAttribute{name: name, kind: gexfKind(value)})
+		} else {
+			w.attrsIndex[key] = len(w.edgeAttrs)
+			w.edgeAttrs = append(w.edgeAttrs, gexfAttribute{name: name, kind: gexfKind(value)})
+		}
+	}
+}
+
+// timeAt returns the formatted start time of the snapshot at index i ; empty if past the last.
+func (w *gexfWriter) timeAt(i int) string {
+	if i >= len(w.snapshots) {
+		return ""
+	}
+	return w.snapshots[i].Time.Format(time.RFC3339)
+}
+
+// period returns the start and end attributes for the snapshots from..to (exclusive) ;
+// empty if not dynamic.
+func (w *gexfWriter) period(from, to int) string {
+	if !w.dynamic {
+		return ""
+	}
+	s := fmt.Sprintf(` start="%s"`, w.timeAt(from))
+	if end := w.timeAt(to); end != "" {
+		s += fmt.Sprintf(` end="%s"`, end)
+	}
+	return s
+}
+
+func (w *gexfWriter) write(out *IndentWriter) {
+	out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	out.WriteString(`<gexf xmlns="http://gexf.net/1.3" xmlns:viz="http://gexf.net/1.3/viz" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://gexf.net/1.3 http://gexf.net/1.3/gexf.xsd" version="1.3">` + "\n")
+	out.WriteString("  <meta>\n    <creator>github.com/emicklei/dot</creator>\n  </meta>\n")
+	edgeType := "directed"
+	if !w.directed {
+		edgeType = "undirected"
+	}
+	if w.dynamic {
+		fmt.Fprintf(out, `  <graph mode="dynamic" defaultedgetype="%s" timeformat="datetime" timerepresentation="interval">`+"\n", edgeType)
+	} else {
+		fmt.Fprintf(out, `  <graph mode="static" defaultedgetype="%s">`+"\n", edgeType)
+	}
+	w.writeAttributes(out, "node", w.nodeAttrs)
+	w.writeAttributes(out, "edge", w.edgeAttrs)
+	out.WriteString("    <nodes>\n")
+	for _, each := range w.nodes {
+		w.writeElement(out, "node", each, w.nodeAttrs)
+	}
+	out.WriteString("    </nodes>\n    <edges>\n")
+	for _, each := range w.edges {
+		w.writeElement(out, "edge", each, w.edgeAttrs)
+	}
+	out.WriteString("    </edges>\n  </graph>\n</gexf>\n")
+}
+
+func (w *gexfWriter) writeAttributes(out *IndentWriter, class string, attrs []gexfAttribute) {
+	if len(attrs) == 0 {
+		return
+	}
+	mode := ""
+	if w.dynamic {
+		mode = ` mode="dynamic"`
+	}
+	fmt.Fprintf(out, `    <attributes class="%s"%s>`+"\n", class, mode)
+	for _, each := range attrs {
+		fmt.Fprintf(out, `      <attribute id="%s" title="%s" type="%s"/>`+"\n", xmlEscape(each.name), xmlEscape(each.name), each.kind)
+	}
+	out.WriteString("    </attributes>\n")
+}
+
+// runs returns the [from,to) ranges of consecutive snapshots for which same returns true
+// for the attributes of the element.
+func runs(attributes []map[string]interface{}, present func(map[string]interface{}) bool, same func(a, b map[string]interface{}) bool) [][2]int {
+	list := [][2]int{}
+	for i := 0; i < len(attributes); i++ {
+		if attributes[i] == nil || !present(attributes[i]) {
+			continue
+		}
+		j := i + 1
+		for j < len(attributes) && attributes[j] != nil && present(attributes[j]) && same(attributes[i], attributes[j]) {
+			j++
+		}
+		list = append(list, [2]int{i, j})
+		i = j - 1
+	}
+	return list
+}
+
+func always(map[string]interface{}) bool { return true }
+
+func (w *gexfWriter) writeElement(out *IndentWriter, class string, elem *gexfElement, declared []gexfAttribute) {
+	// the last attributes are used for the label and visual attributes
+	var last map[string]interface{}
+	for _, each := range elem.attributes {
+		if each != nil {
+			last = each
+		}
+	}
+	label := elem.id
+	if v, ok := last["label"]; ok {
+		label = fmt.Sprintf("%v", v)
+	}
+	if class == "node" {
+		fmt.Fprintf(out, `      <node id="%s" label="%s"`, xmlEscape(elem.id), xmlEscape(label))
+	} else {
+		fmt.Fprintf(out, `      <edge id="%s" source="%s" target="%s"`, elem.id, xmlEscape(elem.source), xmlEscape(elem.target))
+		if _, ok := last["label"]; ok {
+			fmt.Fprintf(out, ` label="%s"`, xmlEscape(label))
+		}
+		if f, ok := floatValue(last["weight"]); ok {
+			fmt.Fprintf(out, ` weight="%s"`, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	out.WriteString(">\n")
+	if w.dynamic {
+		out.WriteString("        <spells>\n")
+		for _, each := range runs(elem.attributes, always, func(a, b map[string]interface{}) bool { return true }) {
+			fmt.Fprintf(out, "          <spell%s/>\n", w.period(each[0], each[1]))
+		}
+		out.WriteString("        </spells>\n")
+	}
+	values := new(strings.Builder)
+	for _, attr := range declared {
+		name := attr.name
+		has := func(m map[string]interface{}) bool { _, ok := m[name]; return ok }
+		same := func(a, b map[string]interface{}) bool {
+			return fmt.Sprintf("%v", a[name]) == fmt.Sprintf("%v", b[name])
+		}
+		for _, each := range runs(elem.attributes, has, same) {
+			fmt.Fprintf(values, `          <attvalue for="%s" value="%s"%s/>`+"\n",
+				xmlEscape(name), xmlEscape(fmt.Sprintf("%v", elem.attributes[each[0]][name])), w.period(each[0], each[1]))
+		}
+	}
+	if values.Len() > 0 {
+		out.WriteString("        <attvalues>\n")
+		out.WriteString(values.String())
+		out.WriteString("        </attvalues>\n")
+	}
+	if class == "node" {
+		writeGEXFNodeViz(out, last)
+	} else {
+		writeGEXFEdgeViz(out, last)
+	}
+	fmt.Fprintf(out, "      </%s>\n", class)
+}
+
+func writeGEXFColor(out *IndentWriter, value interface{}) {
+	r, g, b, a, ok := colorRGBA(value)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(out, `        <viz:color r="%d" g="%d" b="%d"`, r, g, b)
+	if a < 1 {
+		fmt.Fprintf(out, ` a="%s"`, strconv.FormatFloat(a, 'f', 2, 64))
+	}
+	out.WriteString("/>\n")
+}
+
+func writeGEXFNodeViz(out *IndentWriter, attrs map[string]interface{}) {
+	if v, ok := attrs["fillcolor"]; ok {
+		writeGEXFColor(out, v)
+	} else {
+		writeGEXFColor(out, attrs["color"])
+	}
+	if x, y, ok := positionValue(attrs["pos"]); ok {
+		fmt.Fprintf(out, `        <viz:position x="%s" y="%s" z="0"/>`+"\n",
+			strconv.FormatFloat(x, 'g', -1, 64), strconv.FormatFloat(y, 'g', -1, 64))
+	}
+	size, ok := floatValue(attrs["width"])
+	if h, hok := floatValue(attrs["height"]); hok && (!ok || h > size) {
+		size, ok = h, true
+	}
+	if ok {
+		fmt.Fprintf(out, `        <viz:size value="%s"/>`+"\n", strconv.FormatFloat(size*72, 'g', -1, 64))
+	}
+	shape := ""
+	switch strings.ToLower(fmt.Sprintf("%v", attrs["shape"])) {
+	case "circle", "ellipse", "oval", "point", "doublecircle":
+		shape = "disc"
+	case "box", "rect", "rectangle", "square":
+		shape = "square"
+	case "triangle", "invtriangle":
+		shape = "triangle"
+	case "diamond", "mdiamond":
+		shape = "diamond"
+	}
+	if shape != "" {
+		fmt.Fprintf(out, `        <viz:shape value="%s"/>`+"\n", shape)
+	}
+}
+
+func writeGEXFEdgeViz(out *IndentWriter, attrs map[string]interface{}) {
+	writeGEXFColor(out, attrs["color"])
+	if f, ok := floatValue(attrs["penwidth"]); ok {
+		fmt.Fprintf(out, `        <viz:thickness value="%s"/>`+"\n", strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	style := strings.ToLower(fmt.Sprintf("%v", attrs["style"]))
+	for _, each := range []string{"dashed", "dotted"} {
+		if strings.Contains(style, each) {
+			fmt.Fprintf(out, `        <viz:shape value="%s"/>`+"\n", each)
+			break
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package dot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGEXF(t *testing.T) {
+	g := NewGraph(Directed)
+	g.NodeDefaults().Attr("shape", "box")
+	c := g.Subgraph("api", ClusterOption{})
+	a := c.Node("a").Attr("fillcolor", "#ff8000").Attr("width", 1.0).Attr("pos", "10,20!")
+	b := g.Node("b").Attr("color", "lightblue").Attr("replicas", 3).Label("B & b")
+	a.Edge(b, "calls").Attr("penwidth", 2).Attr("style", "dashed").Attr("weight", 4)
+	out := GEXF(g)
+	for _, each := range []string{
+		`<graph mode="static" defaultedgetype="directed">`,
+		`<attribute id="replicas" title="replicas" type="integer"/>`,
+		`<attribute id="shape" title="shape" type="string"/>`,
+		`<node id="a" label="a">`,
+		`<node id="b" label="B &amp; b">`,
+		`<attvalue for="replicas" value="3"/>`,
+		`<viz:color r="255" g="128" b="0"/>`,
+		`<viz:color r="173" g="216" b="230"/>`,
+		`<viz:position x="10" y="20" z="0"/>`,
+		`<viz:size value="72"/>`,
+		`<viz:shape value="square"/>`,
+		`<edge id="0" source="a" target="b" label="calls" weight="4">`,
+		`<viz:thickness value="2"/>`,
+		`<viz:shape value="dashed"/>`,
+	} {
+		if !strings.Contains(out, each) {
+			t.Errorf("missing %s in %s", each, out)
+		}
+	}
+	if strings.Contains(out, `for="label"`) {
+		t.Errorf("label must not be an attribute in %s", out)
+	}
+}
+
+func TestDynamicGEXF(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshots := []GEXFSnapshot{}
+	for i, edges := range [][]string{{"a-b"}, {"a-b", "b-c"}, {"b-c"}, {"a-b", "b-c"}} {
+		g := NewGraph(Directed)
+		for _, each := range edges {
+			ids := strings.Split(each, "-")
+			from, to := g.Node(ids[0]), g.Node(ids[1])
+			from.Attr("version", "v1")
+			if i > 1 {
+				from.Attr("version", "v2")
+			}
+			from.Edge(to)
+		}
+		snapshots = append(snapshots, GEXFSnapshot{Time: t0.Add(time.Duration(i) * time.Hour), Graph: g})
+	}
+	out := DynamicGEXF(snapshots)
+	for _, each := range []string{
+		`<graph mode="dynamic" defaultedgetype="directed" timeformat="datetime" timerepresentation="interval">`,
+		`<attributes class="node" mode="dynamic">`,
+		// a is absent in the third snapshot
+		`<node id="a" label="a">
+        <spells>
+          <spell start="2024-01-01T00:00:00Z" end="2024-01-01T02:00:00Z"/>
+          <spell start="2024-01-01T03:00:00Z"/>
+        </spells>
+        <attvalues>
+          <attvalue for="version" value="v1" start="2024-01-01T00:00:00Z" end="2024-01-01T02:00:00Z"/>
+          <attvalue for="version" value="v2" start="2024-01-01T03:00:00Z"/>
+        </attvalues>`,
+		// c is present from the second snapshot
+		`<node id="c" label="c">
+        <spells>
+          <spell start="2024-01-01T01:00:00Z"/>
+        </spells>`,
+		`<edge id="1" source="b" target="c">
+        <spells>
+          <spell start="2024-01-01T01:00:00Z"/>`,
+	} {
+		if !strings.Contains(out, each) {
+			t.Errorf("missing %s in %s", each, out)
+		}
+	}
+	if got, want := strings.Count(out, "<edge "), 2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestWriteGEXF(t *testing.T) {
+	g := NewGraph(Undirected)
+	g.Node("a").Edge(g.Node("b"))
+	buf := new(bytes.Buffer)
+	n, err := WriteGEXF(buf, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(buf.Len()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if !strings.Contains(buf.String(), `defaultedgetype="undirected"`) {
+		t.Errorf("missing edge type in %s", buf.String())
+	}
+	n, err = WriteGEXF(&failingWriter{limit: 10}, g)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if got, want := n, int64(10); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestColorRGBA(t *testing.T) {
+	for _, each := range []struct {
+		in         interface{}
+		r, g, b, a float64
+		ok         bool
+	}{
+		{"red", 255, 0, 0, 1, true},
+		{"#00ff0080", 0, 255, 0, 128.0 / 255, true},
+		{"/x11/navy", 0, 0, 128, 1, true},
+		{"0.000 1.000 1.000", 255, 0, 0, 1, true},
+		{"blue:red", 0, 0, 255, 1, true},
+		{"transparent", 255, 255, 254, 0, true},
+		{"unknown", 0, 0, 0, 0, false},
+		{nil, 0, 0, 0, 0, false},
+	} {
+		r, g, b, a, ok := colorRGBA(each.in)
+		if got, want := []float64{float64(r), float64(g), float64(b), a}, []float64{each.r, each.g, each.b, each.a}; ok != each.ok || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+			t.Errorf("%v: got [%v %v] want [%v %v]", each.in, got, ok, want, each.ok)
+		}
+	}
+}
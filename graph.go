@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
 )
 
 // Graph represents a dot graph with nodes and edges.
@@ -15,11 +17,31 @@ type Graph struct {
 	isStrict  bool
 	graphType string
 	seq       int
+	// edgeSeq is the sequence for edges ; only used on the root graph
+	edgeSeq int
+	// index is the sequence number taken when the subgraph was created
+	index int
+	// order is set by OrderOption ; only used on the root graph
+	order     string
 	nodes     map[string]Node
 	edgesFrom map[string][]Edge
 	subgraphs map[string]*Graph
 	parent    *Graph
-	sameRank  map[string][]Node
+	// sameRank has the nodes of all rank groups, not only those with rank=same
+	sameRank map[string][]Node
+	// rankTypes has the RankType of each rank group
+	rankTypes map[string]RankType
+	// rankOrder has the names of the rank groups in order of creation
+	rankOrder []string
+	// defaults are written as node [..] and edge [..] statements
+	nodeDefaults AttributesMap
+	edgeDefaults AttributesMap
+	// useNodeIDs is set by NodeIDOption ; only used on the root graph
+	useNodeIDs bool
+	// classDefs are the classDef statements read by ParseMermaid ; only used on the root graph
+	classDefs map[string]string
+	// mu is set by the Concurrent option and shared by the root graph, all its subgraphs, nodes and edges
+	mu *sync.RWMutex
 	//
 	nodeInitializer func(Node)
 	edgeInitializer func(Edge)
@@ -35,6 +57,9 @@ func NewGraph(options ...GraphOption) *Graph {
 		edgesFrom:     map[string][]Edge{},
 		subgraphs:     map[string]*Graph{},
 		sameRank:      map[string][]Node{},
+		rankTypes:     map[string]RankType{},
+		nodeDefaults:  AttributesMap{attributes: map[string]interface{}{}},
+		edgeDefaults:  AttributesMap{attributes: map[string]interface{}{}},
 	}
 	for _, each := range options {
 		each.Apply(graph)
@@ -42,28 +67,72 @@ func NewGraph(options ...GraphOption) *Graph {
 	return graph
 }
 
+// lock, unlock, rlock and runlock guard the graph if it was created with the Concurrent option.
+// Methods that take the lock must only call unexported methods that do not.
+func (g *Graph) lock() {
+	if g.mu != nil {
+		g.mu.Lock()
+	}
+}
+
+func (g *Graph) unlock() {
+	if g.mu != nil {
+		g.mu.Unlock()
+	}
+}
+
+func (g *Graph) rlock() {
+	if g.mu != nil {
+		g.mu.RLock()
+	}
+}
+
+func (g *Graph) runlock() {
+	if g.mu != nil {
+		g.mu.RUnlock()
+	}
+}
+
+// setMutex makes the graph and its attributes use the lock ; nil means no locking.
+func (g *Graph) setMutex(mu *sync.RWMutex) {
+	g.mu = mu
+	g.AttributesMap.mu = mu
+	g.nodeDefaults.mu = mu
+	g.edgeDefaults.mu = mu
+}
+
 // WalkEdges iterates over all edges in the graph and all its subgraphs recursively
 // and calls the callback function for each edge. Abort if the callback returns false.
 func (g *Graph) WalkEdges(callback func(edge Edge) bool) {
-	for _, edges := range g.edgesFrom {
-		for _, edge := range edges {
-			if !callback(edge) {
-				return
-			}
+	g.rlock()
+	var all []Edge
+	g.collectEdges(&all)
+	g.runlock()
+	for _, edge := range all {
+		if !callback(edge) {
+			return
 		}
 	}
-	for _, subgraph := range g.subgraphs {
-		subgraph.WalkEdges(callback)
+}
+
+func (g *Graph) collectEdges(all *[]Edge) {
+	*all = append(*all, g.orderedEdges()...)
+	for _, key := range g.orderedSubgraphsKeys() {
+		g.subgraphs[key].collectEdges(all)
 	}
 }
 
 // GetID returns the identifier of the graph.
 func (g *Graph) GetID() string {
+	g.rlock()
+	defer g.runlock()
 	return g.id
 }
 
 // ID sets the identifier of the graph.
 func (g *Graph) ID(newID string) *Graph {
+	g.lock()
+	defer g.unlock()
 	if len(g.id) > 0 {
 		panic("cannot overwrite non-empty id ; both the old and the new could be in use and we cannot tell")
 	}
@@ -81,6 +150,74 @@ func (g *Graph) beCluster() {
 	g.id = "cluster_" + g.id
 }
 
+// NodeDefaults returns the default attributes for all nodes of this graph and its subgraphs.
+// These are written as a "node [..]" statement instead of being copied onto each node.
+// Use Node.EffectiveValue to get the value of a node attribute, including its defaults.
+func (g *Graph) NodeDefaults() AttributesMap {
+	return g.nodeDefaults
+}
+
+// EdgeDefaults returns the default attributes for all edges of this graph and its subgraphs.
+// These are written as an "edge [..]" statement instead of being copied onto each edge.
+// Use Edge.EffectiveValue to get the value of an edge attribute, including its defaults.
+func (g *Graph) EdgeDefaults() AttributesMap {
+	return g.edgeDefaults
+}
+
+// EffectiveValue returns the value of a graph attribute. If absent then the value is
+// inherited from the parent graph, as Graphviz does for subgraphs. Returns nil if not set.
+func (g *Graph) EffectiveValue(label string) interface{} {
+	g.rlock()
+	defer g.runlock()
+	for each := g; each != nil; each = each.parent {
+		if v, ok := each.attributes[label]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// inheritedNodeDefault returns the default value for a node attribute from the graph or one of its parents.
+func (g *Graph) inheritedNodeDefault(label string) (interface{}, bool) {
+	for each := g; each != nil; each = each.parent {
+		if v, ok := each.nodeDefaults.attributes[label]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// inheritedEdgeDefault returns the default value for an edge attribute from the graph or one of its parents.
+func (g *Graph) inheritedEdgeDefault(label string) (interface{}, bool) {
+	for each := g; each != nil; each = each.parent {
+		if v, ok := each.edgeDefaults.attributes[label]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// effectiveAttributes returns a copy of the attributes of a node or edge of this graph,
+// completed with the node or edge defaults of the graph and its parents.
+func (g *Graph) effectiveAttributes(attributes map[string]interface{}, node bool) map[string]interface{} {
+	all := map[string]interface{}{}
+	for each := g; each != nil; each = each.parent {
+		defaults := each.edgeDefaults.attributes
+		if node {
+			defaults = each.nodeDefaults.attributes
+		}
+		for k, v := range defaults {
+			if _, ok := all[k]; !ok {
+				all[k] = v
+			}
+		}
+	}
+	for k, v := range attributes {
+		all[k] = v
+	}
+	return all
+}
+
 // Root returns the top-level graph if this was a subgraph.
 func (g *Graph) Root() *Graph {
 	if g.parent == nil {
@@ -89,7 +226,14 @@ func (g *Graph) Root() *Graph {
 	return g.parent.Root()
 }
 
+// FindNodeWithLabel returns the node with the given label from the graph or one from its parents.
 func (g *Graph) FindNodeWithLabel(label string) (Node, bool) {
+	g.rlock()
+	defer g.runlock()
+	return g.findNodeWithLabel(label)
+}
+
+func (g *Graph) findNodeWithLabel(label string) (Node, bool) {
 	for _, each := range g.nodes {
 		if eachLabel, ok := each.attributes["label"]; ok {
 			if eachLabel == label {
@@ -101,16 +245,32 @@ func (g *Graph) FindNodeWithLabel(label string) (Node, bool) {
 	if g.parent == nil {
 		return Node{id: "void"}, false
 	}
-	return g.parent.FindNodeWithLabel(label)
-
+	return g.parent.findNodeWithLabel(label)
 }
 
 // FindSubgraph returns the subgraph of the graph or one from its parents.
 func (g *Graph) FindSubgraph(id string) (*Graph, bool) {
+	g.rlock()
+	defer g.runlock()
+	return g.findSubgraph(id)
+}
+
+// Subgraphs returns the direct subgraphs of this graph in the order of writing.
+func (g *Graph) Subgraphs() []*Graph {
+	g.rlock()
+	defer g.runlock()
+	list := []*Graph{}
+	for _, key := range g.orderedSubgraphsKeys() {
+		list = append(list, g.subgraphs[key])
+	}
+	return list
+}
+
+func (g *Graph) findSubgraph(id string) (*Graph, bool) {
 	sub, ok := g.subgraphs[id]
 	if !ok {
 		if g.parent != nil {
-			return g.parent.FindSubgraph(id)
+			return g.parent.findSubgraph(id)
 		}
 	}
 	return sub, ok
@@ -119,17 +279,21 @@ func (g *Graph) FindSubgraph(id string) (*Graph, bool) {
 // Subgraph returns the Graph with the given id ; creates one if absent.
 // The label attribute is also set to the id ; use Label() to overwrite it.
 func (g *Graph) Subgraph(id string, options ...GraphOption) *Graph {
+	g.lock()
+	defer g.unlock()
 	sub, ok := g.subgraphs[id]
 	if ok {
 		return sub
 	}
 	sub = NewGraph(Sub)
 	sub.Attr("label", id) // for consistency with Node creation behavior.
-	sub.id = fmt.Sprintf("s%d", g.nextSeq())
+	sub.index = g.nextSeq()
+	sub.id = fmt.Sprintf("s%d", sub.index)
 	for _, each := range options {
 		each.Apply(sub)
 	}
 	sub.parent = g
+	sub.setMutex(g.mu)
 	sub.edgeInitializer = g.edgeInitializer
 	sub.nodeInitializer = g.nodeInitializer
 	g.subgraphs[id] = sub
@@ -153,22 +317,38 @@ func (g *Graph) nextSeq() int {
 	return root.seq
 }
 
+// nextEdgeSeq takes the next edge sequence number from the root graph
+func (g *Graph) nextEdgeSeq() int {
+	root := g.Root()
+	root.edgeSeq++
+	return root.edgeSeq
+}
+
 // NodeInitializer sets a function that is called (if not nil) when a Node is implicitly created.
+// The function can use the graph, also if it was created with the Concurrent option ;
+// if two goroutines create the same node then it can be called for both but only one node is kept.
 func (g *Graph) NodeInitializer(callback func(n Node)) {
+	g.lock()
+	defer g.unlock()
 	g.nodeInitializer = callback
 }
 
 // EdgeInitializer sets a function that is called (if not nil) when an Edge is implicitly created.
+// The function can use the graph, also if it was created with the Concurrent option.
 func (g *Graph) EdgeInitializer(callback func(e Edge)) {
+	g.lock()
+	defer g.unlock()
 	g.edgeInitializer = callback
 }
 
 // Node returns the node created with this id or creates a new node if absent.
 // The node will have a label attribute with the id as its value. Use Label() to overwrite this.
 // This method can be used as both a constructor and accessor.
-// not thread safe!
+// Not thread safe unless the graph was created with the Concurrent option.
 func (g *Graph) Node(id string) Node {
+	g.lock()
 	if n, ok := g.findNode(id); ok {
+		g.unlock()
 		return n
 	}
 	n := Node{
@@ -178,9 +358,19 @@ func (g *Graph) Node(id string) Node {
 			"label": id}},
 		graph: g,
 	}
-	if g.nodeInitializer != nil {
-		g.nodeInitializer(n)
+	initializer := g.nodeInitializer
+	g.unlock()
+	// the initializer is called without holding the lock such that it can use the graph ; the node is not shared yet
+	if initializer != nil {
+		initializer(n)
 	}
+	g.lock()
+	defer g.unlock()
+	// another goroutine can have created the node in the meantime
+	if other, ok := g.findNode(id); ok {
+		return other
+	}
+	n.AttributesMap.mu = g.mu
 	// store local
 	g.nodes[id] = n
 	return n
@@ -189,6 +379,8 @@ func (g *Graph) Node(id string) Node {
 // DeleteNode deletes a node and all the edges associated to the node
 // Returns false if the node wasn't found, true otherwise
 func (g *Graph) DeleteNode(id string) bool {
+	g.lock()
+	defer g.unlock()
 	if _, ok := g.findNode(id); ok {
 		// Remove Node
 		delete(g.nodes, id)
@@ -218,16 +410,14 @@ func (g *Graph) Edge(fromNode, toNode Node, labels ...string) Edge {
 // EdgeWithPorts creates a new edge between two nodes with ports.
 // Other functionality are the same
 func (g *Graph) EdgeWithPorts(fromNode, toNode Node, fromNodePort, toNodePort string, labels ...string) Edge {
-	// assume fromNode owner == toNode owner
-	edgeOwner := g
-	if fromNode.graph != toNode.graph { // 1 or 2 are subgraphs
-		edgeOwner = commonParentOf(fromNode.graph, toNode.graph)
-	}
+	g.lock()
+	edgeOwner := g.edgeOwner(fromNode, toNode)
 	e := Edge{
 		from:          fromNode,
 		to:            toNode,
 		AttributesMap: AttributesMap{attributes: map[string]interface{}{}},
-		graph:         edgeOwner}
+		graph:         edgeOwner,
+		seq:           g.nextEdgeSeq()}
 	if fromNodePort != "" {
 		e.fromPort = fromNodePort
 	}
@@ -237,9 +427,15 @@ func (g *Graph) EdgeWithPorts(fromNode, toNode Node, fromNodePort, toNodePort st
 	if len(labels) > 0 {
 		e.Attr("label", strings.Join(labels, ","))
 	}
-	if g.edgeInitializer != nil {
-		g.edgeInitializer(e)
+	initializer := g.edgeInitializer
+	g.unlock()
+	// the initializer is called without holding the lock such that it can use the graph ; the edge is not shared yet
+	if initializer != nil {
+		initializer(e)
 	}
+	g.lock()
+	defer g.unlock()
+	e.AttributesMap.mu = g.mu
 	edgeOwner.edgesFrom[fromNode.id] = append(edgeOwner.edgesFrom[fromNode.id], e)
 	return e
 }
@@ -247,11 +443,10 @@ func (g *Graph) EdgeWithPorts(fromNode, toNode Node, fromNodePort, toNodePort st
 // FindEdges finds all edges in the graph that go from the fromNode to the toNode.
 // Otherwise, returns an empty slice.
 func (g *Graph) FindEdges(fromNode, toNode Node) (found []Edge) {
+	g.rlock()
+	defer g.runlock()
 	found = make([]Edge, 0)
-	edgeOwner := g
-	if fromNode.graph != toNode.graph {
-		edgeOwner = commonParentOf(fromNode.graph, toNode.graph)
-	}
+	edgeOwner := g.edgeOwner(fromNode, toNode)
 	if edges, ok := edgeOwner.edgesFrom[fromNode.id]; ok {
 		for _, e := range edges {
 			if e.to.id == toNode.id {
@@ -262,14 +457,110 @@ func (g *Graph) FindEdges(fromNode, toNode Node) (found []Edge) {
 	return found
 }
 
+// edgeOwner returns the graph that holds the edges between two nodes.
+// This is the nearest (sub)graph that contains both nodes, independent of the receiver,
+// so that creating and finding edges agree. Falls back to the receiver for nodes without a graph.
+func (g *Graph) edgeOwner(fromNode, toNode Node) *Graph {
+	if fromNode.graph == nil || toNode.graph == nil {
+		return g
+	}
+	if fromNode.graph == toNode.graph {
+		return fromNode.graph
+	}
+	return commonParentOf(fromNode.graph, toNode.graph)
+}
+
+// commonParentOf returns the nearest graph that is (an ancestor of) both graphs.
+// Returns the root of one if the graphs are not related.
 func commonParentOf(one *Graph, two *Graph) *Graph {
-	// TODO
+	ancestors := map[*Graph]bool{}
+	for each := one; each != nil; each = each.parent {
+		ancestors[each] = true
+	}
+	for each := two; each != nil; each = each.parent {
+		if ancestors[each] {
+			return each
+		}
+	}
 	return one.Root()
 }
 
+// RankType is the value of the rank attribute of a rank group, see AddToRank.
+type RankType string
+
+const (
+	// RankSame puts all nodes of the group on the same rank.
+	RankSame RankType = "same"
+	// RankMin puts all nodes of the group on the minimum rank.
+	RankMin RankType = "min"
+	// RankMax puts all nodes of the group on the maximum rank.
+	RankMax RankType = "max"
+	// RankSource puts all nodes of the group on the minimum rank, and only these.
+	RankSource RankType = "source"
+	// RankSink puts all nodes of the group on the maximum rank, and only these.
+	RankSink RankType = "sink"
+)
+
 // AddToSameRank adds the given nodes to the specified rank group, forcing them to be rendered in the same row
 func (g *Graph) AddToSameRank(group string, nodes ...Node) {
+	g.AddToRank(RankSame, group, nodes...)
+}
+
+// AddToRank adds the given nodes to the specified rank group and sets the rank type of the group.
+// A rank group is written as {rank=<type>; ...} in the graph on which it was added, e.g. a cluster.
+func (g *Graph) AddToRank(rank RankType, group string, nodes ...Node) {
+	g.lock()
+	defer g.unlock()
+	if _, ok := g.sameRank[group]; !ok {
+		g.rankOrder = append(g.rankOrder, group)
+	}
 	g.sameRank[group] = append(g.sameRank[group], nodes...)
+	g.rankTypes[group] = rank
+}
+
+// RankGroup returns the rank type and the nodes of a rank group of this graph.
+func (g *Graph) RankGroup(group string) (RankType, []Node, bool) {
+	g.rlock()
+	defer g.runlock()
+	nodes, ok := g.sameRank[group]
+	if !ok {
+		return "", nil, false
+	}
+	return g.rankTypeOf(group), append([]Node(nil), nodes...), true
+}
+
+// RankGroups returns the names of the rank groups of this graph in the order of writing.
+func (g *Graph) RankGroups() []string {
+	g.rlock()
+	defer g.runlock()
+	return g.orderedRankGroups()
+}
+
+// RemoveRankGroup removes the rank group from this graph ; the nodes are not removed.
+// Returns false if the group wasn't found, true otherwise.
+func (g *Graph) RemoveRankGroup(group string) bool {
+	g.lock()
+	defer g.unlock()
+	if _, ok := g.sameRank[group]; !ok {
+		return false
+	}
+	delete(g.sameRank, group)
+	delete(g.rankTypes, group)
+	for i, each := range g.rankOrder {
+		if each == group {
+			g.rankOrder = append(g.rankOrder[:i], g.rankOrder[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// rankTypeOf returns the RankType of a group ; RankSame if unknown.
+func (g *Graph) rankTypeOf(group string) RankType {
+	if rank, ok := g.rankTypes[group]; ok {
+		return rank
+	}
+	return RankSame
 }
 
 // String returns the source in dot notation.
@@ -279,61 +570,91 @@ func (g *Graph) String() string {
 	return b.String()
 }
 
+// Write writes the graph in dot notation. Errors from the writer are ignored ; use WriteTo to get them.
 func (g *Graph) Write(w io.Writer) {
 	g.IndentedWrite(NewIndentWriter(w))
 }
 
+// WriteTo writes the graph in dot notation and returns the number of bytes written
+// and the first error returned by the writer, if any. It implements io.WriterTo.
+func (g *Graph) WriteTo(w io.Writer) (int64, error) {
+	iw := NewIndentWriter(w)
+	g.IndentedWrite(iw)
+	return iw.Written(), iw.Err()
+}
+
 // IndentedWrite write the graph to a writer using simple TAB indentation.
 func (g *Graph) IndentedWrite(w *IndentWriter) {
+	g.rlock()
+	defer g.runlock()
+	g.indentedWrite(w)
+}
+
+func (g *Graph) indentedWrite(w *IndentWriter) {
 	if g.isStrict && g.graphType != Sub.Name {
 		fmt.Fprintf(w, "strict ")
 	}
-	fmt.Fprintf(w, "%s %s {", g.graphType, g.id)
+	id := g.id
+	if id != "" {
+		id = quoteID(id)
+	}
+	fmt.Fprintf(w, "%s %s {", g.graphType, id)
 	w.NewLineIndentWhile(func() {
+		// defaults apply to everything that follows, including subgraphs
+		if len(g.nodeDefaults.attributes) > 0 {
+			fmt.Fprint(w, "node")
+			appendSortedMap(g.nodeDefaults.attributes, true, w)
+			fmt.Fprint(w, ";")
+			w.NewLine()
+		}
+		if len(g.edgeDefaults.attributes) > 0 {
+			fmt.Fprint(w, "edge")
+			appendSortedMap(g.edgeDefaults.attributes, true, w)
+			fmt.Fprint(w, ";")
+			w.NewLine()
+		}
 		// subgraphs
-		for _, key := range g.sortedSubgraphsKeys() {
+		for _, key := range g.orderedSubgraphsKeys() {
 			each := g.subgraphs[key]
-			each.IndentedWrite(w)
+			each.indentedWrite(w)
 		}
 		// graph attributes
 		appendSortedMap(g.AttributesMap.attributes, false, w)
 		w.NewLine()
 		// graph nodes
-		for _, key := range g.sortedNodesKeys() {
+		for _, key := range g.orderedNodesKeys() {
 			each := g.nodes[key]
-			fmt.Fprintf(w, "n%d", each.seq)
+			fmt.Fprint(w, g.nodeRef(each))
 			appendSortedMap(each.attributes, true, w)
 			fmt.Fprintf(w, ";")
 			w.NewLine()
 		}
 		// graph edges
+		// subgraphs own edges too ; the root decides whether they are directed
 		denoteEdge := "->"
-		if g.graphType == "graph" {
+		if g.Root().graphType == "graph" {
 			denoteEdge = "--"
 		}
-		for _, each := range g.sortedEdgesFromKeys() {
-			all := g.edgesFrom[each]
-			for _, each := range all {
-				fromPort := ""
-				if each.fromPort != "" {
-					fromPort = ":" + each.fromPort
-				}
-				toPort := ""
-				if each.toPort != "" {
-					toPort = ":" + each.toPort
-				}
-				fmt.Fprintf(w, "n%d%s%sn%d%s", each.from.seq, fromPort, denoteEdge, each.to.seq, toPort)
-				appendSortedMap(each.attributes, true, w)
-				fmt.Fprint(w, ";")
-				w.NewLine()
+		for _, each := range g.orderedEdges() {
+			fromPort := ""
+			if each.fromPort != "" {
+				fromPort = ":" + each.fromPort
 			}
+			toPort := ""
+			if each.toPort != "" {
+				toPort = ":" + each.toPort
+			}
+			fmt.Fprintf(w, "%s%s%s%s%s", g.nodeRef(each.from), fromPort, denoteEdge, g.nodeRef(each.to), toPort)
+			appendSortedMap(each.attributes, true, w)
+			fmt.Fprint(w, ";")
+			w.NewLine()
 		}
-		for _, nodes := range g.sameRank {
+		for _, group := range g.orderedRankGroups() {
 			str := ""
-			for _, n := range nodes {
-				str += fmt.Sprintf("n%d;", n.seq)
+			for _, n := range g.sameRank[group] {
+				str += g.nodeRef(n) + ";"
 			}
-			fmt.Fprintf(w, "{rank=same; %s};", str)
+			fmt.Fprintf(w, "{rank=%s; %s};", g.rankTypeOf(group), str)
 			w.NewLine()
 		}
 	})
@@ -341,6 +662,52 @@ func (g *Graph) IndentedWrite(w *IndentWriter) {
 	w.NewLine()
 }
 
+// nodeRef returns the identifier of the node as written in dot notation.
+// This is the node id if the root graph has the NodeIDOption, n<seq> otherwise.
+func (g *Graph) nodeRef(n Node) string {
+	if g.Root().useNodeIDs {
+		return quoteID(n.id)
+	}
+	return fmt.Sprintf("n%d", n.seq)
+}
+
+var (
+	unquotedIDPattern = regexp.MustCompile(`^[a-zA-Z\x{80}-\x{10FFFF}_][a-zA-Z\x{80}-\x{10FFFF}_0-9]*$`)
+	numeralIDPattern  = regexp.MustCompile(`^-?(\.[0-9]+|[0-9]+(\.[0-9]*)?)$`)
+)
+
+// quoteID returns the id as is if it is a valid unquoted DOT identifier or numeral, a double-quoted string otherwise.
+func quoteID(id string) string {
+	if (unquotedIDPattern.MatchString(id) && !isKeyword(id)) || numeralIDPattern.MatchString(id) {
+		return id
+	}
+	// only the double quote needs escaping ; other backslash sequences are kept by Graphviz
+	// but backslashes before a quote or at the end would escape that (closing) quote.
+	b := new(strings.Builder)
+	b.WriteRune('"')
+	for i, r := range id {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			// double each backslash of a run that ends with a quote or the id
+			end := i
+			for end < len(id) && id[end] == '\\' {
+				end++
+			}
+			if end == len(id) || id[end] == '"' {
+				b.WriteString(`\\`)
+			} else {
+				b.WriteRune(r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteRune('"')
+	return b.String()
+}
+
 func appendSortedMap(m map[string]interface{}, mustBracket bool, b io.Writer) {
 	if len(m) == 0 {
 		return
@@ -384,15 +751,23 @@ func appendSortedMap(m map[string]interface{}, mustBracket bool, b io.Writer) {
 
 // VisitNodes visits all nodes recursively
 func (g *Graph) VisitNodes(callback func(node Node) (done bool)) {
-	for _, node := range g.nodes {
-		done := callback(node)
-		if done {
+	g.rlock()
+	var all []Node
+	g.collectNodes(&all)
+	g.runlock()
+	for _, node := range all {
+		if callback(node) {
 			return
 		}
 	}
+}
 
-	for _, subGraph := range g.subgraphs {
-		subGraph.VisitNodes(callback)
+func (g *Graph) collectNodes(all *[]Node) {
+	for _, key := range g.orderedNodesKeys() {
+		*all = append(*all, g.nodes[key])
+	}
+	for _, key := range g.orderedSubgraphsKeys() {
+		g.subgraphs[key].collectNodes(all)
 	}
 }
 
@@ -419,14 +794,30 @@ func (g *Graph) FindNodes() (nodes []Node) {
 	return foundNodes
 }
 
+// UsesNodeIDs returns true if the (root) graph was created with the NodeIDOption ;
+// then nodes are written by their id instead of n<seq>.
+func (g *Graph) UsesNodeIDs() bool {
+	return g.Root().useNodeIDs
+}
+
 // IsDirected returns info about the graph type
 func (g *Graph) IsDirected() bool {
 	return g.graphType == Directed.Name
 }
 
 // EdgesMap returns a map with Node.id -> []Edge
+// If the graph was created with the Concurrent option then a copy is returned.
 func (g *Graph) EdgesMap() map[string][]Edge {
-	return g.edgesFrom
+	if g.mu == nil {
+		return g.edgesFrom
+	}
+	g.rlock()
+	defer g.runlock()
+	m := make(map[string][]Edge, len(g.edgesFrom))
+	for k, v := range g.edgesFrom {
+		m[k] = append([]Edge(nil), v...)
+	}
+	return m
 }
 
 // HasNode returns whether the node was created in this graph (does not look for it in subgraphs).
@@ -436,6 +827,12 @@ func (g *Graph) HasNode(n Node) bool {
 
 // GetAttributes returns a copy of the attributes.
 func (am *AttributesMap) GetAttributes() map[string]interface{} {
+	am.rlock()
+	defer am.runlock()
+	return am.copyAttributes()
+}
+
+func (am *AttributesMap) copyAttributes() map[string]interface{} {
 	copyMap := make(map[string]interface{}, len(am.attributes))
 	for k, v := range am.attributes {
 		copyMap[k] = v
@@ -444,20 +841,39 @@ func (am *AttributesMap) GetAttributes() map[string]interface{} {
 }
 
 // DeepCopy creates a deep copy of a Graph, including all nodes, edges, subgraphs & attributes
+// The copy is safe for concurrent use if the graph is.
 func (g *Graph) DeepCopy() *Graph {
+	g.rlock()
+	defer g.runlock()
+	var mu *sync.RWMutex
+	if g.mu != nil {
+		mu = new(sync.RWMutex)
+	}
+	return g.deepCopy(mu)
+}
+
+func (g *Graph) deepCopy(mu *sync.RWMutex) *Graph {
 	copy := NewGraph()
+	copy.mu = mu
 	copy.id = g.id
 	copy.isStrict = g.isStrict
 	copy.graphType = g.graphType
 	copy.seq = g.seq
 	copy.parent = g.parent
+	copy.useNodeIDs = g.useNodeIDs
+	copy.edgeSeq = g.edgeSeq
+	copy.index = g.index
+	copy.order = g.order
+	copy.rankOrder = append([]string(nil), g.rankOrder...)
 
-	copy.AttributesMap = AttributesMap{attributes: g.GetAttributes()}
+	copy.AttributesMap = AttributesMap{attributes: g.copyAttributes(), mu: mu}
+	copy.nodeDefaults = AttributesMap{attributes: g.nodeDefaults.copyAttributes(), mu: mu}
+	copy.edgeDefaults = AttributesMap{attributes: g.edgeDefaults.copyAttributes(), mu: mu}
 
 	copy.nodes = make(map[string]Node, len(g.nodes))
 	for id, node := range g.nodes {
 		copy.nodes[id] = Node{
-			AttributesMap: AttributesMap{attributes: node.GetAttributes()},
+			AttributesMap: AttributesMap{attributes: node.copyAttributes(), mu: mu},
 			graph:         copy,
 			id:            node.id,
 			seq:           node.seq,
@@ -469,12 +885,13 @@ func (g *Graph) DeepCopy() *Graph {
 		newEdges := make([]Edge, len(edges))
 		for i, edge := range edges {
 			newEdges[i] = Edge{
-				AttributesMap: AttributesMap{attributes: edge.GetAttributes()},
+				AttributesMap: AttributesMap{attributes: edge.copyAttributes(), mu: mu},
 				graph:         copy,
 				from:          copy.nodes[edge.from.id],
 				to:            copy.nodes[edge.to.id],
 				fromPort:      edge.fromPort,
 				toPort:        edge.toPort,
+				seq:           edge.seq,
 			}
 		}
 		copy.edgesFrom[from] = newEdges
@@ -487,7 +904,7 @@ func (g *Graph) DeepCopy() *Graph {
 	}
 	sort.Strings(keys)
 	for _, id := range keys {
-		newSubgraph := g.subgraphs[id].DeepCopy()
+		newSubgraph := g.subgraphs[id].deepCopy(mu)
 		newSubgraph.parent = copy
 		copy.subgraphs[id] = newSubgraph
 	}
@@ -505,6 +922,10 @@ func (g *Graph) DeepCopy() *Graph {
 		}
 		copy.sameRank[rank] = newNodes
 	}
+	copy.rankTypes = make(map[string]RankType, len(g.rankTypes))
+	for group, rank := range g.rankTypes {
+		copy.rankTypes[group] = rank
+	}
 
 	copy.nodeInitializer = g.nodeInitializer
 	copy.edgeInitializer = g.edgeInitializer
@@ -1,5 +1,7 @@
 package dot
 
+import "sync"
+
 type GraphOption interface {
 	Apply(*Graph)
 }
@@ -28,3 +30,45 @@ func (o GraphTypeOption) Apply(g *Graph) {
 	}
 	g.graphType = o.Name
 }
+
+// NodeIDOption makes the dot and mermaid output use the id of each Node (quoted if needed) as identifier
+// instead of n<seq>. Only for graph and digraph, not for subgraph.
+type NodeIDOption struct{}
+
+func (o NodeIDOption) Apply(g *Graph) {
+	g.useNodeIDs = true
+}
+
+// Concurrent makes it safe to create and change nodes, edges, subgraphs and attributes of the graph
+// from multiple goroutines, and to write the graph while doing so.
+// All subgraphs, nodes and edges share the lock of the graph. Only for graph and digraph, not for subgraph.
+// The node and edge initializers are called without holding the lock.
+var Concurrent = ConcurrentOption{}
+
+type ConcurrentOption struct{}
+
+func (o ConcurrentOption) Apply(g *Graph) {
+	if g.mu != nil {
+		return
+	}
+	g.setMutex(new(sync.RWMutex))
+}
+
+// OrderOption determines the order in which subgraphs, nodes, edges and rank groups are written.
+// Because the layout of Graphviz depends on the order of declaration, use InsertionOrder to keep the order of creation.
+// Only for graph and digraph, not for subgraph.
+type OrderOption struct {
+	Name string
+}
+
+var (
+	// KeyOrder sorts on the (string) keys: node ids, subgraph ids, rank group names and the node id of edges.
+	// Note that "n10" comes before "n2". This is the default.
+	KeyOrder = OrderOption{"key"}
+	// InsertionOrder keeps the order in which subgraphs, nodes, edges and rank groups were created.
+	InsertionOrder = OrderOption{"insertion"}
+)
+
+func (o OrderOption) Apply(g *Graph) {
+	g.order = o.Name
+}
@@ -1,11 +1,16 @@
 package dot
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestEmpty(t *testing.T) {
@@ -553,3 +558,402 @@ func TestDeepCopy(t *testing.T) {
 		}
 	}
 }
+
+// failingWriter accepts limit bytes and then fails.
+type failingWriter struct {
+	limit int
+}
+
+func (f *failingWriter) Write(data []byte) (int, error) {
+	if len(data) > f.limit {
+		n := f.limit
+		f.limit = 0
+		return n, errors.New("disk full")
+	}
+	f.limit -= len(data)
+	return len(data), nil
+}
+
+func TestWriteTo(t *testing.T) {
+	di := NewGraph(Directed)
+	di.Node("A").Edge(di.Node("B"))
+	b := new(bytes.Buffer)
+	n, err := di.WriteTo(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(len(di.String())); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := b.String(), di.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestWriteToFails(t *testing.T) {
+	di := NewGraph(Directed)
+	di.Node("A").Edge(di.Node("B"))
+	n, err := di.WriteTo(&failingWriter{limit: 20})
+	if err == nil || err.Error() != "disk full" {
+		t.Fatalf("expected disk full error, got %v", err)
+	}
+	if got, want := n, int64(20); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestNodeIDOption(t *testing.T) {
+	di := NewGraph(Directed, NodeIDOption{})
+	a := di.Node("A")
+	b := di.Node("my node")
+	c := di.Node(`say "hi"`)
+	d := di.Node("node")
+	e := di.Node("42")
+	di.EdgeWithPorts(a, b, "p", "")
+	c.Edge(d).Edge(e)
+	di.AddToSameRank("row", a, e)
+	want := `digraph  {42[label="42"];A[label="A"];"my node"[label="my node"];"node"[label="node"];"say \"hi\""[label="say \"hi\""];A:p->"my node";"node"->42;"say \"hi\""->"node";{rank=same; A;42;};}`
+	if got := flatten(di.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestNodeIDOptionInSubgraph(t *testing.T) {
+	di := NewGraph(Directed, NodeIDOption{})
+	sub := di.Subgraph("s", ClusterOption{})
+	sub.Node("x").Edge(di.Node("y"))
+	if got, want := flatten(di.String()), `digraph  {subgraph cluster_s1 {label="s";x[label="x"];}y[label="y"];x->y;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestQuoteID(t *testing.T) {
+	for id, want := range map[string]string{
+		"abc":     "abc",
+		"_a1":     "_a1",
+		"-1.5":    "-1.5",
+		".5":      ".5",
+		"1a":      `"1a"`,
+		"a-b":     `"a-b"`,
+		"":        `""`,
+		"Graph":   `"Graph"`,
+		"über":    "über",
+		`a"b`:     `"a\"b"`,
+		"two\nli": "\"two\nli\"",
+		`a\`:      `"a\\"`,
+		`a\"b`:    `"a\\\"b"`,
+		`a\nb`:    `"a\nb"`,
+		`a\\`:     `"a\\\\"`,
+	} {
+		if got := quoteID(id); got != want {
+			t.Errorf("quoteID(%q) got [%v] want [%v]", id, got, want)
+		}
+	}
+}
+
+func TestConcurrentInitializersUseGraph(t *testing.T) {
+	g := NewGraph(Directed, Concurrent)
+	g.NodeInitializer(func(n Node) {
+		n.Attr("shape", "box")
+		if n.ID() != "root" {
+			g.Node("root").Attr("color", "blue")
+		}
+	})
+	g.EdgeInitializer(func(e Edge) {
+		e.From().Attr("color", "red")
+	})
+	done := make(chan bool)
+	go func() {
+		g.Node("x").Edge(g.Node("y"))
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock")
+	}
+	if got, want := flatten(g.String()), `digraph  {n2[color="blue",label="root",shape="box"];n1[color="red",label="x",shape="box"];n3[label="y",shape="box"];n1->n3;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestConcurrentGraph(t *testing.T) {
+	g := NewGraph(Directed, Concurrent)
+	g.NodeInitializer(func(n Node) {
+		n.Attr("shape", "box")
+	})
+	root := g.Node("root")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sub := g.Subgraph(fmt.Sprintf("service-%d", i%3), ClusterOption{})
+			sub.Attr("color", "gray")
+			for j := 0; j < 50; j++ {
+				n := sub.Node(fmt.Sprintf("n-%d-%d", i, j))
+				n.Attr("color", "red")
+				_ = n.Value("color")
+				e := root.Edge(n).Attr("weight", j)
+				_ = e.GetAttributes()
+				_ = root.EdgesTo(n)
+				g.AddToSameRank(fmt.Sprintf("rank-%d", j), n)
+				if j%10 == 0 {
+					_ = g.String()
+					_ = MermaidFlowchart(g, MermaidLeftToRight)
+					_ = g.FindNodes()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got, want := len(g.FindNodes()), 1+8*50; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	count := 0
+	g.WalkEdges(func(e Edge) bool {
+		count++
+		return true
+	})
+	if got, want := count, 8*50; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	copy := g.DeepCopy()
+	if got, want := len(copy.FindNodes()), 1+8*50; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphCommonParentNested(t *testing.T) {
+	di := NewGraph(Directed)
+	outer := di.Subgraph("outer", ClusterOption{})
+	left := outer.Subgraph("left", ClusterOption{})
+	deep := left.Subgraph("deep", ClusterOption{})
+	right := outer.Subgraph("right", ClusterOption{})
+	a := deep.Node("a")
+	b := right.Node("b")
+	c := left.Node("c")
+	e := a.Edge(b)
+	a.Edge(c)
+	if got, want := flatten(di.String()), `digraph  {subgraph cluster_s1 {subgraph cluster_s2 {subgraph cluster_s3 {label="deep";n5[label="a"];}label="left";n7[label="c"];n5->n7;}subgraph cluster_s4 {label="right";n6[label="b"];}label="outer";n5->n6;}}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	for _, each := range []*Graph{di, outer, left, deep, right} {
+		list := each.FindEdges(a, b)
+		if len(list) != 1 || !reflect.DeepEqual(list[0], e) {
+			t.Errorf("FindEdges on %s: got %v", each.GetID(), list)
+		}
+	}
+	if got, want := len(a.EdgesTo(b)), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(e.EdgesTo(c)), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(a.EdgesTo(c)), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphCommonParentOf(t *testing.T) {
+	di := NewGraph(Directed)
+	s1 := di.Subgraph("s1")
+	s11 := s1.Subgraph("s11")
+	s111 := s11.Subgraph("s111")
+	s12 := s1.Subgraph("s12")
+	s2 := di.Subgraph("s2")
+	tests := []struct {
+		one, two, want *Graph
+	}{
+		{s111, s12, s1},
+		{s12, s111, s1},
+		{s111, s11, s11},
+		{s11, s111, s11},
+		{s111, s2, di},
+		{s111, s111, s111},
+		{s1, NewGraph(), di},
+	}
+	for i, each := range tests {
+		if got := commonParentOf(each.one, each.two); got != each.want {
+			t.Errorf("%d: got [%v] want [%v]", i, got.GetID(), each.want.GetID())
+		}
+	}
+}
+
+func TestEdgeOwnerIndependentOfReceiver(t *testing.T) {
+	di := NewGraph(Directed)
+	sub := di.Subgraph("sub")
+	a := sub.Node("a")
+	b := sub.Node("b")
+	di.Edge(a, b)
+	if got, want := len(a.EdgesTo(b)), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(di.FindEdges(a, b)), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestNodeAndEdgeDefaults(t *testing.T) {
+	di := NewGraph(Directed)
+	di.NodeDefaults().Attrs("shape", "box", "fontname", "arial")
+	di.EdgeDefaults().Attr("color", "gray")
+	sub := di.Subgraph("sub", ClusterOption{})
+	sub.NodeDefaults().Attr("shape", "circle")
+	a := di.Node("a")
+	b := sub.Node("b").Attr("fontname", "courier")
+	e := a.Edge(b)
+	if got, want := flatten(di.String()), `digraph  {node[fontname="arial",shape="box"];edge[color="gray"];subgraph cluster_s1 {node[shape="circle"];label="sub";n3[fontname="courier",label="b"];}n2[label="a"];n2->n3;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := a.EffectiveValue("shape"), "box"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := b.EffectiveValue("shape"), "circle"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := b.EffectiveValue("fontname"), "courier"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := b.Value("shape"); got != nil {
+		t.Errorf("got [%v] want nil", got)
+	}
+	if got, want := e.EffectiveValue("color"), "gray"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := e.EffectiveValue("style"); got != nil {
+		t.Errorf("got [%v] want nil", got)
+	}
+}
+
+func TestGraphEffectiveValue(t *testing.T) {
+	di := NewGraph(Directed)
+	di.Attr("fontname", "arial")
+	sub := di.Subgraph("sub")
+	if got, want := sub.EffectiveValue("fontname"), "arial"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := sub.EffectiveValue("label"), "sub"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got := sub.EffectiveValue("color"); got != nil {
+		t.Errorf("got [%v] want nil", got)
+	}
+}
+
+func TestSameRankGroupsSorted(t *testing.T) {
+	di := NewGraph(Directed)
+	a := di.Node("a")
+	b := di.Node("b")
+	c := di.Node("c")
+	di.AddToSameRank("z", a)
+	di.AddToSameRank("y", b)
+	di.AddToSameRank("x", c)
+	want := `digraph  {n1[label="a"];n2[label="b"];n3[label="c"];{rank=same; n3;};{rank=same; n2;};{rank=same; n1;};}`
+	for i := 0; i < 10; i++ {
+		if got := flatten(di.String()); got != want {
+			t.Fatalf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestInsertionOrder(t *testing.T) {
+	di := NewGraph(Directed, InsertionOrder)
+	var nodes []Node
+	for i := 0; i < 11; i++ {
+		nodes = append(nodes, di.Node(fmt.Sprintf("%c", 'k'-i)))
+	}
+	nodes[10].Edge(nodes[0])
+	nodes[0].Edge(nodes[10])
+	nodes[5].Edge(nodes[1])
+	di.Subgraph("z")
+	di.Subgraph("a")
+	di.AddToSameRank("z", nodes[1], nodes[0])
+	di.AddToSameRank("a", nodes[2])
+	want := `digraph  {subgraph s12 {label="z";}subgraph s13 {label="a";}n1[label="k"];n2[label="j"];n3[label="i"];n4[label="h"];n5[label="g"];n6[label="f"];n7[label="e"];n8[label="d"];n9[label="c"];n10[label="b"];n11[label="a"];n11->n1;n1->n11;n6->n2;{rank=same; n2;n1;};{rank=same; n3;};}`
+	if got := flatten(di.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	var walked []string
+	di.WalkEdges(func(e Edge) bool {
+		walked = append(walked, e.From().ID()+e.To().ID())
+		return true
+	})
+	if got, want := strings.Join(walked, ","), "ak,ka,fj"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestKeyOrder(t *testing.T) {
+	di := NewGraph(Directed, KeyOrder)
+	di.Node("b").Edge(di.Node("a"))
+	di.Node("a").Edge(di.Node("b"))
+	if got, want := flatten(di.String()), `digraph  {n2[label="a"];n1[label="b"];n2->n1;n1->n2;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestRankGroups(t *testing.T) {
+	di := NewGraph(Directed, InsertionOrder)
+	a := di.Node("a")
+	b := di.Node("b")
+	c := di.Node("c")
+	d := di.Node("d")
+	di.AddToRank(RankSource, "top", a)
+	di.AddToRank(RankMin, "upper", b)
+	di.AddToRank(RankMax, "lower", c)
+	di.AddToRank(RankSink, "bottom", d)
+	di.AddToSameRank("middle", b, c)
+	if got, want := flatten(di.String()), `digraph  {n1[label="a"];n2[label="b"];n3[label="c"];n4[label="d"];{rank=source; n1;};{rank=min; n2;};{rank=max; n3;};{rank=sink; n4;};{rank=same; n2;n3;};}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	rank, nodes, ok := di.RankGroup("lower")
+	if !ok || rank != RankMax || len(nodes) != 1 || nodes[0].ID() != "c" {
+		t.Errorf("unexpected group %v %v %v", rank, nodes, ok)
+	}
+	if _, _, ok := di.RankGroup("missing"); ok {
+		t.Fail()
+	}
+	if got, want := strings.Join(di.RankGroups(), ","), "top,upper,lower,bottom,middle"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if !di.RemoveRankGroup("upper") || di.RemoveRankGroup("upper") {
+		t.Fail()
+	}
+	if got, want := strings.Join(di.RankGroups(), ","), "top,lower,bottom,middle"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// change type of existing group
+	di.AddToRank(RankMin, "middle")
+	if got, want := flatten(di.String()), `digraph  {n1[label="a"];n2[label="b"];n3[label="c"];n4[label="d"];{rank=source; n1;};{rank=max; n3;};{rank=sink; n4;};{rank=min; n2;n3;};}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestRankGroupInCluster(t *testing.T) {
+	di := NewGraph(Directed, NodeIDOption{})
+	outer := di.Subgraph("outer", ClusterOption{})
+	inner := outer.Subgraph("inner", ClusterOption{})
+	x := inner.Node("x")
+	y := inner.Node("y")
+	inner.AddToRank(RankSame, "row", x, y)
+	outer.AddToRank(RankMin, "first", outer.Node("z"))
+	if got, want := flatten(di.String()), `digraph  {subgraph cluster_s1 {subgraph cluster_s2 {label="inner";x[label="x"];y[label="y"];{rank=same; x;y;};}label="outer";z[label="z"];{rank=min; z;};}}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	copy := di.DeepCopy()
+	if got, want := copy.String(), di.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestUndirectedEdgesInNestedSubgraphs(t *testing.T) {
+	h := NewGraph(Undirected)
+	outer := h.Subgraph("outer", ClusterOption{})
+	inner := outer.Subgraph("inner")
+	h.Edge(outer.Node("a"), inner.Node("b"))
+	h.Edge(inner.Node("b"), inner.Node("c"))
+	if got, want := flatten(h.String()), `graph  {subgraph cluster_s1 {subgraph s2 {label="inner";n4[label="b"];n5[label="c"];n4--n5;}label="outer";n3[label="a"];n3--n4;}}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,502 @@
+package dot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GraphML returns the graph in GraphML notation, e.g. for yEd or Gephi.
+// Subgraphs are written as nested graphs inside a (group) node with id "subgraph:<id>".
+// Attributes are written as data elements with typed keys ; HTML and Literal values are marked
+// with a dot.type on their key such that ReadGraphML can restore them.
+// The node defaults and edge defaults of the graph are written as key defaults.
+func GraphML(g *Graph) string {
+	b := new(bytes.Buffer)
+	WriteGraphML(b, g)
+	return b.String()
+}
+
+// WriteGraphML writes the graph in GraphML notation to w while walking the graph.
+// It returns the number of bytes written and the first error of w, after which it stops writing.
+func WriteGraphML(w io.Writer, g *Graph) (int64, error) {
+	g.rlock()
+	defer g.runlock()
+	gw := &graphmlWriter{out: NewIndentWriter(w), keys: map[graphmlKeyKind]string{}}
+	gw.collectKeys(g)
+	gw.write(g)
+	return gw.out.Written(), gw.out.Err()
+}
+
+// graphmlKeyKind identifies a key by its domain (graph, node or edge), the attribute name and the type of its values.
+type graphmlKeyKind struct {
+	domain, name, kind string
+}
+
+type graphmlWriter struct {
+	out *IndentWriter
+	// keys maps to the key id
+	keys     map[graphmlKeyKind]string
+	defaults map[graphmlKeyKind]interface{}
+	// nodeIDs has the number of nodes per id
+	nodeIDs map[string]int
+	// ports has the port names used by edges per node seq
+	ports map[int]map[string]bool
+}
+
+// graphmlKind returns the GraphML attr.type of a value, or "html" or "literal" for these dot types.
+func graphmlKind(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case int:
+		return "int"
+	case int64:
+		return "long"
+	case float32:
+		return "float"
+	case float64:
+		return "double"
+	case HTML:
+		return "html"
+	case Literal:
+		return "literal"
+	}
+	return "string"
+}
+
+func (w *graphmlWriter) collectKeys(root *Graph) {
+	w.defaults = map[graphmlKeyKind]interface{}{}
+	w.nodeIDs = map[string]int{}
+	w.ports = map[int]map[string]bool{}
+	addPort := func(n Node, port string) {
+		if port == "" {
+			return
+		}
+		if w.ports[n.seq] == nil {
+			w.ports[n.seq] = map[string]bool{}
+		}
+		w.ports[n.seq][port] = true
+	}
+	add := func(domain string, attributes map[string]interface{}) {
+		for name, value := range attributes {
+			w.keys[graphmlKeyKind{domain, name, graphmlKind(value)}] = ""
+		}
+	}
+	for name, value := range root.nodeDefaults.attributes {
+		kind := graphmlKeyKind{"node", name, graphmlKind(value)}
+		w.keys[kind] = ""
+		w.defaults[kind] = value
+	}
+	for name, value := range root.edgeDefaults.attributes {
+		kind := graphmlKeyKind{"edge", name, graphmlKind(value)}
+		w.keys[kind] = ""
+		w.defaults[kind] = value
+	}
+	var walk func(g *Graph)
+	walk = func(g *Graph) {
+		add("graph", g.attributes)
+		for _, each := range g.nodes {
+			add("node", each.attributes)
+			w.nodeIDs[each.id]++
+		}
+		for _, edges := range g.edgesFrom {
+			for _, each := range edges {
+				add("edge", each.attributes)
+				addPort(each.from, each.fromPort)
+				addPort(each.to, each.toPort)
+			}
+		}
+		for _, each := range g.subgraphs {
+			walk(each)
+		}
+	}
+	walk(root)
+	// assign ids ; the string kind gets the shortest id.
+	// Ids can clash, e.g. n_label_int for the string label_int and the int label,
+	// so the kinds are sorted (strings first) and a clashing id gets a number suffix.
+	kinds := []graphmlKeyKind{}
+	for each := range w.keys {
+		kinds = append(kinds, each)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		a, b := kinds[i], kinds[j]
+		if (a.kind == "string") != (b.kind == "string") {
+			return a.kind == "string"
+		}
+		if a.domain != b.domain {
+			return a.domain < b.domain
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.kind < b.kind
+	})
+	used := map[string]bool{}
+	for _, kind := range kinds {
+		id := fmt.Sprintf("%s_%s", kind.domain[:1], kind.name)
+		if kind.kind != "string" {
+			id += "_" + kind.kind
+		}
+		unique := id
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s_%d", id, i)
+		}
+		used[unique] = true
+		w.keys[kind] = unique
+	}
+}
+
+// graphmlNodeID returns the id of the node in GraphML ; n<seq> if the node id is not unique.
+func (w *graphmlWriter) graphmlNodeID(n Node) string {
+	if w.nodeIDs[n.id] > 1 {
+		return fmt.Sprintf("n%d", n.seq)
+	}
+	return n.id
+}
+
+func (w *graphmlWriter) write(g *Graph) {
+	w.out.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	w.out.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://graphml.graphdrawing.org/xmlns http://graphml.graphdrawing.org/xmlns/1.0/graphml.xsd">` + "\n")
+	kinds := []graphmlKeyKind{}
+	for each := range w.keys {
+		kinds = append(kinds, each)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return w.keys[kinds[i]] < w.keys[kinds[j]] })
+	for _, each := range kinds {
+		attrType, dotType := each.kind, ""
+		if each.kind == "html" || each.kind == "literal" {
+			attrType, dotType = "string", fmt.Sprintf(` dot.type="%s"`, each.kind)
+		}
+		fmt.Fprintf(w.out, `  <key id="%s" for="%s" attr.name="%s" attr.type="%s"%s`, xmlEscape(w.keys[each]), each.domain, xmlEscape(each.name), attrType, dotType)
+		if value, ok := w.defaults[each]; ok {
+			fmt.Fprintf(w.out, ">\n    <default>%s</default>\n  </key>\n", xmlEscape(graphmlValue(value)))
+		} else {
+			w.out.WriteString("/>\n")
+		}
+	}
+	edgeDefault := "directed"
+	if g.graphType == Undirected.Name {
+		edgeDefault = "undirected"
+	}
+	id := ""
+	if g.id != "" {
+		id = fmt.Sprintf(` id="%s"`, xmlEscape(g.id))
+	}
+	strict := ""
+	if g.isStrict {
+		strict = ` dot.strict="true"`
+	}
+	fmt.Fprintf(w.out, `  <graph%s edgedefault="%s"%s>`+"\n", id, edgeDefault, strict)
+	w.writeContent(g, edgeDefault, "    ")
+	w.out.WriteString("  </graph>\n</graphml>\n")
+}
+
+// writeContent writes the data, nodes, subgraphs and edges of a graph.
+func (w *graphmlWriter) writeContent(g *Graph, edgeDefault, indent string) {
+	w.writeData("graph", g.attributes, indent)
+	for _, key := range g.orderedNodesKeys() {
+		each := g.nodes[key]
+		fmt.Fprintf(w.out, `%s<node id="%s"`, indent, xmlEscape(w.graphmlNodeID(each)))
+		ports := w.portNames(each)
+		if len(each.attributes) == 0 && len(ports) == 0 {
+			w.out.WriteString("/>\n")
+			continue
+		}
+		w.out.WriteString(">\n")
+		w.writeData("node", each.attributes, indent+"  ")
+		for _, port := range ports {
+			fmt.Fprintf(w.out, `%s  <port name="%s"/>`+"\n", indent, xmlEscape(port))
+		}
+		fmt.Fprintf(w.out, "%s</node>\n", indent)
+	}
+	for _, key := range g.orderedSubgraphsKeys() {
+		each := g.subgraphs[key]
+		cluster := ""
+		if strings.HasPrefix(each.id, "cluster") {
+			cluster = ` dot.cluster="true"`
+		}
+		fmt.Fprintf(w.out, `%s<node id="subgraph:%s" yfiles.foldertype="group">`+"\n", indent, xmlEscape(key))
+		fmt.Fprintf(w.out, `%s  <graph id="%s" edgedefault="%s"%s>`+"\n", indent, xmlEscape(key), edgeDefault, cluster)
+		w.writeContent(each, edgeDefault, indent+"    ")
+		fmt.Fprintf(w.out, "%s  </graph>\n%s</node>\n", indent, indent)
+	}
+	for _, each := range g.orderedEdges() {
+		fmt.Fprintf(w.out, `%s<edge id="e%d" source="%s" target="%s"`, indent, each.seq, xmlEscape(w.graphmlNodeID(each.from)), xmlEscape(w.graphmlNodeID(each.to)))
+		if each.fromPort != "" {
+			fmt.Fprintf(w.out, ` sourceport="%s"`, xmlEscape(each.fromPort))
+		}
+		if each.toPort != "" {
+			fmt.Fprintf(w.out, ` targetport="%s"`, xmlEscape(each.toPort))
+		}
+		if len(each.attributes) == 0 {
+			w.out.WriteString("/>\n")
+			continue
+		}
+		w.out.WriteString(">\n")
+		w.writeData("edge", each.attributes, indent+"  ")
+		fmt.Fprintf(w.out, "%s</edge>\n", indent)
+	}
+}
+
+// portNames returns the sorted names of the ports of the node used by edges, which must be declared in GraphML.
+func (w *graphmlWriter) portNames(n Node) []string {
+	list := []string{}
+	for each := range w.ports[n.seq] {
+		list = append(list, each)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (w *graphmlWriter) writeData(domain string, attributes map[string]interface{}, indent string) {
+	names := []string{}
+	for each := range attributes {
+		names = append(names, each)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := attributes[name]
+		id := w.keys[graphmlKeyKind{domain, name, graphmlKind(value)}]
+		fmt.Fprintf(w.out, `%s<data key="%s">%s</data>`+"\n", indent, xmlEscape(id), xmlEscape(graphmlValue(value)))
+	}
+}
+
+func graphmlValue(value interface{}) string {
+	return fmt.Sprintf("%v", value)
+}
+
+func xmlEscape(s string) string {
+	sb := new(strings.Builder)
+	xml.EscapeText(sb, []byte(s))
+	return sb.String()
+}
+
+type graphmlDocument struct {
+	Keys   []graphmlKey   `xml:"key"`
+	Graphs []graphmlGraph `xml:"graph"`
+}
+
+type graphmlKey struct {
+	ID      string  `xml:"id,attr"`
+	For     string  `xml:"for,attr"`
+	Name    string  `xml:"attr.name,attr"`
+	Type    string  `xml:"attr.type,attr"`
+	DotType string  `xml:"dot.type,attr"`
+	Default *string `xml:"default"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Strict      string        `xml:"dot.strict,attr"`
+	Cluster     string        `xml:"dot.cluster,attr"`
+	Data        []graphmlData `xml:"data"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlNode struct {
+	ID    string        `xml:"id,attr"`
+	Data  []graphmlData `xml:"data"`
+	Graph *graphmlGraph `xml:"graph"`
+}
+
+type graphmlEdge struct {
+	ID         string        `xml:"id,attr"`
+	Source     string        `xml:"source,attr"`
+	Target     string        `xml:"target,attr"`
+	SourcePort string        `xml:"sourceport,attr"`
+	TargetPort string        `xml:"targetport,attr"`
+	Data       []graphmlData `xml:"data"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ReadGraphML reads a Graph from GraphML. Only the first graph of the document is read.
+// Nested graphs become subgraphs ; these are clusters if marked as such by GraphML
+// or if their id starts with "cluster". As with Graph.Node and Graph.Subgraph, the label is the id unless given as data.
+// Data with keys that have no attr.name (e.g. yEd graphics) are ignored.
+func ReadGraphML(r io.Reader, options ...GraphOption) (*Graph, error) {
+	var doc graphmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("graphml: %v", err)
+	}
+	if len(doc.Graphs) == 0 {
+		return nil, fmt.Errorf("graphml: missing graph element")
+	}
+	top := doc.Graphs[0]
+	graphOptions := []GraphOption{Directed}
+	if top.EdgeDefault == "undirected" {
+		graphOptions[0] = Undirected
+	}
+	if top.Strict == "true" {
+		graphOptions = append(graphOptions, Strict)
+	}
+	g := NewGraph(append(graphOptions, options...)...)
+	if top.ID != "" {
+		g.ID(top.ID)
+	}
+	rd := &graphmlReader{keys: map[string]graphmlKey{}, nodes: map[string]Node{}}
+	for _, each := range doc.Keys {
+		rd.keys[each.ID] = each
+		if each.Default == nil || each.Name == "" {
+			continue
+		}
+		value, err := graphmlParseValue(each, *each.Default)
+		if err != nil {
+			return nil, err
+		}
+		switch each.For {
+		case "node":
+			g.NodeDefaults().Attr(each.Name, value)
+		case "edge":
+			g.EdgeDefaults().Attr(each.Name, value)
+		}
+	}
+	if err := rd.readNodes(g, top); err != nil {
+		return nil, err
+	}
+	if err := rd.readEdges(g, top); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+type graphmlReader struct {
+	keys  map[string]graphmlKey
+	nodes map[string]Node
+}
+
+// graphmlParseValue converts the text of a data element to the type of its key.
+func graphmlParseValue(key graphmlKey, text string) (interface{}, error) {
+	var value interface{}
+	var err error
+	switch key.Type {
+	case "boolean":
+		value, err = strconv.ParseBool(strings.TrimSpace(text))
+	case "int":
+		value, err = strconv.Atoi(strings.TrimSpace(text))
+	case "long":
+		value, err = strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(strings.TrimSpace(text), 32)
+		value = float32(f)
+	case "double":
+		value, err = strconv.ParseFloat(strings.TrimSpace(text), 64)
+	default:
+		switch key.DotType {
+		case "html":
+			value = HTML(text)
+		case "literal":
+			value = Literal(text)
+		default:
+			value = text
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("graphml: invalid %s value %q for key %s", key.Type, text, key.ID)
+	}
+	return value, nil
+}
+
+// setData sets the attributes from the data elements ; data without a named key are ignored.
+func (rd *graphmlReader) setData(attributes AttributesMap, data []graphmlData) error {
+	for _, each := range data {
+		key, ok := rd.keys[each.Key]
+		if !ok || key.Name == "" {
+			continue
+		}
+		value, err := graphmlParseValue(key, each.Value)
+		if err != nil {
+			return err
+		}
+		attributes.Attr(key.Name, value)
+	}
+	return nil
+}
+
+// readNodes creates the nodes and subgraphs of a graph element and sets their attributes.
+func (rd *graphmlReader) readNodes(g *Graph, gg graphmlGraph) error {
+	if err := rd.setData(g.AttributesMap, gg.Data); err != nil {
+		return err
+	}
+	for _, each := range gg.Nodes {
+		if each.Graph != nil {
+			id := each.Graph.ID
+			if id == "" {
+				id = strings.TrimPrefix(each.ID, "subgraph:")
+			}
+			var options []GraphOption
+			if each.Graph.Cluster == "true" || strings.HasPrefix(id, "cluster") {
+				options = append(options, ClusterOption{})
+			}
+			sub := g.Subgraph(id, options...)
+			if err := rd.readNodes(sub, *each.Graph); err != nil {
+				return err
+			}
+			continue
+		}
+		n := g.Node(each.ID)
+		if err := rd.setData(n.AttributesMap, each.Data); err != nil {
+			return err
+		}
+		rd.nodes[each.ID] = n
+	}
+	return nil
+}
+
+// collectEdges returns the edges of a graph element and its nested graphs.
+func collectEdges(gg graphmlGraph, list []graphmlEdge) []graphmlEdge {
+	list = append(list, gg.Edges...)
+	for _, each := range gg.Nodes {
+		if each.Graph != nil {
+			list = collectEdges(*each.Graph, list)
+		}
+	}
+	return list
+}
+
+// readEdges creates the edges of a graph element and its nested graphs.
+// Edges are created in the order of their id if all ids are like "e<number>", as written by GraphML.
+// The edges are owned by the innermost graph that contains both nodes.
+func (rd *graphmlReader) readEdges(g *Graph, gg graphmlGraph) error {
+	edges := collectEdges(gg, nil)
+	numbered := true
+	for _, each := range edges {
+		if _, err := strconv.Atoi(strings.TrimPrefix(each.ID, "e")); err != nil || !strings.HasPrefix(each.ID, "e") {
+			numbered = false
+			break
+		}
+	}
+	if numbered {
+		sort.SliceStable(edges, func(i, j int) bool {
+			ni, _ := strconv.Atoi(edges[i].ID[1:])
+			nj, _ := strconv.Atoi(edges[j].ID[1:])
+			return ni < nj
+		})
+	}
+	for _, each := range edges {
+		from, ok := rd.nodes[each.Source]
+		if !ok {
+			return fmt.Errorf("graphml: unknown source node %q", each.Source)
+		}
+		to, ok := rd.nodes[each.Target]
+		if !ok {
+			return fmt.Errorf("graphml: unknown target node %q", each.Target)
+		}
+		e := g.EdgeWithPorts(from, to, each.SourcePort, each.TargetPort)
+		if err := rd.setData(e.AttributesMap, each.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
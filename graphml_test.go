@@ -0,0 +1,191 @@
+package dot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGraphMLRoundTrip(t *testing.T) {
+	g := NewGraph(Directed)
+	g.ID("G")
+	g.Attr("rankdir", "LR")
+	g.NodeDefaults().Attr("shape", "box")
+	c := g.Subgraph("one", ClusterOption{})
+	c.Attr("color", "red")
+	a := c.Node("a").Attr("width", 1.5)
+	b := c.Subgraph("inner").Node("b").Attr("label", HTML("<b>B</b>"))
+	d := g.Node("d").Attr("xlabel", Literal(`"x"`))
+	a.Edge(b, "a<b")
+	g.EdgeWithPorts(b, d, "p1", "p2").Attr("weight", int64(3))
+	first := GraphML(g)
+	back, err := ReadGraphML(strings.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := GraphML(back), first; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := back.GetID(), "G"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphMLClashingKeyIDs(t *testing.T) {
+	g := NewGraph(Directed)
+	g.Node("a").Attr("label_int", "text").Attr("label", 3)
+	out := GraphML(g)
+	for _, each := range []string{
+		`<key id="n_label_int" for="node" attr.name="label_int" attr.type="string"/>`,
+		`<key id="n_label_int_2" for="node" attr.name="label" attr.type="int"/>`,
+	} {
+		if !strings.Contains(out, each) {
+			t.Errorf("missing %s in %s", each, out)
+		}
+	}
+	back, err := ReadGraphML(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := back.FindNodeById("a")
+	if got, want := a.Value("label_int"), "text"; got != want {
+		t.Errorf("got [%v:%T] want [%v:%T]", got, got, want, want)
+	}
+	if got, want := a.Value("label"), 3; got != want {
+		t.Errorf("got [%v:%T] want [%v:%T]", got, got, want, want)
+	}
+	if got, want := GraphML(back), out; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphMLKeysAndData(t *testing.T) {
+	g := NewGraph(Directed)
+	g.NodeDefaults().Attr("shape", "box")
+	c := g.Subgraph("one & two", ClusterOption{})
+	a := c.Node("a").Attr("width", 1.5).Attr("fixedsize", true)
+	b := g.Node("b").Attr("label", HTML("<b>B</b>"))
+	d := g.Node("d").Attr("peripheries", 2)
+	a.Edge(b, "a<b")
+	g.EdgeWithPorts(b, d, "p1", "p2").Attr("weight", int64(3))
+	d.Edge(a).Attr("penwidth", float32(1.5))
+	out := GraphML(g)
+	for _, each := range []string{
+		`<key id="n_shape" for="node" attr.name="shape" attr.type="string">`,
+		`<default>box</default>`,
+		`<key id="n_width_double" for="node" attr.name="width" attr.type="double"/>`,
+		`<key id="n_fixedsize_boolean" for="node" attr.name="fixedsize" attr.type="boolean"/>`,
+		`<key id="n_peripheries_int" for="node" attr.name="peripheries" attr.type="int"/>`,
+		`<key id="e_weight_long" for="edge" attr.name="weight" attr.type="long"/>`,
+		`<key id="e_penwidth_float" for="edge" attr.name="penwidth" attr.type="float"/>`,
+		`<key id="n_label_html" for="node" attr.name="label" attr.type="string" dot.type="html"/>`,
+		`<data key="n_label_html">&lt;b&gt;B&lt;/b&gt;</data>`,
+		`<data key="e_label">a&lt;b</data>`,
+		`<node id="subgraph:one &amp; two" yfiles.foldertype="group">`,
+		`<graph id="one &amp; two" edgedefault="directed" dot.cluster="true">`,
+		`<port name="p1"/>`,
+		`<edge id="e2" source="b" target="d" sourceport="p1" targetport="p2">`,
+	} {
+		if !strings.Contains(out, each) {
+			t.Errorf("missing %s in %s", each, out)
+		}
+	}
+}
+
+func TestReadGraphMLHierarchy(t *testing.T) {
+	in := NewGraph(Directed)
+	c := in.Subgraph("one & two", ClusterOption{})
+	c.Node("a").Attr("width", 1.5).Attr("fixedsize", true)
+	c.Subgraph("inner").Node("b").Attr("label", HTML("<b>B</b>"))
+	g, err := ReadGraphML(strings.NewReader(GraphML(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := g.FindSubgraph("one & two")
+	if !ok {
+		t.Fatal("missing cluster")
+	}
+	if got, want := strings.HasPrefix(c.GetID(), "cluster"), true; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	inner, ok := c.FindSubgraph("inner")
+	if !ok {
+		t.Fatal("missing subgraph")
+	}
+	b, ok := inner.FindNodeById("b")
+	if !ok {
+		t.Fatal("missing node")
+	}
+	if got, want := b.Value("label"), HTML("<b>B</b>"); got != want {
+		t.Errorf("got [%v]:%T want [%v]:%T", got, got, want, want)
+	}
+	a, _ := c.FindNodeById("a")
+	if got, want := a.Value("width"), 1.5; got != want {
+		t.Errorf("got [%v]:%T want [%v]:%T", got, got, want, want)
+	}
+	if got, want := a.Value("fixedsize"), true; got != want {
+		t.Errorf("got [%v]:%T want [%v]:%T", got, got, want, want)
+	}
+}
+
+func TestReadGraphMLForeign(t *testing.T) {
+	// yEd writes graphics in keys without attr.name
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key for="node" id="d0" yfiles.type="nodegraphics"/>
+  <key id="d1" for="node" attr.name="color" attr.type="string"><default>yellow</default></key>
+  <graph id="G" edgedefault="undirected">
+    <node id="n0"><data key="d0"><y:ShapeNode/></data></node>
+    <node id="n1"><data key="d1">blue</data></node>
+    <edge source="n0" target="n1"/>
+  </graph>
+</graphml>`
+	g, err := ReadGraphML(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `graph G {node[color="yellow"];n1[label="n0"];n2[color="blue",label="n1"];n1--n2;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestReadGraphMLErrors(t *testing.T) {
+	for _, each := range []struct {
+		in, err string
+	}{
+		{`<graphml/>`, "graphml: missing graph element"},
+		{`<graphml><graph><edge source="a" target="b"/></graph></graphml>`, `graphml: unknown source node "a"`},
+		{`<graphml><key id="k" for="node" attr.name="w" attr.type="int"/><graph><node id="a"><data key="k">x</data></node></graph></graphml>`, `graphml: invalid int value "x" for key k`},
+	} {
+		_, err := ReadGraphML(strings.NewReader(each.in))
+		if err == nil {
+			t.Fatalf("expected error for %s", each.in)
+		}
+		if got, want := err.Error(), each.err; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	g := NewGraph(Undirected)
+	g.Node("a")
+	buf := new(bytes.Buffer)
+	n, err := WriteGraphML(buf, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(buf.Len()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if !strings.Contains(buf.String(), `<graph edgedefault="undirected">`) {
+		t.Errorf("missing graph in %s", buf.String())
+	}
+	n, err = WriteGraphML(&failingWriter{limit: 10}, g)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if got, want := n, int64(10); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
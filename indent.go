@@ -1,14 +1,17 @@
 package dot
 
 import (
-	"fmt"
 	"io"
+	"strings"
 )
 
-// IndentWriter decorates an io.Writer to insert leading TAB \t character per line
+// IndentWriter decorates an io.Writer to insert leading TAB \t character per line.
+// It keeps the first error returned by the io.Writer ; after that, nothing is written.
 type IndentWriter struct {
 	level  int
 	writer io.Writer
+	count  int64
+	err    error
 }
 
 // NewIndentWriter returns a new IndentWriter with indent level 0.
@@ -19,7 +22,7 @@ func NewIndentWriter(w io.Writer) *IndentWriter {
 // Indent raises the level and writes the extra \t (TAB) character.
 func (i *IndentWriter) Indent() {
 	i.level++
-	fmt.Fprint(i.writer, "\t")
+	i.WriteString("\t")
 }
 
 // BackIndent drops the level with one.
@@ -45,19 +48,31 @@ func (i *IndentWriter) NewLineIndentWhile(block func()) {
 
 // NewLine writes the new line and a number of tab \t characters that matches the level count.
 func (i *IndentWriter) NewLine() {
-	fmt.Fprint(i.writer, "\n")
-	for j := 0; j < i.level; j++ {
-		fmt.Fprint(i.writer, "\t")
-	}
+	i.WriteString("\n" + strings.Repeat("\t", i.level))
 }
 
 // Write makes it an io.Writer
 func (i *IndentWriter) Write(data []byte) (n int, err error) {
-	return i.writer.Write(data)
+	if i.err != nil {
+		return 0, i.err
+	}
+	n, err = i.writer.Write(data)
+	i.count += int64(n)
+	i.err = err
+	return
 }
 
 // WriteString is a convenient Write.
 func (i *IndentWriter) WriteString(s string) (n int, err error) {
-	fmt.Fprint(i.writer, s)
-	return len(s), nil
+	return i.Write([]byte(s))
+}
+
+// Err returns the first error returned by the decorated io.Writer, if any.
+func (i *IndentWriter) Err() error {
+	return i.err
+}
+
+// Written returns the number of bytes written to the decorated io.Writer.
+func (i *IndentWriter) Written() int64 {
+	return i.count
 }
@@ -46,3 +46,15 @@ func TestIndentWriter_IndentWhile(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestIndentWriter_Err(t *testing.T) {
+	i := NewIndentWriter(&failingWriter{limit: 2})
+	i.WriteString("doc")
+	i.NewLine()
+	if i.Err() == nil {
+		t.Fail()
+	}
+	if got, want := i.Written(), int64(2); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
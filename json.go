@@ -0,0 +1,292 @@
+package dot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// jsonGraph is the JSON schema of a Graph ; see MarshalJSON.
+type jsonGraph struct {
+	Name         string          `json:"name,omitempty"`
+	ID           string          `json:"id,omitempty"`
+	Type         string          `json:"type"`
+	Strict       bool            `json:"strict,omitempty"`
+	Index        int             `json:"index,omitempty"`
+	Seq          int             `json:"seq,omitempty"`
+	EdgeSeq      int             `json:"edgeSeq,omitempty"`
+	Order        string          `json:"order,omitempty"`
+	NodeIDs      bool            `json:"nodeIDs,omitempty"`
+	Attributes   jsonAttributes  `json:"attributes,omitempty"`
+	NodeDefaults jsonAttributes  `json:"nodeDefaults,omitempty"`
+	EdgeDefaults jsonAttributes  `json:"edgeDefaults,omitempty"`
+	Nodes        []jsonNode      `json:"nodes,omitempty"`
+	Edges        []jsonEdge      `json:"edges,omitempty"`
+	Subgraphs    []jsonGraph     `json:"subgraphs,omitempty"`
+	RankGroups   []jsonRankGroup `json:"rankGroups,omitempty"`
+}
+
+type jsonNode struct {
+	ID         string         `json:"id"`
+	Seq        int            `json:"seq"`
+	Attributes jsonAttributes `json:"attributes,omitempty"`
+}
+
+type jsonEdge struct {
+	Seq        int            `json:"seq"`
+	From       int            `json:"from"`
+	To         int            `json:"to"`
+	FromPort   string         `json:"fromPort,omitempty"`
+	ToPort     string         `json:"toPort,omitempty"`
+	Attributes jsonAttributes `json:"attributes,omitempty"`
+}
+
+type jsonRankGroup struct {
+	Name  string   `json:"name"`
+	Rank  RankType `json:"rank"`
+	Nodes []int    `json:"nodes"`
+}
+
+// jsonAttributes encodes HTML and Literal values as {"html":..} and {"literal":..} to keep their type.
+type jsonAttributes map[string]interface{}
+
+// MarshalJSON implements json.Marshaler
+func (a jsonAttributes) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{}
+	for k, v := range a {
+		switch t := v.(type) {
+		case HTML:
+			m[k] = map[string]string{"html": string(t)}
+		case Literal:
+			m[k] = map[string]string{"literal": string(t)}
+		case string, bool, int, int64, float32, float64:
+			m[k] = v
+		default:
+			m[k] = fmt.Sprintf("%v", v)
+		}
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *jsonAttributes) UnmarshalJSON(data []byte) error {
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	m := jsonAttributes{}
+	for k, v := range raw {
+		v = bytes.TrimSpace(v)
+		if len(v) == 0 {
+			continue
+		}
+		switch v[0] {
+		case '{':
+			typed := map[string]string{}
+			if err := json.Unmarshal(v, &typed); err != nil {
+				return fmt.Errorf("dot: invalid value of attribute %q: %v", k, err)
+			}
+			if s, ok := typed["html"]; ok {
+				m[k] = HTML(s)
+			} else if s, ok := typed["literal"]; ok {
+				m[k] = Literal(s)
+			} else {
+				return fmt.Errorf("dot: invalid value of attribute %q: expected html or literal", k)
+			}
+		case '"':
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			m[k] = s
+		case 't', 'f':
+			var b bool
+			if err := json.Unmarshal(v, &b); err != nil {
+				return err
+			}
+			m[k] = b
+		default:
+			if i, err := strconv.Atoi(string(v)); err == nil {
+				m[k] = i
+				continue
+			}
+			f, err := strconv.ParseFloat(string(v), 64)
+			if err != nil {
+				return fmt.Errorf("dot: invalid value of attribute %q: %s", k, v)
+			}
+			m[k] = f
+		}
+	}
+	*a = m
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The graph and all its subgraphs are written as:
+//
+//	{
+//	  "id": "G",                          // the id of the graph, empty for a root graph without id
+//	  "type": "digraph",                  // digraph, graph or subgraph
+//	  "strict": true,                     // omitted if false
+//	  "seq": 4, "edgeSeq": 1,             // sequence counters, only for the root graph
+//	  "order": "insertion",               // see OrderOption, only for the root graph
+//	  "nodeIDs": true,                    // see NodeIDOption, only for the root graph
+//	  "attributes": {"label": "x", "fontsize": 12, "xlabel": {"html": "<b>x</b>"}, "tooltip": {"literal": "\"x\""}},
+//	  "nodeDefaults": {"shape": "box"},
+//	  "edgeDefaults": {"color": "red"},
+//	  "nodes": [{"id": "a", "seq": 2, "attributes": {"label": "a"}}],
+//	  "edges": [{"seq": 1, "from": 2, "to": 3, "fromPort": "p", "toPort": "q", "attributes": {}}],
+//	  "subgraphs": [{"name": "one", "id": "cluster_s1", "index": 1, "type": "subgraph", ...}],
+//	  "rankGroups": [{"name": "top", "rank": "same", "nodes": [2, 3]}]
+//	}
+//
+// Edges are listed in the (sub)graph that owns them and refer to nodes by their seq.
+// Attribute values are strings, booleans or numbers ; HTML and Literal values are objects to keep their type.
+// The Concurrent option and the node and edge initializers are not written.
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	g.rlock()
+	defer g.runlock()
+	return json.Marshal(g.jsonGraph(""))
+}
+
+func (g *Graph) jsonGraph(name string) jsonGraph {
+	jg := jsonGraph{
+		Name:         name,
+		ID:           g.id,
+		Type:         g.graphType,
+		Strict:       g.isStrict,
+		Index:        g.index,
+		Attributes:   jsonAttributes(g.attributes),
+		NodeDefaults: jsonAttributes(g.nodeDefaults.attributes),
+		EdgeDefaults: jsonAttributes(g.edgeDefaults.attributes),
+	}
+	if g.parent == nil {
+		jg.Seq, jg.EdgeSeq, jg.Order, jg.NodeIDs = g.seq, g.edgeSeq, g.order, g.useNodeIDs
+	}
+	for _, key := range g.orderedNodesKeys() {
+		each := g.nodes[key]
+		jg.Nodes = append(jg.Nodes, jsonNode{ID: each.id, Seq: each.seq, Attributes: jsonAttributes(each.attributes)})
+	}
+	for _, each := range g.orderedEdges() {
+		jg.Edges = append(jg.Edges, jsonEdge{
+			Seq:        each.seq,
+			From:       each.from.seq,
+			To:         each.to.seq,
+			FromPort:   each.fromPort,
+			ToPort:     each.toPort,
+			Attributes: jsonAttributes(each.attributes),
+		})
+	}
+	for _, key := range g.orderedSubgraphsKeys() {
+		jg.Subgraphs = append(jg.Subgraphs, g.subgraphs[key].jsonGraph(key))
+	}
+	for _, group := range g.orderedRankGroups() {
+		rg := jsonRankGroup{Name: group, Rank: g.rankTypeOf(group), Nodes: []int{}}
+		for _, each := range g.sameRank[group] {
+			rg.Nodes = append(rg.Nodes, each.seq)
+		}
+		jg.RankGroups = append(jg.RankGroups, rg)
+	}
+	return jg
+}
+
+// UnmarshalJSON implements json.Unmarshaler ; see MarshalJSON for the schema.
+// It replaces the content of the graph. The lock of a graph created with the Concurrent option is kept.
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	var jg jsonGraph
+	if err := json.Unmarshal(data, &jg); err != nil {
+		return err
+	}
+	g.lock()
+	defer g.unlock()
+	mu := g.mu
+	parent := g.parent
+	*g = *NewGraph()
+	g.parent = parent
+	g.setMutex(mu)
+	nodes := map[int]Node{}
+	g.fromJSONGraph(jg, nodes)
+	return g.fromJSONEdges(jg, nodes)
+}
+
+// fromJSONGraph sets the fields, attributes, nodes and subgraphs ; nodes is filled by seq.
+func (g *Graph) fromJSONGraph(jg jsonGraph, nodes map[int]Node) {
+	g.id = jg.ID
+	g.graphType = jg.Type
+	if g.graphType == "" {
+		g.graphType = Directed.Name
+	}
+	g.isStrict = jg.Strict
+	g.index = jg.Index
+	g.seq, g.edgeSeq, g.order, g.useNodeIDs = jg.Seq, jg.EdgeSeq, jg.Order, jg.NodeIDs
+	g.AttributesMap.attributes = copyJSONAttributes(jg.Attributes)
+	g.nodeDefaults.attributes = copyJSONAttributes(jg.NodeDefaults)
+	g.edgeDefaults.attributes = copyJSONAttributes(jg.EdgeDefaults)
+	for _, each := range jg.Nodes {
+		n := Node{
+			AttributesMap: AttributesMap{attributes: copyJSONAttributes(each.Attributes), mu: g.mu},
+			graph:         g,
+			id:            each.ID,
+			seq:           each.Seq,
+		}
+		g.nodes[each.ID] = n
+		nodes[each.Seq] = n
+	}
+	for _, each := range jg.Subgraphs {
+		sub := NewGraph(Sub)
+		sub.parent = g
+		sub.setMutex(g.mu)
+		sub.fromJSONGraph(each, nodes)
+		g.subgraphs[each.Name] = sub
+	}
+}
+
+// fromJSONEdges adds the edges and rank groups of the graph and its subgraphs.
+func (g *Graph) fromJSONEdges(jg jsonGraph, nodes map[int]Node) error {
+	for _, each := range jg.Edges {
+		from, ok := nodes[each.From]
+		if !ok {
+			return fmt.Errorf("dot: edge %d has unknown from node %d", each.Seq, each.From)
+		}
+		to, ok := nodes[each.To]
+		if !ok {
+			return fmt.Errorf("dot: edge %d has unknown to node %d", each.Seq, each.To)
+		}
+		e := Edge{
+			AttributesMap: AttributesMap{attributes: copyJSONAttributes(each.Attributes), mu: g.mu},
+			graph:         g,
+			from:          from,
+			to:            to,
+			fromPort:      each.FromPort,
+			toPort:        each.ToPort,
+			seq:           each.Seq,
+		}
+		g.edgesFrom[from.id] = append(g.edgesFrom[from.id], e)
+	}
+	for _, each := range jg.RankGroups {
+		g.rankOrder = append(g.rankOrder, each.Name)
+		g.rankTypes[each.Name] = each.Rank
+		g.sameRank[each.Name] = []Node{}
+		for _, seq := range each.Nodes {
+			n, ok := nodes[seq]
+			if !ok {
+				return fmt.Errorf("dot: rank group %q has unknown node %d", each.Name, seq)
+			}
+			g.sameRank[each.Name] = append(g.sameRank[each.Name], n)
+		}
+	}
+	for _, each := range jg.Subgraphs {
+		if err := g.subgraphs[each.Name].fromJSONEdges(each, nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyJSONAttributes(a jsonAttributes) map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range a {
+		m[k] = v
+	}
+	return m
+}
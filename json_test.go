@@ -0,0 +1,130 @@
+package dot
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGraphJSONRoundTrip(t *testing.T) {
+	g := NewGraph(Directed, Strict, InsertionOrder)
+	g.ID("G")
+	g.Attr("rankdir", "LR")
+	g.NodeDefaults().Attr("shape", "box")
+	g.EdgeDefaults().Attr("color", "red")
+	c := g.Subgraph("one", ClusterOption{})
+	a := c.Node("a").Attr("label", HTML("<b>A</b>"))
+	b := c.Node("b").Attr("xlabel", Literal(`"B"`)).Attr("width", 1.5).Attr("peripheries", 2)
+	x := c.Subgraph("inner").Node("x")
+	g.Node("d")
+	g.DeleteNode("d")
+	e := g.Node("e")
+	g.EdgeWithPorts(a, e, "p1", "p2").Attr("label", "a-e")
+	a.Edge(b)
+	x.Edge(a)
+	c.AddToRank(RankSame, "ab", a, b)
+	g.AddToRank(RankSink, "last", e)
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Graph
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := back.String(), g.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	again, err := json.Marshal(&back)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(again), string(data); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// sequences are kept
+	c, _ = back.FindSubgraph("one")
+	b, _ = c.FindNodeById("b")
+	if got, want := b.Seq(), 3; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := back.Node("f").Seq(), 8; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// types are kept
+	if got, want := b.Value("xlabel"), Literal(`"B"`); got != want {
+		t.Errorf("got [%v]:%T want [%v]:%T", got, got, want, want)
+	}
+	if got, want := b.Value("width"), 1.5; got != want {
+		t.Errorf("got [%v]:%T want [%v]:%T", got, got, want, want)
+	}
+	if got, want := b.Value("peripheries"), 2; got != want {
+		t.Errorf("got [%v]:%T want [%v]:%T", got, got, want, want)
+	}
+	a, _ = c.FindNodeById("a")
+	if got, want := a.Value("label"), HTML("<b>A</b>"); got != want {
+		t.Errorf("got [%v]:%T want [%v]:%T", got, got, want, want)
+	}
+	// edges are linked to the nodes of the graph
+	edges := back.FindEdges(a, b)
+	if got, want := len(edges), 1; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	if got, want := edges[0].From().Seq(), a.Seq(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphJSONSchema(t *testing.T) {
+	g := NewGraph(Directed)
+	a := g.Node("a").Attr("label", HTML("<i>a</i>"))
+	b := g.Node("b")
+	g.EdgeWithPorts(a, b, "p", "")
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `{"type":"digraph","seq":2,"edgeSeq":1,"nodes":[{"id":"a","seq":1,"attributes":{"label":{"html":"\u003ci\u003ea\u003c/i\u003e"}}},{"id":"b","seq":2,"attributes":{"label":"b"}}],"edges":[{"seq":1,"from":1,"to":2,"fromPort":"p"}]}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphJSONConcurrent(t *testing.T) {
+	in := NewGraph(Directed)
+	in.Subgraph("one", ClusterOption{}).Node("a")
+	data, _ := json.Marshal(in)
+	g := NewGraph(Concurrent)
+	if err := json.Unmarshal(data, g); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := g.FindSubgraph("one")
+	done := make(chan bool)
+	go func() {
+		c.Node("a").Attr("color", "red")
+		done <- true
+	}()
+	_ = g.String()
+	<-done
+	if got, want := c.Node("a").Value("color"), "red"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphJSONErrors(t *testing.T) {
+	for _, each := range []struct {
+		in, err string
+	}{
+		{`{"type":"digraph","edges":[{"seq":1,"from":1,"to":2}]}`, "dot: edge 1 has unknown from node 1"},
+		{`{"type":"digraph","rankGroups":[{"name":"r","rank":"same","nodes":[3]}]}`, `dot: rank group "r" has unknown node 3`},
+		{`{"type":"digraph","attributes":{"label":{"xml":"x"}}}`, `dot: invalid value of attribute "label": expected html or literal`},
+	} {
+		var g Graph
+		err := json.Unmarshal([]byte(each.in), &g)
+		if err == nil {
+			t.Fatalf("expected error for %s", each.in)
+		}
+		if got, want := err.Error(), each.err; !strings.Contains(got, want) {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/emicklei/dot"
+)
+
+const (
+	pointsPerInch    = 72.0
+	defaultNodeSep   = 0.25
+	defaultRankSep   = 0.5
+	defaultWidth     = 0.75
+	defaultHeight    = 0.5
+	nodeMargin       = 8.0
+	clusterMargin    = 8.0
+	graphMargin      = 4.0
+	dummyWidth       = 4.0
+	selfLoopDistance = 18.0
+)
+
+// vnode is a node in the layered graph ; either a graph node or a dummy node on a long edge.
+// The u axis is along a rank and the v axis is across the ranks, independent of the rankdir.
+type vnode struct {
+	// layout is nil for a dummy node
+	layout *NodeLayout
+	shape  string
+	// extent along (u) and across (v) the rank
+	uSize, vSize float64
+	class        int
+	rank         int
+	order        int
+	bary         float64
+	u, v         float64
+	// path is the list of clusters that contain the node, outermost first
+	path     []*cluster
+	up, down []*vnode
+	// filler is true for a dummy node that keeps a cluster together on a rank without its nodes
+	filler bool
+}
+
+// cluster is a subgraph with an id starting with "cluster".
+type cluster struct {
+	graph  *dot.Graph
+	parent *cluster
+	depth  int
+	index  int
+	label  []string
+	// label extent along and across the rank
+	labelU, labelV float64
+	// lo and hi are the extent along the ranks, including margins and the space for the label
+	lo, hi float64
+	bounds Rect
+}
+
+// vedge is an edge of the graph with the chain of vnodes it passes, from the lowest rank to the highest.
+type vedge struct {
+	edge       dot.Edge
+	tail, head *vnode
+	minlen     int
+	constraint bool
+	reversed   bool
+	chain      []*vnode
+	label      []string
+	labelW     float64
+	labelH     float64
+	// labelNode is the dummy node that reserves space for the label
+	labelNode *vnode
+}
+
+// rankGroup is a group of nodes with the same rank.
+type rankGroup struct {
+	rank  dot.RankType
+	nodes []dot.Node
+}
+
+type builder struct {
+	graph    *dot.Graph
+	rankdir  string
+	nodeSep  float64
+	rankSep  float64
+	vnodes   []*vnode
+	bySeq    map[int]*vnode
+	edges    []*vedge
+	clusters []*cluster
+	groups   []rankGroup
+	ranks    [][]*vnode
+}
+
+func newBuilder(g *dot.Graph) *builder {
+	rankdir := strings.ToUpper(stringValue(g.Value("rankdir")))
+	if rankdir == "" {
+		rankdir = "TB"
+	}
+	return &builder{
+		graph:   g,
+		rankdir: rankdir,
+		nodeSep: max(0.02, floatValue(g.Value("nodesep"), defaultNodeSep)) * pointsPerInch,
+		rankSep: max(0.02, floatValue(g.Value("ranksep"), defaultRankSep)) * pointsPerInch,
+		bySeq:   map[int]*vnode{},
+	}
+}
+
+// horizontal returns true if ranks are laid out from left to right or right to left.
+func (b *builder) horizontal() bool {
+	return b.rankdir == "LR" || b.rankdir == "RL"
+}
+
+// collect creates the vnodes, vedges, clusters and rank groups from the graph.
+func (b *builder) collect() {
+	for _, each := range b.graph.FindNodes() {
+		nl := &NodeLayout{Node: each}
+		v := &vnode{layout: nl, shape: stringValue(each.EffectiveValue("shape"))}
+		b.sizeNode(v)
+		b.vnodes = append(b.vnodes, v)
+		b.bySeq[each.Seq()] = v
+	}
+	b.collectGraph(b.graph, nil)
+	b.graph.WalkEdges(func(e dot.Edge) bool {
+		tail, head := b.bySeq[e.From().Seq()], b.bySeq[e.To().Seq()]
+		if tail == nil || head == nil {
+			return true
+		}
+		ve := &vedge{
+			edge:       e,
+			tail:       tail,
+			head:       head,
+			minlen:     int(floatValue(e.EffectiveValue("minlen"), 1)),
+			constraint: stringValue(e.EffectiveValue("constraint")) != "false",
+		}
+		if ve.minlen < 0 {
+			ve.minlen = 0
+		}
+		if label := e.EffectiveValue("label"); label != nil {
+			ve.label = labelLines(label, map[byte]string{
+				'E': e.From().ID() + "->" + e.To().ID(),
+				'T': e.From().ID(),
+				'H': e.To().ID(),
+				'G': b.graph.GetID()})
+			ve.labelW, ve.labelH = textSize(ve.label, floatValue(e.EffectiveValue("fontsize"), defaultFontSize))
+		}
+		b.edges = append(b.edges, ve)
+		return true
+	})
+}
+
+// collectGraph collects the clusters and rank groups of the graph and its subgraphs.
+func (b *builder) collectGraph(g *dot.Graph, parent *cluster) {
+	for _, each := range g.RankGroups() {
+		rank, nodes, _ := g.RankGroup(each)
+		b.groups = append(b.groups, rankGroup{rank: rank, nodes: nodes})
+	}
+	for _, sub := range g.Subgraphs() {
+		if !strings.HasPrefix(sub.GetID(), "cluster") {
+			if rank := stringValue(sub.Value("rank")); rank != "" {
+				b.groups = append(b.groups, rankGroup{rank: dot.RankType(rank), nodes: sub.FindNodes()})
+			}
+			b.collectGraph(sub, parent)
+			continue
+		}
+		// like Graphviz, a cluster without nodes is not drawn
+		if len(sub.FindNodes()) == 0 {
+			continue
+		}
+		c := &cluster{graph: sub, parent: parent, index: len(b.clusters)}
+		if parent != nil {
+			c.depth = parent.depth + 1
+		}
+		if label := sub.Value("label"); label != nil {
+			c.label = labelLines(label, map[byte]string{'G': sub.GetID()})
+			w, h := textSize(c.label, floatValue(sub.EffectiveValue("fontsize"), defaultFontSize))
+			c.labelU, c.labelV = w, h
+			if b.horizontal() {
+				c.labelU, c.labelV = h, w
+			}
+		}
+		b.clusters = append(b.clusters, c)
+		// nested clusters are collected later and then overwrite the path of their nodes
+		for _, n := range sub.FindNodes() {
+			if v := b.bySeq[n.Seq()]; v != nil {
+				v.path = clusterPath(c)
+			}
+		}
+		b.collectGraph(sub, c)
+	}
+}
+
+// clusterPath returns the cluster and its parents, outermost first.
+func clusterPath(c *cluster) []*cluster {
+	path := []*cluster{}
+	for each := c; each != nil; each = each.parent {
+		path = append([]*cluster{each}, path...)
+	}
+	return path
+}
+
+// sizeNode computes the size of the node from its label, shape and attributes.
+func (b *builder) sizeNode(v *vnode) {
+	n := v.layout.Node
+	fontSize := floatValue(n.EffectiveValue("fontsize"), defaultFontSize)
+	lines := labelLines(n.EffectiveValue("label"), map[byte]string{'N': n.ID(), 'G': b.graph.GetID()})
+	if v.shape == "record" || v.shape == "mrecord" {
+		lines = recordLines(lines)
+	}
+	tw, th := textSize(lines, fontSize)
+	minW := floatValue(n.EffectiveValue("width"), defaultWidth) * pointsPerInch
+	minH := floatValue(n.EffectiveValue("height"), defaultHeight) * pointsPerInch
+	w, h := tw+2*nodeMargin, th+nodeMargin
+	switch v.shape {
+	case "point":
+		w, h = 0.05*pointsPerInch, 0.05*pointsPerInch
+		if n.EffectiveValue("width") != nil {
+			w, h = minW, minW
+		}
+		minW, minH = w, h
+	case "plaintext", "plain", "none":
+		if v.shape == "plain" {
+			minW, minH = 0, 0
+		}
+	case "ellipse", "oval", "":
+		// the text fits in the inner rectangle of the ellipse
+		w, h = w*1.3, h*1.3
+	case "diamond", "mdiamond":
+		w, h = w*2, h*2
+	case "circle", "doublecircle", "mcircle":
+		d := max(w, h) * 1.2
+		w, h = d, d
+	}
+	if stringValue(n.EffectiveValue("fixedsize")) == "true" {
+		w, h = minW, minH
+	} else {
+		w, h = max(w, minW), max(h, minH)
+	}
+	if v.shape == "circle" || v.shape == "doublecircle" || v.shape == "mcircle" {
+		d := max(w, h)
+		w, h = d, d
+	}
+	v.layout.Width, v.layout.Height = w, h
+	v.uSize, v.vSize = w, h
+	if b.horizontal() {
+		v.uSize, v.vSize = h, w
+	}
+}
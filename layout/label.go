@@ -0,0 +1,143 @@
+package layout
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/dot"
+)
+
+const (
+	defaultFontSize = 14.0
+	// charWidth is the average width of a character relative to the font size
+	charWidth = 0.55
+	// lineHeight is the height of a line relative to the font size
+	lineHeight = 1.2
+)
+
+var (
+	htmlBreakPattern  = regexp.MustCompile(`(?i)<br\s*/?>|</tr>`)
+	htmlTagPattern    = regexp.MustCompile(`<[^>]*>`)
+	recordPortPattern = regexp.MustCompile(`<[^>]*>`)
+)
+
+// labelLines returns the lines of text of a label value.
+// The escapes \N, \G, \E, \T and \H are replaced by the given names.
+// The line breaks \n, \l and \r are applied ; they all center the line.
+func labelLines(value interface{}, names map[byte]string) []string {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		text = `\N`
+	case dot.HTML:
+		text = htmlBreakPattern.ReplaceAllString(string(v), "\n")
+		text = html.UnescapeString(htmlTagPattern.ReplaceAllString(text, ""))
+		return trimLines(strings.Split(text, "\n"))
+	case dot.Literal:
+		text = string(v)
+		if len(text) >= 2 && strings.HasPrefix(text, `"`) && strings.HasSuffix(text, `"`) {
+			text = strings.Replace(text[1:len(text)-1], `\"`, `"`, -1)
+		}
+	case string:
+		text = v
+	default:
+		text = fmt.Sprintf("%v", v)
+	}
+	lines := []string{}
+	line := new(strings.Builder)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c == '\n' {
+			lines = append(lines, line.String())
+			line.Reset()
+			continue
+		}
+		if c != '\\' || i+1 == len(text) {
+			line.WriteByte(c)
+			continue
+		}
+		i++
+		switch next := text[i]; next {
+		case 'n', 'l', 'r':
+			lines = append(lines, line.String())
+			line.Reset()
+		case 'N', 'G', 'E', 'T', 'H':
+			line.WriteString(names[next])
+		default:
+			line.WriteByte(next)
+		}
+	}
+	// a trailing line break does not add an empty line
+	if line.Len() > 0 || len(lines) == 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// trimLines removes surrounding whitespace and blank lines.
+func trimLines(lines []string) []string {
+	list := []string{}
+	for _, each := range lines {
+		if s := strings.TrimSpace(each); len(s) > 0 {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// recordLines returns the lines of the label of a record shape ; each field is put on its own line.
+func recordLines(lines []string) []string {
+	list := []string{}
+	for _, each := range lines {
+		each = recordPortPattern.ReplaceAllString(each, "")
+		each = strings.NewReplacer("{", "", "}", "").Replace(each)
+		for _, field := range strings.Split(each, "|") {
+			list = append(list, strings.TrimSpace(field))
+		}
+	}
+	return list
+}
+
+// textSize returns the estimated width and height of the lines of text.
+func textSize(lines []string, fontSize float64) (float64, float64) {
+	width := 0.0
+	for _, each := range lines {
+		if w := float64(len([]rune(each))) * fontSize * charWidth; w > width {
+			width = w
+		}
+	}
+	return width, float64(len(lines)) * fontSize * lineHeight
+}
+
+// floatValue returns the value as a number or the default if absent or invalid.
+func floatValue(value interface{}, defaultValue float64) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int:
+		return float64(v)
+	case string, dot.Literal:
+		// e.g. ranksep "1.2 equally"
+		fields := strings.Fields(strings.Trim(fmt.Sprintf("%v", v), `"`))
+		if len(fields) == 0 {
+			return defaultValue
+		}
+		if f, err := strconv.ParseFloat(fields[0], 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+// stringValue returns the value as a lowercase string without quotes ; empty if absent.
+func stringValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.ToLower(strings.Trim(fmt.Sprintf("%v", value), `"`))
+}
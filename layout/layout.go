@@ -0,0 +1,121 @@
+// Package layout computes a layered (Sugiyama style) layout of a dot.Graph and renders it as SVG,
+// without the need for a Graphviz installation.
+//
+// The layout takes the graph attributes rankdir, ranksep and nodesep, the node attributes
+// shape, label, width, height, fixedsize and fontsize and the edge attributes minlen and constraint into account.
+// Clusters (subgraphs with an id starting with "cluster") are kept together, other nodes are kept outside
+// and clusters without nodes are left out. Rank groups (see dot.Graph.AddToRank) are respected. Ports are ignored.
+//
+// The layout computed by Graphviz can be read from its -Tplain or -Tjson output using ParsePlain or ParseJSON.
+//
+// Copyright (c) Ernest Micklei. MIT License
+package layout
+
+import (
+	"github.com/emicklei/dot"
+)
+
+// Point is a position in points (1/72 inch). The Y axis grows downwards, as in SVG.
+type Point struct {
+	X, Y float64
+}
+
+// Rect is an axis-aligned rectangle with its top-left corner at X,Y.
+type Rect struct {
+	X, Y, Width, Height float64
+}
+
+// Center returns the center point of the rectangle.
+func (r Rect) Center() Point {
+	return Point{X: r.X + r.Width/2, Y: r.Y + r.Height/2}
+}
+
+// union returns the smallest rectangle that contains both ; an empty rectangle is ignored.
+func (r Rect) union(o Rect) Rect {
+	if r.Width == 0 && r.Height == 0 {
+		return o
+	}
+	if o.Width == 0 && o.Height == 0 {
+		return r
+	}
+	minX, minY := min(r.X, o.X), min(r.Y, o.Y)
+	maxX, maxY := max(r.X+r.Width, o.X+o.Width), max(r.Y+r.Height, o.Y+o.Height)
+	return Rect{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+// NodeLayout is the position and size of a node.
+type NodeLayout struct {
+	Node   dot.Node
+	Center Point
+	Width  float64
+	Height float64
+}
+
+// Bounds returns the rectangle that contains the node.
+func (n *NodeLayout) Bounds() Rect {
+	return Rect{X: n.Center.X - n.Width/2, Y: n.Center.Y - n.Height/2, Width: n.Width, Height: n.Height}
+}
+
+// EdgeLayout is the route of an edge.
+type EdgeLayout struct {
+	Edge dot.Edge
+	// Points is the route from the tail to the head node, clipped at their boundaries.
+	// A route with more than two points is drawn as a smooth curve through all points.
+	Points []Point
+	// Spline is true if Points are the control points of a B-spline, as read from Graphviz output.
+	Spline bool
+	// ArrowHead and ArrowTail are the tips of the arrows if these are beyond the first or last point ;
+	// nil if the route ends at the node boundary.
+	ArrowHead, ArrowTail *Point
+	// LabelPosition is the center of the label, if the edge has one.
+	LabelPosition Point
+}
+
+// ClusterLayout is the position and size of a cluster subgraph.
+type ClusterLayout struct {
+	Graph  *dot.Graph
+	Bounds Rect
+}
+
+// Layout holds the positions of all nodes, edges and clusters of a graph.
+type Layout struct {
+	Graph    *dot.Graph
+	Bounds   Rect
+	Nodes    []*NodeLayout
+	Edges    []*EdgeLayout
+	Clusters []*ClusterLayout
+	// nodes is indexed by dot.Node.Seq
+	nodes map[int]*NodeLayout
+}
+
+// Node returns the layout of a node of the graph.
+func (l *Layout) Node(n dot.Node) (*NodeLayout, bool) {
+	nl, ok := l.nodes[n.Seq()]
+	return nl, ok
+}
+
+// Compute returns the layered layout of the graph.
+// Use ParsePlain or ParseJSON to get the layout computed by Graphviz instead.
+func Compute(g *dot.Graph) *Layout {
+	b := newBuilder(g)
+	b.collect()
+	b.rank()
+	b.insertDummies()
+	b.order()
+	b.position()
+	return b.result()
+}
+
+func min(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,358 @@
+package layout
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/dot"
+)
+
+func nodeLayout(t *testing.T, l *Layout, n dot.Node) *NodeLayout {
+	t.Helper()
+	nl, ok := l.Node(n)
+	if !ok {
+		t.Fatalf("missing layout of node %s", n.ID())
+	}
+	return nl
+}
+
+func TestComputeRanksTopToBottom(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b, c := g.Node("a"), g.Node("b"), g.Node("c")
+	a.Edge(b)
+	b.Edge(c)
+	l := Compute(g)
+	if got, want := len(l.Nodes), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	ya, yb, yc := nodeLayout(t, l, a).Center.Y, nodeLayout(t, l, b).Center.Y, nodeLayout(t, l, c).Center.Y
+	if !(ya < yb && yb < yc) {
+		t.Errorf("expected increasing y, got %v %v %v", ya, yb, yc)
+	}
+	// straight line
+	if got, want := nodeLayout(t, l, a).Center.X, nodeLayout(t, l, c).Center.X; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestComputeRankdir(t *testing.T) {
+	for _, each := range []struct {
+		rankdir string
+		less    func(p, q Point) bool
+	}{
+		{"LR", func(p, q Point) bool { return p.X < q.X }},
+		{"RL", func(p, q Point) bool { return p.X > q.X }},
+		{"BT", func(p, q Point) bool { return p.Y > q.Y }},
+	} {
+		g := dot.NewGraph(dot.Directed)
+		g.Attr("rankdir", each.rankdir)
+		a, b := g.Node("a"), g.Node("b")
+		a.Edge(b)
+		l := Compute(g)
+		if !each.less(nodeLayout(t, l, a).Center, nodeLayout(t, l, b).Center) {
+			t.Errorf("%s: unexpected positions %v %v", each.rankdir, nodeLayout(t, l, a).Center, nodeLayout(t, l, b).Center)
+		}
+	}
+}
+
+func TestComputeCycle(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b, c := g.Node("a"), g.Node("b"), g.Node("c")
+	a.Edge(b)
+	b.Edge(c)
+	c.Edge(a)
+	a.Edge(a)
+	l := Compute(g)
+	if got, want := len(l.Edges), 4; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	for _, each := range l.Edges {
+		if len(each.Points) < 2 {
+			t.Errorf("missing route of %s->%s", each.Edge.From().ID(), each.Edge.To().ID())
+		}
+	}
+	// the route of the reversed edge still goes from tail to head
+	var back *EdgeLayout
+	for _, each := range l.Edges {
+		if each.Edge.From().ID() == "c" {
+			back = each
+		}
+	}
+	if got, want := back.Points[0].Y > back.Points[len(back.Points)-1].Y, true; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestComputeSameRank(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b, c := g.Node("a"), g.Node("b"), g.Node("c")
+	a.Edge(b)
+	b.Edge(c)
+	g.AddToSameRank("top", a, c)
+	l := Compute(g)
+	if got, want := nodeLayout(t, l, c).Center.Y, nodeLayout(t, l, a).Center.Y; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestComputeRankTypes(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b, c, d := g.Node("a"), g.Node("b"), g.Node("c"), g.Node("d")
+	a.Edge(b)
+	b.Edge(c)
+	g.AddToRank(dot.RankSink, "bottom", d)
+	g.AddToRank(dot.RankSource, "top", b)
+	l := Compute(g)
+	if !(nodeLayout(t, l, b).Center.Y < nodeLayout(t, l, a).Center.Y) {
+		t.Errorf("expected source b above a")
+	}
+	if !(nodeLayout(t, l, d).Center.Y > nodeLayout(t, l, c).Center.Y) {
+		t.Errorf("expected sink d below c")
+	}
+}
+
+func TestComputeRankSubgraph(t *testing.T) {
+	g, err := dot.Parse(strings.NewReader(`digraph { a -> b -> c; {rank=same; a; c} }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := g.FindNodeById("a")
+	c, _ := g.FindNodeById("c")
+	l := Compute(g)
+	if got, want := nodeLayout(t, l, c).Center.Y, nodeLayout(t, l, a).Center.Y; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestComputeMinlenAndConstraint(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b, c := g.Node("a"), g.Node("b"), g.Node("c")
+	a.Edge(b).Attr("minlen", 2)
+	a.Edge(c).Attr("constraint", "false")
+	c.Edge(a)
+	l := Compute(g)
+	ya, yb, yc := nodeLayout(t, l, a).Center.Y, nodeLayout(t, l, b).Center.Y, nodeLayout(t, l, c).Center.Y
+	if !(yc < ya) {
+		t.Errorf("expected c above a, got %v %v", yc, ya)
+	}
+	if !(yb-ya > ya-yc) {
+		t.Errorf("expected a longer edge a->b, got %v %v %v", ya, yb, yc)
+	}
+}
+
+func TestComputeNoOverlap(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	root := g.Node("root")
+	for _, each := range []string{"one", "two", "three", "four"} {
+		root.Edge(g.Node(each).Label("a long label for " + each))
+	}
+	l := Compute(g)
+	for i, one := range l.Nodes {
+		for _, two := range l.Nodes[i+1:] {
+			p, q := one.Bounds(), two.Bounds()
+			if p.X < q.X+q.Width && q.X < p.X+p.Width && p.Y < q.Y+q.Height && q.Y < p.Y+p.Height {
+				t.Errorf("nodes %s and %s overlap", one.Node.ID(), two.Node.ID())
+			}
+		}
+	}
+}
+
+func TestComputeCrossings(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b := g.Node("a"), g.Node("b")
+	c, d := g.Node("c"), g.Node("d")
+	a.Edge(d)
+	b.Edge(c)
+	b.Edge(d)
+	a.Edge(d)
+	b1 := newBuilder(g)
+	b1.collect()
+	b1.rank()
+	b1.insertDummies()
+	b1.order()
+	if got, want := b1.crossings(), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestComputeClusters(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	outer := g.Subgraph("outer", dot.ClusterOption{})
+	inner := outer.Subgraph("inner", dot.ClusterOption{})
+	a, b := outer.Node("a"), inner.Node("b")
+	c, d := g.Node("c"), g.Node("d")
+	c.Edge(a)
+	c.Edge(d)
+	d.Edge(b)
+	a.Edge(b)
+	l := Compute(g)
+	if got, want := len(l.Clusters), 2; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	contains := func(r, o Rect) bool {
+		return r.X <= o.X && r.Y <= o.Y && o.X+o.Width <= r.X+r.Width && o.Y+o.Height <= r.Y+r.Height
+	}
+	overlaps := func(r, o Rect) bool {
+		return r.X < o.X+o.Width && o.X < r.X+r.Width && r.Y < o.Y+o.Height && o.Y < r.Y+r.Height
+	}
+	outerBounds, innerBounds := l.Clusters[0].Bounds, l.Clusters[1].Bounds
+	if !contains(outerBounds, innerBounds) {
+		t.Errorf("outer %v does not contain inner %v", outerBounds, innerBounds)
+	}
+	if !contains(outerBounds, nodeLayout(t, l, a).Bounds()) {
+		t.Errorf("outer does not contain a")
+	}
+	if !contains(innerBounds, nodeLayout(t, l, b).Bounds()) {
+		t.Errorf("inner does not contain b")
+	}
+	for _, each := range []dot.Node{c, d} {
+		if overlaps(outerBounds, nodeLayout(t, l, each).Bounds()) {
+			t.Errorf("outer overlaps %s", each.ID())
+		}
+	}
+}
+
+// clusterIntruders returns the nodes that are inside a cluster they are not part of.
+func clusterIntruders(l *Layout) []string {
+	list := []string{}
+	for _, c := range l.Clusters {
+		members := map[int]bool{}
+		for _, n := range c.Graph.FindNodes() {
+			members[n.Seq()] = true
+		}
+		r := c.Bounds
+		for _, each := range l.Nodes {
+			o := each.Bounds()
+			if !members[each.Node.Seq()] && r.X < o.X+o.Width && o.X < r.X+r.Width && r.Y < o.Y+o.Height && o.Y < r.Y+r.Height {
+				list = append(list, fmt.Sprintf("%s in %s", each.Node.ID(), c.Graph.GetID()))
+			}
+		}
+	}
+	return list
+}
+
+func TestComputeClusterWithoutForeignNodes(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	g.Attr("rankdir", "TB")
+	c0 := g.Subgraph("c0", dot.ClusterOption{})
+	n0, n1 := g.Node("n0"), g.Node("n1")
+	n2, l, n4 := c0.Node("n2"), c0.Node("L"), c0.Node("n4")
+	c0.Node("n5")
+	g.Node("n6")
+	n2.Edge(l)
+	n2.Edge(n4).Attr("minlen", "2")
+	l.Edge(n2)
+	n0.Edge(n4)
+	n0.Edge(n4)
+	n0.Edge(l).Attr("constraint", "false").Attr("minlen", "2")
+	n1.Edge(l).Attr("constraint", "false")
+	if got := clusterIntruders(Compute(g)); len(got) > 0 {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestComputeRandomClusters(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	for i := 0; i < 500; i++ {
+		g := dot.NewGraph(dot.Directed)
+		g.Attr("rankdir", []string{"TB", "LR", "BT", "RL"}[i%4])
+		graphs := []*dot.Graph{g, g.Subgraph("a", dot.ClusterOption{}).Label("a longer label"), g.Subgraph("b", dot.ClusterOption{})}
+		graphs = append(graphs, graphs[1].Subgraph("c", dot.ClusterOption{}))
+		nodes := []dot.Node{}
+		for n := 0; n < 3+rnd.Intn(20); n++ {
+			nodes = append(nodes, graphs[rnd.Intn(len(graphs))].Node(fmt.Sprintf("n%d", n)))
+		}
+		for e := 0; e < rnd.Intn(30); e++ {
+			edge := nodes[rnd.Intn(len(nodes))].Edge(nodes[rnd.Intn(len(nodes))])
+			switch rnd.Intn(6) {
+			case 0:
+				edge.Attr("minlen", "2")
+			case 1:
+				edge.Attr("constraint", "false")
+			case 2:
+				edge.Label("e")
+			}
+		}
+		if got := clusterIntruders(Compute(g)); len(got) > 0 {
+			t.Fatalf("graph %d: got %v in %s", i, got, g.String())
+		}
+	}
+}
+
+func TestComputeEdgeLabel(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b := g.Node("a"), g.Node("b")
+	a.Edge(b, "label")
+	l := Compute(g)
+	e := l.Edges[0]
+	if got, want := e.LabelPosition.X > nodeLayout(t, l, a).Center.X, true; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// clipped at the node boundaries
+	if got, want := e.Points[0].Y, nodeLayout(t, l, a).Center.Y+nodeLayout(t, l, a).Height/2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestComputeNodeSize(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a := g.Node("a").Attr("width", "2").Attr("height", "1").Attr("fixedsize", "true")
+	b := g.Node("b").Attr("shape", "circle")
+	l := Compute(g)
+	if got, want := nodeLayout(t, l, a).Width, 144.0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := nodeLayout(t, l, a).Height, 72.0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := nodeLayout(t, l, b).Width, nodeLayout(t, l, b).Height; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestIsotonic(t *testing.T) {
+	got := isotonic([]float64{10, 0, 20}, []float64{0, 10, 20})
+	want := []float64{0, 10, 20}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestComputeRandomGraphs(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	for i := 0; i < 20; i++ {
+		g := dot.NewGraph(dot.Directed)
+		if i%4 == 1 {
+			g.Attr("rankdir", "LR")
+		}
+		graphs := []*dot.Graph{g, g.Subgraph("a", dot.ClusterOption{}), g.Subgraph("b", dot.ClusterOption{})}
+		graphs = append(graphs, graphs[1].Subgraph("c", dot.ClusterOption{}))
+		nodes := []dot.Node{}
+		for n := 0; n < 5+rnd.Intn(20); n++ {
+			nodes = append(nodes, graphs[rnd.Intn(len(graphs))].Node(fmt.Sprintf("n%d", n)))
+		}
+		for e := 0; e < 5+rnd.Intn(30); e++ {
+			from, to := nodes[rnd.Intn(len(nodes))], nodes[rnd.Intn(len(nodes))]
+			from.Edge(to, "e")
+		}
+		g.AddToSameRank("same", nodes[0], nodes[len(nodes)-1])
+		l := Compute(g)
+		if got, want := len(l.Nodes), len(nodes); got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+		for _, each := range l.Nodes {
+			if math.IsNaN(each.Center.X) || math.IsNaN(each.Center.Y) {
+				t.Fatalf("invalid position of %s", each.Node.ID())
+			}
+		}
+	}
+	// empty graph
+	if got, want := len(Compute(dot.NewGraph()).Nodes), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
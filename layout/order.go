@@ -0,0 +1,224 @@
+package layout
+
+import "sort"
+
+// maxOrderIterations is the number of barycenter sweeps to reduce edge crossings.
+const maxOrderIterations = 24
+
+// order sorts the vnodes within each rank to reduce edge crossings while keeping the nodes of a cluster together.
+func (b *builder) order() {
+	b.initialOrder()
+	best, saved := b.crossings(), b.saveOrder()
+	for i := 0; i < maxOrderIterations && best > 0; i++ {
+		if i%2 == 0 {
+			for r := 1; r < len(b.ranks); r++ {
+				b.sortRank(r, true)
+			}
+		} else {
+			for r := len(b.ranks) - 2; r >= 0; r-- {
+				b.sortRank(r, false)
+			}
+		}
+		if c := b.crossings(); c < best {
+			best, saved = c, b.saveOrder()
+		}
+	}
+	b.ranks = saved
+	for _, rank := range b.ranks {
+		for i, v := range rank {
+			v.order = i
+		}
+	}
+	b.orderClusters()
+}
+
+// orderClusters keeps the nodes of a cluster together and gives clusters with the same parent
+// the same order on all ranks, otherwise two such clusters cannot be positioned without overlap.
+// A cluster is placed by the average relative order of its nodes.
+func (b *builder) orderClusters() {
+	sums := map[*cluster]float64{}
+	counts := map[*cluster]int{}
+	for _, rank := range b.ranks {
+		for _, v := range rank {
+			for _, c := range v.path {
+				sums[c] += float64(v.order) / float64(len(rank))
+				counts[c]++
+			}
+		}
+	}
+	less := func(one, two *cluster) bool {
+		k1, k2 := sums[one]/float64(counts[one]), sums[two]/float64(counts[two])
+		if k1 != k2 {
+			return k1 < k2
+		}
+		return one.index < two.index
+	}
+	var arrange func(nodes []*vnode, level int) []*vnode
+	arrange = func(nodes []*vnode, level int) []*vnode {
+		// split in blocks of nodes with the same cluster at this level, at the position of the first node ;
+		// other nodes are a block of their own
+		blocks := [][]*vnode{}
+		slots := []int{}
+		slotOf := map[*cluster]int{}
+		for _, v := range nodes {
+			if level < len(v.path) {
+				if slot, ok := slotOf[v.path[level]]; ok {
+					blocks[slot] = append(blocks[slot], v)
+					continue
+				}
+				slotOf[v.path[level]] = len(blocks)
+				slots = append(slots, len(blocks))
+			}
+			blocks = append(blocks, []*vnode{v})
+		}
+		// put the cluster blocks in order, keeping the positions of the other nodes
+		clustered := [][]*vnode{}
+		for _, each := range slots {
+			clustered = append(clustered, arrange(blocks[each], level+1))
+		}
+		sort.SliceStable(clustered, func(i, j int) bool {
+			return less(clustered[i][0].path[level], clustered[j][0].path[level])
+		})
+		for i, each := range slots {
+			blocks[each] = clustered[i]
+		}
+		result := make([]*vnode, 0, len(nodes))
+		for _, each := range blocks {
+			result = append(result, each...)
+		}
+		return result
+	}
+	for r, rank := range b.ranks {
+		b.ranks[r] = arrange(rank, 0)
+		for i, v := range b.ranks[r] {
+			v.order = i
+		}
+	}
+}
+
+// initialOrder fills the ranks by a depth-first search from the nodes without incoming edges.
+func (b *builder) initialOrder() {
+	maxRank := 0
+	for _, each := range b.vnodes {
+		if each.rank > maxRank {
+			maxRank = each.rank
+		}
+	}
+	b.ranks = make([][]*vnode, maxRank+1)
+	visited := map[*vnode]bool{}
+	var visit func(v *vnode)
+	visit = func(v *vnode) {
+		if visited[v] {
+			return
+		}
+		visited[v] = true
+		v.order = len(b.ranks[v.rank])
+		b.ranks[v.rank] = append(b.ranks[v.rank], v)
+		for _, each := range v.down {
+			visit(each)
+		}
+	}
+	for _, each := range b.vnodes {
+		if len(each.up) == 0 {
+			visit(each)
+		}
+	}
+	for _, each := range b.vnodes {
+		visit(each)
+	}
+	// make clusters contiguous
+	for _, rank := range b.ranks {
+		for _, v := range rank {
+			v.bary = float64(v.order)
+		}
+		b.sortByBary(rank)
+	}
+}
+
+// sortRank sorts a rank by the barycenter of the neighbors in the rank above (or below).
+func (b *builder) sortRank(r int, above bool) {
+	rank := b.ranks[r]
+	for _, v := range rank {
+		neighbors := v.down
+		if above {
+			neighbors = v.up
+		}
+		if len(neighbors) == 0 {
+			v.bary = float64(v.order)
+			continue
+		}
+		sum := 0.0
+		for _, n := range neighbors {
+			sum += float64(n.order)
+		}
+		v.bary = sum / float64(len(neighbors))
+	}
+	b.sortByBary(rank)
+}
+
+// sortByBary sorts the rank by barycenter. Nodes of a cluster are sorted
+// as one block using the average barycenter of the nodes in that cluster.
+func (b *builder) sortByBary(rank []*vnode) {
+	sums := map[*cluster]float64{}
+	counts := map[*cluster]int{}
+	for _, v := range rank {
+		for _, c := range v.path {
+			sums[c] += v.bary
+			counts[c]++
+		}
+	}
+	key := func(v *vnode, level int) (float64, int) {
+		if level < len(v.path) {
+			c := v.path[level]
+			return sums[c] / float64(counts[c]), c.index + 1
+		}
+		return v.bary, 0
+	}
+	sort.SliceStable(rank, func(i, j int) bool {
+		one, two := rank[i], rank[j]
+		level := commonLength(one.path, two.path)
+		k1, t1 := key(one, level)
+		k2, t2 := key(two, level)
+		if k1 != k2 {
+			return k1 < k2
+		}
+		if t1 != t2 {
+			return t1 < t2
+		}
+		return one.bary < two.bary
+	})
+	for i, v := range rank {
+		v.order = i
+	}
+}
+
+// crossings returns the number of edge crossings between all adjacent ranks.
+func (b *builder) crossings() int {
+	count := 0
+	for _, rank := range b.ranks {
+		type pair struct{ top, bottom int }
+		pairs := []pair{}
+		for _, v := range rank {
+			for _, w := range v.down {
+				pairs = append(pairs, pair{v.order, w.order})
+			}
+		}
+		for i := 0; i < len(pairs); i++ {
+			for j := i + 1; j < len(pairs); j++ {
+				p, q := pairs[i], pairs[j]
+				if (p.top < q.top && p.bottom > q.bottom) || (p.top > q.top && p.bottom < q.bottom) {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+func (b *builder) saveOrder() [][]*vnode {
+	saved := make([][]*vnode, len(b.ranks))
+	for i, rank := range b.ranks {
+		saved[i] = append([]*vnode(nil), rank...)
+	}
+	return saved
+}
@@ -0,0 +1,384 @@
+package layout
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/dot"
+)
+
+// matcher finds the nodes, edges and clusters of a graph by the names used in Graphviz output.
+type matcher struct {
+	// nodes are keyed by id if the graph uses node ids, by n<seq> otherwise
+	nodes     map[string]dot.Node
+	edges     map[[2]int][]dot.Edge
+	directed  bool
+	subgraphs map[string]*dot.Graph
+}
+
+func newMatcher(g *dot.Graph) *matcher {
+	m := &matcher{
+		nodes:     map[string]dot.Node{},
+		edges:     map[[2]int][]dot.Edge{},
+		directed:  g.IsDirected(),
+		subgraphs: map[string]*dot.Graph{},
+	}
+	for _, each := range g.FindNodes() {
+		if g.UsesNodeIDs() {
+			m.nodes[each.ID()] = each
+		} else {
+			m.nodes[fmt.Sprintf("n%d", each.Seq())] = each
+		}
+	}
+	g.WalkEdges(func(e dot.Edge) bool {
+		key := [2]int{e.From().Seq(), e.To().Seq()}
+		m.edges[key] = append(m.edges[key], e)
+		return true
+	})
+	var collect func(g *dot.Graph)
+	collect = func(g *dot.Graph) {
+		for _, each := range g.Subgraphs() {
+			m.subgraphs[each.GetID()] = each
+			collect(each)
+		}
+	}
+	collect(g)
+	return m
+}
+
+// node returns the node by its id if the graph uses node ids, by its n<seq> name otherwise.
+func (m *matcher) node(name string) (dot.Node, error) {
+	if n, ok := m.nodes[name]; ok {
+		return n, nil
+	}
+	return dot.Node{}, fmt.Errorf("layout: unknown node %q", name)
+}
+
+// edge returns the next unmatched edge between two nodes.
+// For undirected graphs, the nodes can be in either order.
+func (m *matcher) edge(tail, head dot.Node) (dot.Edge, error) {
+	keys := [][2]int{{tail.Seq(), head.Seq()}}
+	if !m.directed {
+		keys = append(keys, [2]int{head.Seq(), tail.Seq()})
+	}
+	for _, key := range keys {
+		if list := m.edges[key]; len(list) > 0 {
+			m.edges[key] = list[1:]
+			return list[0], nil
+		}
+	}
+	return dot.Edge{}, fmt.Errorf("layout: unknown edge %s -> %s", tail.ID(), head.ID())
+}
+
+func (l *Layout) addNode(nl *NodeLayout) {
+	l.Nodes = append(l.Nodes, nl)
+	l.nodes[nl.Node.Seq()] = nl
+}
+
+// ParsePlain reads the output of Graphviz with -Tplain for the graph and returns the layout
+// with positions in points and the Y axis pointing down, like Compute.
+// The nodes and edges are matched with those of the graph ; the plain format has no clusters.
+// Edge points are the control points of a B-spline.
+func ParsePlain(r io.Reader, g *dot.Graph) (*Layout, error) {
+	m := newMatcher(g)
+	l := &Layout{Graph: g, nodes: map[int]*NodeLayout{}}
+	// height is needed to flip the Y axis
+	height := 0.0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields, err := plainFields(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("layout: line %d: %v", lineNumber, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		values := func(from, to int) ([]float64, error) {
+			if to > len(fields) {
+				return nil, fmt.Errorf("layout: line %d: missing values", lineNumber)
+			}
+			list := []float64{}
+			for _, each := range fields[from:to] {
+				f, err := strconv.ParseFloat(each, 64)
+				if err != nil {
+					return nil, fmt.Errorf("layout: line %d: %v", lineNumber, err)
+				}
+				list = append(list, f*pointsPerInch)
+			}
+			return list, nil
+		}
+		switch fields[0] {
+		case "graph":
+			v, err := values(2, 4)
+			if err != nil {
+				return nil, err
+			}
+			l.Bounds = Rect{Width: v[0], Height: v[1]}
+			height = v[1]
+		case "node":
+			if len(fields) < 6 {
+				return nil, fmt.Errorf("layout: line %d: missing values", lineNumber)
+			}
+			n, err := m.node(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			v, err := values(2, 6)
+			if err != nil {
+				return nil, err
+			}
+			l.addNode(&NodeLayout{Node: n, Center: Point{X: v[0], Y: height - v[1]}, Width: v[2], Height: v[3]})
+		case "edge":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("layout: line %d: missing values", lineNumber)
+			}
+			tail, err := m.node(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			head, err := m.node(fields[2])
+			if err != nil {
+				return nil, err
+			}
+			e, err := m.edge(tail, head)
+			if err != nil {
+				return nil, err
+			}
+			count, err := strconv.Atoi(fields[3])
+			if err != nil {
+				return nil, fmt.Errorf("layout: line %d: %v", lineNumber, err)
+			}
+			v, err := values(4, 4+2*count)
+			if err != nil {
+				return nil, err
+			}
+			el := &EdgeLayout{Edge: e, Spline: true}
+			for i := 0; i < len(v); i += 2 {
+				el.Points = append(el.Points, Point{X: v[i], Y: height - v[i+1]})
+			}
+			// label xl yl style color
+			if rest := fields[4+2*count:]; len(rest) >= 5 {
+				lp, err := values(4+2*count+1, 4+2*count+3)
+				if err != nil {
+					return nil, err
+				}
+				el.LabelPosition = Point{X: lp[0], Y: height - lp[1]}
+			}
+			l.Edges = append(l.Edges, el)
+		case "stop":
+			return l, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// plainFields splits a line of plain output into fields ; quoted fields are unquoted.
+func plainFields(line string) ([]string, error) {
+	fields := []string{}
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '"':
+			sb := new(strings.Builder)
+			i++
+			for {
+				if i >= len(line) {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '"' {
+					sb.WriteByte('"')
+					i += 2
+					continue
+				}
+				if line[i] == '"' {
+					i++
+					break
+				}
+				sb.WriteByte(line[i])
+				i++
+			}
+			fields = append(fields, sb.String())
+		default:
+			start := i
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' && line[i] != '\r' {
+				i++
+			}
+			fields = append(fields, line[start:i])
+		}
+	}
+	return fields, nil
+}
+
+// jsonGraph is the part of the Graphviz -Tjson output that is needed for the layout.
+type jsonGraph struct {
+	BB string `json:"bb"`
+	// SubgraphCount is the number of subgraphs ; these are the first objects
+	SubgraphCount int          `json:"_subgraph_cnt"`
+	Objects       []jsonObject `json:"objects"`
+	Edges         []jsonEdge   `json:"edges"`
+}
+
+type jsonObject struct {
+	ID     int    `json:"_gvid"`
+	Name   string `json:"name"`
+	BB     string `json:"bb"`
+	Pos    string `json:"pos"`
+	Width  string `json:"width"`
+	Height string `json:"height"`
+	// Nodes and Edges are set for subgraphs
+	Nodes []int `json:"nodes"`
+	Edges []int `json:"edges"`
+}
+
+type jsonEdge struct {
+	Tail int    `json:"tail"`
+	Head int    `json:"head"`
+	Pos  string `json:"pos"`
+	LP   string `json:"lp"`
+}
+
+// ParseJSON reads the output of Graphviz with -Tjson (or -Tjson0) for the graph and returns the layout
+// with positions in points and the Y axis pointing down, like Compute.
+// The nodes, edges and clusters are matched with those of the graph.
+// Edge points are the control points of a B-spline.
+func ParseJSON(r io.Reader, g *dot.Graph) (*Layout, error) {
+	var jg jsonGraph
+	if err := json.NewDecoder(r).Decode(&jg); err != nil {
+		return nil, fmt.Errorf("layout: %v", err)
+	}
+	m := newMatcher(g)
+	l := &Layout{Graph: g, nodes: map[int]*NodeLayout{}}
+	bb, err := parseBox(jg.BB)
+	if err != nil {
+		return nil, err
+	}
+	height := bb.Height + bb.Y
+	l.Bounds = Rect{Width: bb.Width, Height: bb.Height}
+	flip := func(p Point) Point { return Point{X: p.X - bb.X, Y: height - p.Y} }
+	byID := map[int]dot.Node{}
+	for _, each := range jg.Objects {
+		if each.ID < jg.SubgraphCount || each.Nodes != nil || each.Edges != nil {
+			// only clusters have a bounding box ; other subgraphs, such as rank groups (%3), are skipped
+			if each.BB == "" {
+				continue
+			}
+			sub, ok := m.subgraphs[each.Name]
+			if !ok {
+				return nil, fmt.Errorf("layout: unknown subgraph %q", each.Name)
+			}
+			box, err := parseBox(each.BB)
+			if err != nil {
+				return nil, err
+			}
+			topLeft := flip(Point{X: box.X, Y: box.Y + box.Height})
+			l.Clusters = append(l.Clusters, &ClusterLayout{Graph: sub, Bounds: Rect{X: topLeft.X, Y: topLeft.Y, Width: box.Width, Height: box.Height}})
+			continue
+		}
+		n, err := m.node(each.Name)
+		if err != nil {
+			return nil, err
+		}
+		byID[each.ID] = n
+		nl := &NodeLayout{Node: n}
+		if each.Pos != "" {
+			p, err := parsePoint(each.Pos)
+			if err != nil {
+				return nil, err
+			}
+			nl.Center = flip(p)
+		}
+		nl.Width = floatValue(each.Width, 0) * pointsPerInch
+		nl.Height = floatValue(each.Height, 0) * pointsPerInch
+		l.addNode(nl)
+	}
+	for _, each := range jg.Edges {
+		tail, ok := byID[each.Tail]
+		if !ok {
+			return nil, fmt.Errorf("layout: unknown tail object %d", each.Tail)
+		}
+		head, ok := byID[each.Head]
+		if !ok {
+			return nil, fmt.Errorf("layout: unknown head object %d", each.Head)
+		}
+		e, err := m.edge(tail, head)
+		if err != nil {
+			return nil, err
+		}
+		el := &EdgeLayout{Edge: e, Spline: true}
+		// a spline is [s,x,y] [e,x,y] followed by the control points ; multiple splines are separated by ;
+		for _, each := range strings.Fields(strings.Replace(each.Pos, ";", " ", -1)) {
+			if strings.HasPrefix(each, "s,") || strings.HasPrefix(each, "e,") {
+				p, err := parsePoint(each[2:])
+				if err != nil {
+					return nil, err
+				}
+				tip := flip(p)
+				if each[0] == 's' {
+					el.ArrowTail = &tip
+				} else {
+					el.ArrowHead = &tip
+				}
+				continue
+			}
+			p, err := parsePoint(each)
+			if err != nil {
+				return nil, err
+			}
+			el.Points = append(el.Points, flip(p))
+		}
+		if each.LP != "" {
+			p, err := parsePoint(each.LP)
+			if err != nil {
+				return nil, err
+			}
+			el.LabelPosition = flip(p)
+		}
+		l.Edges = append(l.Edges, el)
+	}
+	return l, nil
+}
+
+// parsePoint parses "x,y" in points ; a third coordinate is ignored.
+func parsePoint(s string) (Point, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.TrimSpace(s), "!"), ",")
+	if len(parts) < 2 {
+		return Point{}, fmt.Errorf("layout: invalid point %q", s)
+	}
+	x, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("layout: invalid point %q", s)
+	}
+	y, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("layout: invalid point %q", s)
+	}
+	return Point{X: x, Y: y}, nil
+}
+
+// parseBox parses "llx,lly,urx,ury" in points into a Rect with X,Y at the lower left corner.
+func parseBox(s string) (Rect, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return Rect{}, fmt.Errorf("layout: invalid bounding box %q", s)
+	}
+	v := make([]float64, 4)
+	for i, each := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(each), 64)
+		if err != nil {
+			return Rect{}, fmt.Errorf("layout: invalid bounding box %q", s)
+		}
+		v[i] = f
+	}
+	return Rect{X: v[0], Y: v[1], Width: v[2] - v[0], Height: v[3] - v[1]}, nil
+}
@@ -0,0 +1,197 @@
+package layout
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/dot"
+)
+
+// fixtureGraph is the graph for which the output in testdata was produced.
+func fixtureGraph() (*dot.Graph, []dot.Node) {
+	g := dot.NewGraph(dot.Directed)
+	g.ID("G")
+	c := g.Subgraph("one", dot.ClusterOption{})
+	a, b := c.Node("a"), c.Node("b")
+	d := g.Node("d e")
+	a.Edge(b, "ab")
+	b.Edge(d)
+	a.Edge(d)
+	return g, []dot.Node{a, b, d}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func TestParsePlain(t *testing.T) {
+	g, nodes := fixtureGraph()
+	f, err := os.Open("testdata/graph.plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l, err := ParsePlain(f, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(l.Nodes), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	if !near(l.Bounds.Width, 88) || !near(l.Bounds.Height, 220) {
+		t.Errorf("unexpected bounds %v", l.Bounds)
+	}
+	a := nodeLayout(t, l, nodes[0])
+	if !near(a.Center.X, 52) || !near(a.Center.Y, 220-194) {
+		t.Errorf("unexpected center %v", a.Center)
+	}
+	if got, want := a.Width, 54.0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(l.Edges), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	ab := l.Edges[0]
+	if got, want := ab.Edge.To().ID(), "b"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(ab.Points), 4; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if !near(ab.LabelPosition.X, 62) {
+		t.Errorf("unexpected label position %v", ab.LabelPosition)
+	}
+	if got, want := len(l.Edges[2].Points), 7; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := l.Edges[2].Spline, true; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	g, nodes := fixtureGraph()
+	f, err := os.Open("testdata/graph.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l, err := ParseJSON(f, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(l.Nodes), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	d := nodeLayout(t, l, nodes[2])
+	if got, want := d.Center, (Point{X: 27, Y: 202}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(l.Clusters), 1; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	if got, want := l.Clusters[0].Bounds, (Rect{X: 8, Y: 8, Width: 72, Height: 132}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := l.Clusters[0].Graph.GetID(), "cluster_s1"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(l.Edges), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	ad := l.Edges[2]
+	if got, want := ad.Edge.From().ID()+ad.Edge.To().ID(), "ad e"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(ad.Points), 7; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := l.Edges[0].LabelPosition, (Point{X: 60.5, Y: 76}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// the parsed layout can be written as SVG
+	sb := new(strings.Builder)
+	if _, err := l.WriteSVG(sb); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), `<path d="M70,52.8 C81.2,61.7 86,77 86,114 C86,151 66,179.6 50.9,191"`) {
+		t.Errorf("missing spline in %s", sb.String())
+	}
+	if !strings.Contains(sb.String(), `<polygon points="44.9,186.6`) {
+		t.Errorf("missing arrow in %s", sb.String())
+	}
+}
+
+func TestParseJSONRankGroup(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a, b, c := g.Node("a"), g.Node("b"), g.Node("c")
+	a.Edge(b)
+	a.Edge(c)
+	g.AddToSameRank("bottom", b, c)
+	f, err := os.Open("testdata/rank.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	l, err := ParseJSON(f, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(l.Nodes), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(l.Clusters), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := nodeLayout(t, l, c).Center, (Point{X: 99, Y: 90}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseUnknownNode(t *testing.T) {
+	g, _ := fixtureGraph()
+	_, err := ParsePlain(strings.NewReader("graph 1 1 1\nnode n99 0 0 1 1 x solid ellipse black lightgrey\n"), g)
+	if got, want := err.Error(), `layout: unknown node "n99"`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseNodeIDs(t *testing.T) {
+	g := dot.NewGraph(dot.Directed, dot.NodeIDOption{})
+	a, b := g.Node("a"), g.Node("b")
+	a.Edge(b)
+	l, err := ParsePlain(strings.NewReader("graph 1 2 2\nnode a 1 1.5 0.75 0.5 a solid ellipse black lightgrey\nnode b 1 0.5 0.75 0.5 b solid ellipse black lightgrey\nedge a b 4 1 1.25 1 1 1 1 1 0.75 solid black\nstop\n"), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nodeLayout(t, l, b).Center, (Point{X: 72, Y: 108}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseNodeIDsLikeSeq(t *testing.T) {
+	g := dot.NewGraph(dot.Directed, dot.NodeIDOption{})
+	n2, x := g.Node("n2"), g.Node("x")
+	l, err := ParsePlain(strings.NewReader("graph 1 2 2\nnode n2 1 1.5 0.75 0.5 n2 solid ellipse black lightgrey\nnode x 1 0.5 0.75 0.5 x solid ellipse black lightgrey\nstop\n"), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := nodeLayout(t, l, n2).Center, (Point{X: 72, Y: 36}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := nodeLayout(t, l, x).Center, (Point{X: 72, Y: 108}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestPlainFields(t *testing.T) {
+	fields, err := plainFields(`node "a \"b\"" 1 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(fields, "|"), `node|a "b"|1|2`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,510 @@
+package layout
+
+import (
+	"math"
+)
+
+// maxPositionIterations is the number of sweeps to align nodes with their neighbors.
+const maxPositionIterations = 8
+
+// position assigns the u (along rank) and v (across ranks) coordinates of all vnodes.
+func (b *builder) position() {
+	// pack each rank from left to right
+	for _, rank := range b.ranks {
+		u := 0.0
+		for i, v := range rank {
+			if i > 0 {
+				u += b.separation(rank[i-1], v)
+			}
+			v.u = u
+		}
+	}
+	for i := 0; i < maxPositionIterations; i++ {
+		if i%2 == 0 {
+			for r := 1; r < len(b.ranks); r++ {
+				b.alignRank(b.ranks[r], true, i > 0)
+			}
+		} else {
+			for r := len(b.ranks) - 2; r >= 0; r-- {
+				b.alignRank(b.ranks[r], false, true)
+			}
+		}
+	}
+	b.separateClusters()
+	b.positionRanks()
+}
+
+// separation returns the minimum distance between the centers of two neighbors in a rank.
+func (b *builder) separation(left, right *vnode) float64 {
+	common := commonLength(left.path, right.path)
+	crossed := len(left.path) - common + len(right.path) - common
+	return (left.uSize+right.uSize)/2 + b.nodeSep + float64(crossed)*clusterMargin
+}
+
+// alignRank moves the vnodes of a rank towards the average position of their neighbors,
+// keeping the order and separation of the rank.
+func (b *builder) alignRank(rank []*vnode, above, both bool) {
+	if len(rank) == 0 {
+		return
+	}
+	desired := make([]float64, len(rank))
+	for i, v := range rank {
+		neighbors := v.down
+		if above {
+			neighbors = v.up
+		}
+		if both {
+			neighbors = append(append([]*vnode{}, v.up...), v.down...)
+		}
+		if len(neighbors) == 0 {
+			desired[i] = v.u
+			continue
+		}
+		sum := 0.0
+		for _, n := range neighbors {
+			sum += n.u
+		}
+		desired[i] = sum / float64(len(neighbors))
+	}
+	offsets := make([]float64, len(rank))
+	for i := 1; i < len(rank); i++ {
+		offsets[i] = offsets[i-1] + b.separation(rank[i-1], rank[i])
+	}
+	for i, u := range isotonic(desired, offsets) {
+		rank[i].u = u
+	}
+}
+
+// isotonic returns the positions closest (least squares) to the desired positions
+// such that each position is at least the offset difference apart from its predecessor.
+// It uses the pool adjacent violators algorithm.
+func isotonic(desired, offsets []float64) []float64 {
+	type block struct {
+		sum   float64
+		count int
+	}
+	mean := func(b block) float64 { return b.sum / float64(b.count) }
+	blocks := []block{}
+	for i := range desired {
+		blocks = append(blocks, block{sum: desired[i] - offsets[i], count: 1})
+		for len(blocks) > 1 && mean(blocks[len(blocks)-2]) > mean(blocks[len(blocks)-1]) {
+			last := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			blocks[len(blocks)-1].sum += last.sum
+			blocks[len(blocks)-1].count += last.count
+		}
+	}
+	result := make([]float64, 0, len(desired))
+	for _, each := range blocks {
+		m := mean(each)
+		for i := 0; i < each.count; i++ {
+			result = append(result, m+offsets[len(result)])
+		}
+	}
+	return result
+}
+
+// separateClusters moves vnodes such that no vnode is inside a cluster it is not part of.
+// It solves the constraints on the u coordinates of the vnodes and the lo and hi of the clusters:
+// the separation of neighbors in a rank, clusters contain their nodes and nested clusters with a margin,
+// and the neighbors of the nodes of a cluster in a rank are outside the cluster.
+// The constraints go from left to right such that the vnodes only move to the right.
+func (b *builder) separateClusters() {
+	if len(b.clusters) == 0 {
+		return
+	}
+	n := len(b.vnodes)
+	index := map[*vnode]int{}
+	values := make([]float64, n+2*len(b.clusters))
+	for i, v := range b.vnodes {
+		index[v] = i
+		values[i] = v.u
+	}
+	lo := func(c *cluster) int { return n + 2*c.index }
+	hi := func(c *cluster) int { return n + 2*c.index + 1 }
+	type constraint struct {
+		to  int
+		gap float64
+	}
+	after := make([][]constraint, len(values))
+	incoming := make([]int, len(values))
+	add := func(from, to int, gap float64) {
+		after[from] = append(after[from], constraint{to, gap})
+		incoming[to]++
+	}
+	for _, c := range b.clusters {
+		values[lo(c)], values[hi(c)] = math.Inf(-1), math.Inf(-1)
+		if !b.horizontal() {
+			add(lo(c), hi(c), c.labelU+2*clusterMargin)
+		}
+		if c.parent != nil {
+			add(lo(c.parent), lo(c), clusterMargin+b.labelSpace(c.parent))
+			add(hi(c), hi(c.parent), clusterMargin)
+		}
+		for _, v := range b.vnodes {
+			if !v.filler && len(v.path) == c.depth+1 && v.path[c.depth] == c {
+				add(lo(c), index[v], v.uSize/2+clusterMargin+b.labelSpace(c))
+				add(index[v], hi(c), v.uSize/2+clusterMargin)
+			}
+		}
+	}
+	for _, rank := range b.ranks {
+		for i := 1; i < len(rank); i++ {
+			add(index[rank[i-1]], index[rank[i]], b.separation(rank[i-1], rank[i]))
+		}
+		for _, c := range b.clusters {
+			first, last := -1, -1
+			for i, v := range rank {
+				if inCluster(v, c) {
+					if first == -1 {
+						first = i
+					}
+					last = i
+				}
+			}
+			if first > 0 {
+				add(index[rank[first-1]], lo(c), rank[first-1].uSize/2+clusterMargin)
+			}
+			if last != -1 && last < len(rank)-1 {
+				add(hi(c), index[rank[last+1]], rank[last+1].uSize/2+clusterMargin)
+			}
+		}
+	}
+	// longest paths in topological order
+	queue := []int{}
+	for i := range values {
+		if incoming[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, each := range after[from] {
+			values[each.to] = max(values[each.to], values[from]+each.gap)
+			incoming[each.to]--
+			if incoming[each.to] == 0 {
+				queue = append(queue, each.to)
+			}
+		}
+	}
+	for i, v := range b.vnodes {
+		v.u = values[i]
+	}
+	// lo is as far to the right as its constraints allow ; nested clusters come after their parent
+	for i := len(b.clusters) - 1; i >= 0; i-- {
+		c := b.clusters[i]
+		c.hi = values[hi(c)]
+		c.lo = math.Inf(1)
+		if !b.horizontal() {
+			c.lo = c.hi - c.labelU - 2*clusterMargin
+		}
+		for _, v := range b.vnodes {
+			if !v.filler && len(v.path) == c.depth+1 && v.path[c.depth] == c {
+				c.lo = min(c.lo, v.u-v.uSize/2-clusterMargin-b.labelSpace(c))
+			}
+		}
+		for _, other := range b.clusters[i+1:] {
+			if other.parent == c {
+				c.lo = min(c.lo, other.lo-clusterMargin-b.labelSpace(c))
+			}
+		}
+	}
+}
+
+// labelSpace returns the space for the label of a cluster before its nodes in a rank ;
+// the label is above the nodes unless the ranks are horizontal.
+func (b *builder) labelSpace(c *cluster) float64 {
+	if b.horizontal() {
+		return c.labelU
+	}
+	return 0
+}
+
+// positionRanks assigns the v coordinate of each rank, with room for cluster labels and margins
+// and for the labels of edges between adjacent ranks.
+func (b *builder) positionRanks() {
+	n := len(b.ranks)
+	heights := make([]float64, n)
+	for r, rank := range b.ranks {
+		for _, v := range rank {
+			heights[r] = max(heights[r], v.vSize)
+		}
+	}
+	// space needed above and below a rank for the clusters that start or end there,
+	// including that of nested clusters that start or end on the same rank ; nested clusters come after their parent
+	above, below := make([]float64, n), make([]float64, n)
+	type span struct {
+		first, last int
+		top, bottom float64
+	}
+	spans := map[*cluster]span{}
+	for i := len(b.clusters) - 1; i >= 0; i-- {
+		c := b.clusters[i]
+		s := span{first: -1, last: -1}
+		for _, v := range b.vnodes {
+			if inCluster(v, c) {
+				if s.first == -1 || v.rank < s.first {
+					s.first = v.rank
+				}
+				if v.rank > s.last {
+					s.last = v.rank
+				}
+			}
+		}
+		if s.first == -1 {
+			continue
+		}
+		for _, other := range b.clusters[i+1:] {
+			if o, ok := spans[other]; ok && other.parent == c {
+				if o.first == s.first {
+					s.top = max(s.top, o.top)
+				}
+				if o.last == s.last {
+					s.bottom = max(s.bottom, o.bottom)
+				}
+			}
+		}
+		s.top += clusterMargin
+		s.bottom += clusterMargin
+		if !b.horizontal() {
+			s.top += c.labelV
+		} else if extra := c.labelV - float64(s.last-s.first)*b.rankSep; extra > 0 {
+			// the label is along the cluster which can be too short ; the ranks are at least rankSep apart
+			s.top += extra / 2
+			s.bottom += extra / 2
+		}
+		spans[c] = s
+		above[s.first] = max(above[s.first], s.top)
+		below[s.last] = max(below[s.last], s.bottom)
+	}
+	labels := make([]float64, n)
+	for _, e := range b.edges {
+		if len(e.label) > 0 && len(e.chain) == 2 && e.chain[0].rank != e.chain[1].rank {
+			r := e.chain[0].rank
+			labels[r] = max(labels[r], b.labelV(e))
+		}
+	}
+	v := 0.0
+	for r := range b.ranks {
+		if r == 0 {
+			v = above[0] + heights[0]/2
+		} else {
+			gap := max(b.rankSep, below[r-1]+above[r]+clusterMargin) + labels[r-1]
+			v += heights[r-1]/2 + gap + heights[r]/2
+		}
+		for _, each := range b.ranks[r] {
+			each.v = v
+		}
+	}
+}
+
+// inCluster returns true if the vnode is part of the cluster or one of its nested clusters.
+func inCluster(v *vnode, c *cluster) bool {
+	return c.depth < len(v.path) && v.path[c.depth] == c
+}
+
+// clusterBounds computes the bounds of all clusters in u,v coordinates, innermost first.
+func (b *builder) clusterBounds() {
+	for i := len(b.clusters) - 1; i >= 0; i-- {
+		c := b.clusters[i]
+		bounds := Rect{}
+		for _, v := range b.vnodes {
+			if inCluster(v, c) {
+				bounds = bounds.union(Rect{X: v.u - v.uSize/2, Y: v.v - v.vSize/2, Width: v.uSize, Height: v.vSize})
+			}
+		}
+		// nested clusters come after their parent
+		for _, other := range b.clusters[i+1:] {
+			if other.parent == c {
+				bounds = bounds.union(other.bounds)
+			}
+		}
+		// the extent along the ranks is computed by separateClusters
+		bounds = Rect{X: c.lo, Y: bounds.Y - clusterMargin, Width: c.hi - c.lo, Height: bounds.Height + 2*clusterMargin}
+		if b.horizontal() {
+			if extra := c.labelV + 2*clusterMargin - bounds.Height; extra > 0 {
+				bounds.Y -= extra / 2
+				bounds.Height += extra
+			}
+		} else {
+			bounds.Y -= c.labelV
+			bounds.Height += c.labelV
+		}
+		c.bounds = bounds
+	}
+}
+
+// transform maps a u,v coordinate to x,y according to the rankdir.
+func (b *builder) transform(u, v float64) Point {
+	switch b.rankdir {
+	case "BT":
+		return Point{X: u, Y: -v}
+	case "LR":
+		return Point{X: v, Y: u}
+	case "RL":
+		return Point{X: -v, Y: u}
+	}
+	return Point{X: u, Y: v}
+}
+
+func (b *builder) transformRect(r Rect) Rect {
+	p, q := b.transform(r.X, r.Y), b.transform(r.X+r.Width, r.Y+r.Height)
+	return Rect{X: min(p.X, q.X), Y: min(p.Y, q.Y), Width: math.Abs(q.X - p.X), Height: math.Abs(q.Y - p.Y)}
+}
+
+// result creates the Layout from the positioned vnodes.
+func (b *builder) result() *Layout {
+	b.clusterBounds()
+	l := &Layout{Graph: b.graph, nodes: map[int]*NodeLayout{}}
+	for _, v := range b.vnodes {
+		if v.layout == nil {
+			continue
+		}
+		v.layout.Center = b.transform(v.u, v.v)
+		l.Nodes = append(l.Nodes, v.layout)
+		l.nodes[v.layout.Node.Seq()] = v.layout
+	}
+	for _, c := range b.clusters {
+		l.Clusters = append(l.Clusters, &ClusterLayout{Graph: c.graph, Bounds: b.transformRect(c.bounds)})
+	}
+	parallel := map[[2]*vnode]int{}
+	for _, e := range b.edges {
+		l.Edges = append(l.Edges, b.route(e, parallel))
+	}
+	// translate everything such that the top-left corner is at the margin
+	bounds := Rect{}
+	for _, each := range l.Nodes {
+		bounds = bounds.union(each.Bounds())
+	}
+	for _, each := range l.Clusters {
+		bounds = bounds.union(each.Bounds)
+	}
+	for i, each := range l.Edges {
+		for _, p := range each.Points {
+			bounds = bounds.union(Rect{X: p.X, Y: p.Y, Width: 0.01, Height: 0.01})
+		}
+		if e := b.edges[i]; len(e.label) > 0 {
+			p := each.LabelPosition
+			bounds = bounds.union(Rect{X: p.X - e.labelW/2, Y: p.Y - e.labelH/2, Width: e.labelW, Height: e.labelH})
+		}
+	}
+	dx, dy := graphMargin-bounds.X, graphMargin-bounds.Y
+	for _, each := range l.Nodes {
+		each.Center = Point{X: each.Center.X + dx, Y: each.Center.Y + dy}
+	}
+	for _, each := range l.Clusters {
+		each.Bounds.X += dx
+		each.Bounds.Y += dy
+	}
+	for _, each := range l.Edges {
+		for i, p := range each.Points {
+			each.Points[i] = Point{X: p.X + dx, Y: p.Y + dy}
+		}
+		each.LabelPosition = Point{X: each.LabelPosition.X + dx, Y: each.LabelPosition.Y + dy}
+	}
+	l.Bounds = Rect{Width: bounds.Width + 2*graphMargin, Height: bounds.Height + 2*graphMargin}
+	if label := b.graph.Value("label"); label != nil {
+		w, h := textSize(labelLines(label, map[byte]string{'G': b.graph.GetID()}), floatValue(b.graph.Value("fontsize"), defaultFontSize))
+		l.Bounds.Width = max(l.Bounds.Width, w+2*graphMargin)
+		l.Bounds.Height += h + graphMargin
+	}
+	return l
+}
+
+// route computes the points and label position of an edge.
+func (b *builder) route(e *vedge, parallel map[[2]*vnode]int) *EdgeLayout {
+	el := &EdgeLayout{Edge: e.edge}
+	if e.tail == e.head {
+		n := e.tail.layout
+		right := n.Center.X + n.Width/2
+		el.Points = []Point{
+			{X: right - 2, Y: n.Center.Y - n.Height/4},
+			{X: right + selfLoopDistance, Y: n.Center.Y - n.Height/2},
+			{X: right + selfLoopDistance, Y: n.Center.Y + n.Height/2},
+			{X: right - 2, Y: n.Center.Y + n.Height/4},
+		}
+		el.LabelPosition = Point{X: right + selfLoopDistance + e.labelW/2 + 4, Y: n.Center.Y}
+		return el
+	}
+	type uv struct{ u, v float64 }
+	points := []uv{}
+	for _, each := range e.chain {
+		points = append(points, uv{each.u, each.v})
+	}
+	first, last := e.chain[0], e.chain[len(e.chain)-1]
+	labelAt := uv{}
+	switch {
+	case first.rank == last.rank:
+		// flat edge ; route above the rank if there are nodes in between
+		mid := uv{(first.u + last.u) / 2, first.v}
+		if d := first.order - last.order; d > 1 || d < -1 {
+			mid.v -= max(first.vSize, last.vSize)/2 + b.rankSep/3
+		}
+		points = []uv{points[0], mid, points[1]}
+		labelAt = uv{mid.u, mid.v - b.labelV(e)/2 - 2}
+	case e.labelNode != nil:
+		labelAt = uv{e.labelNode.u + b.labelU(e)/2 + dummyWidth, e.labelNode.v}
+	default:
+		mid := uv{(first.u + last.u) / 2, (first.v + last.v) / 2}
+		labelAt = uv{mid.u + b.labelU(e)/2 + dummyWidth, mid.v}
+	}
+	if len(points) == 2 {
+		// spread parallel edges between the same nodes
+		key := [2]*vnode{first, last}
+		k := parallel[key]
+		parallel[key] = k + 1
+		if k > 0 {
+			offset := float64((k+1)/2) * 2 * b.nodeSep / 3
+			if k%2 == 1 {
+				offset = -offset
+			}
+			mid := uv{(first.u+last.u)/2 + offset, (first.v + last.v) / 2}
+			points = []uv{points[0], mid, points[1]}
+			labelAt.u += offset
+		}
+	}
+	for _, each := range points {
+		el.Points = append(el.Points, b.transform(each.u, each.v))
+	}
+	el.LabelPosition = b.transform(labelAt.u, labelAt.v)
+	if e.reversed {
+		for i, j := 0, len(el.Points)-1; i < j; i, j = i+1, j-1 {
+			el.Points[i], el.Points[j] = el.Points[j], el.Points[i]
+		}
+	}
+	tail, head := e.tail, e.head
+	el.Points[0] = clip(tail, el.Points[0], el.Points[1])
+	last2 := len(el.Points) - 1
+	el.Points[last2] = clip(head, el.Points[last2], el.Points[last2-1])
+	return el
+}
+
+// clip returns the point on the boundary of the node at center c in the direction of p.
+func clip(v *vnode, c, p Point) Point {
+	dx, dy := p.X-c.X, p.Y-c.Y
+	if dx == 0 && dy == 0 {
+		return c
+	}
+	w, h := v.layout.Width/2, v.layout.Height/2
+	var t float64
+	switch v.shape {
+	case "ellipse", "oval", "", "circle", "doublecircle", "point", "mcircle":
+		t = 1 / math.Sqrt((dx/w)*(dx/w)+(dy/h)*(dy/h))
+	case "diamond", "mdiamond":
+		t = 1 / (math.Abs(dx)/w + math.Abs(dy)/h)
+	default:
+		t = math.Inf(1)
+		if dx != 0 {
+			t = w / math.Abs(dx)
+		}
+		if dy != 0 {
+			t = min(t, h/math.Abs(dy))
+		}
+	}
+	if t > 1 {
+		// p is inside the node
+		return c
+	}
+	return Point{X: c.X + t*dx, Y: c.Y + t*dy}
+}
@@ -0,0 +1,308 @@
+package layout
+
+import (
+	"github.com/emicklei/dot"
+)
+
+// classEdge is a constraint between two rank classes.
+type classEdge struct {
+	from, to int
+	minlen   int
+}
+
+// rank assigns a rank to each vnode. Nodes of a rank group share a rank class.
+// Cycles are broken by reversing back edges found by a depth-first search,
+// after which the longest path from the sources determines the ranks.
+func (b *builder) rank() {
+	// union-find of rank classes
+	parent := make([]int, len(b.vnodes))
+	for i, each := range b.vnodes {
+		parent[i] = i
+		each.class = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	groupTypes := map[int]dot.RankType{}
+	for _, group := range b.groups {
+		first := -1
+		for _, n := range group.nodes {
+			v := b.bySeq[n.Seq()]
+			if v == nil {
+				continue
+			}
+			if first == -1 {
+				first = find(v.class)
+			} else if other := find(v.class); other != first {
+				parent[other] = first
+			}
+		}
+		if first != -1 && group.rank != dot.RankSame {
+			groupTypes[first] = group.rank
+		}
+	}
+	// the type of a group is kept on the representative of the class
+	classType := map[int]dot.RankType{}
+	for class, rank := range groupTypes {
+		classType[find(class)] = rank
+	}
+	for _, each := range b.vnodes {
+		each.class = find(each.class)
+	}
+
+	out := make([][]classEdge, len(b.vnodes))
+	for _, each := range b.edges {
+		if !each.constraint || each.tail.class == each.head.class {
+			continue
+		}
+		out[each.tail.class] = append(out[each.tail.class], classEdge{from: each.tail.class, to: each.head.class, minlen: each.minlen})
+	}
+	out = removeCycles(out)
+
+	// longest path ranking in topological order
+	indegree := make([]int, len(out))
+	for _, edges := range out {
+		for _, e := range edges {
+			indegree[e.to]++
+		}
+	}
+	ranks := make([]int, len(out))
+	topo := []int{}
+	queue := []int{}
+	for i, each := range b.vnodes {
+		if each.class == i && indegree[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		topo = append(topo, c)
+		for _, e := range out[c] {
+			if r := ranks[c] + e.minlen; r > ranks[e.to] {
+				ranks[e.to] = r
+			}
+			indegree[e.to]--
+			if indegree[e.to] == 0 {
+				queue = append(queue, e.to)
+			}
+		}
+	}
+	// move sources down towards their successors to shorten their edges
+	hasIncoming := make([]bool, len(out))
+	for _, edges := range out {
+		for _, e := range edges {
+			hasIncoming[e.to] = true
+		}
+	}
+	for i := len(topo) - 1; i >= 0; i-- {
+		c := topo[i]
+		if hasIncoming[c] || len(out[c]) == 0 || classType[c] != "" {
+			continue
+		}
+		lowest := -1
+		for _, e := range out[c] {
+			if r := ranks[e.to] - e.minlen; lowest == -1 || r < lowest {
+				lowest = r
+			}
+		}
+		if lowest > ranks[c] {
+			ranks[c] = lowest
+		}
+	}
+	applyRankTypes(ranks, topo, classType)
+
+	for _, each := range b.vnodes {
+		each.rank = ranks[each.class]
+	}
+	// the direction of an edge in the layered graph follows the ranks
+	for _, each := range b.edges {
+		each.reversed = each.tail.rank > each.head.rank
+	}
+}
+
+// removeCycles reverses the back edges found by a depth-first search.
+func removeCycles(out [][]classEdge) [][]classEdge {
+	const (
+		white = iota
+		gray
+		black
+	)
+	color := make([]int, len(out))
+	result := make([][]classEdge, len(out))
+	var visit func(c int)
+	visit = func(c int) {
+		color[c] = gray
+		for _, e := range out[c] {
+			switch color[e.to] {
+			case white:
+				result[c] = append(result[c], e)
+				visit(e.to)
+			case gray:
+				// back edge
+				result[e.to] = append(result[e.to], classEdge{from: e.to, to: c, minlen: e.minlen})
+			default:
+				result[c] = append(result[c], e)
+			}
+		}
+		color[c] = black
+	}
+	for c := range out {
+		if color[c] == white {
+			visit(c)
+		}
+	}
+	return result
+}
+
+// applyRankTypes moves the classes of min, source, max and sink groups to the minimum or maximum rank.
+func applyRankTypes(ranks []int, classes []int, classType map[int]dot.RankType) {
+	if len(classes) == 0 {
+		return
+	}
+	lowest, highest := ranks[classes[0]], ranks[classes[0]]
+	for _, c := range classes {
+		if ranks[c] < lowest {
+			lowest = ranks[c]
+		}
+		if ranks[c] > highest {
+			highest = ranks[c]
+		}
+	}
+	hasSource, hasSink := false, false
+	for c, rank := range classType {
+		switch rank {
+		case dot.RankMin:
+			ranks[c] = lowest
+		case dot.RankMax:
+			ranks[c] = highest
+		case dot.RankSource:
+			hasSource = true
+		case dot.RankSink:
+			hasSink = true
+		}
+	}
+	if hasSource {
+		// only sources are on the minimum rank
+		for _, c := range classes {
+			if classType[c] == dot.RankSource {
+				ranks[c] = lowest
+			} else {
+				ranks[c]++
+			}
+		}
+		highest++
+	}
+	if hasSink {
+		for _, c := range classes {
+			if classType[c] == dot.RankSink {
+				ranks[c] = highest + 1
+			}
+		}
+	}
+	// normalize to start at rank 0
+	lowest = ranks[classes[0]]
+	for _, c := range classes {
+		if ranks[c] < lowest {
+			lowest = ranks[c]
+		}
+	}
+	for _, c := range classes {
+		ranks[c] -= lowest
+	}
+}
+
+// insertDummies creates the chains of all edges ; edges that span more than one rank
+// get a dummy node on each rank in between. Clusters get a filler on each rank they span without nodes.
+func (b *builder) insertDummies() {
+	for _, each := range b.edges {
+		if each.tail == each.head {
+			continue
+		}
+		top, bottom := each.tail, each.head
+		if each.reversed {
+			top, bottom = bottom, top
+		}
+		each.chain = []*vnode{top}
+		path := commonPath(top.path, bottom.path)
+		middle := (top.rank + bottom.rank) / 2
+		for r := top.rank + 1; r < bottom.rank; r++ {
+			d := &vnode{rank: r, uSize: dummyWidth, path: path}
+			if len(each.label) > 0 && r == middle && each.labelNode == nil {
+				d.uSize += b.labelU(each)
+				each.labelNode = d
+			}
+			b.vnodes = append(b.vnodes, d)
+			each.chain = append(each.chain, d)
+		}
+		each.chain = append(each.chain, bottom)
+		for i := 1; i < len(each.chain); i++ {
+			x, y := each.chain[i-1], each.chain[i]
+			if x.rank != y.rank {
+				x.down = append(x.down, y)
+				y.up = append(y.up, x)
+			}
+		}
+	}
+	b.insertFillers()
+}
+
+// insertFillers adds a filler to each rank in the span of a cluster that has no node of that cluster.
+// The order keeps the nodes of a cluster together, also with the fillers, such that no other node can
+// be positioned inside the cluster on such a rank. Nested clusters come after their parent and are filled first.
+func (b *builder) insertFillers() {
+	for i := len(b.clusters) - 1; i >= 0; i-- {
+		c := b.clusters[i]
+		members := map[int]bool{}
+		first, last := -1, -1
+		for _, v := range b.vnodes {
+			if inCluster(v, c) {
+				members[v.rank] = true
+				if first == -1 || v.rank < first {
+					first = v.rank
+				}
+				if v.rank > last {
+					last = v.rank
+				}
+			}
+		}
+		for r := first + 1; r < last; r++ {
+			if !members[r] {
+				b.vnodes = append(b.vnodes, &vnode{rank: r, path: clusterPath(c), filler: true})
+			}
+		}
+	}
+}
+
+// labelU returns the extent of the edge label along a rank.
+func (b *builder) labelU(e *vedge) float64 {
+	if b.horizontal() {
+		return e.labelH
+	}
+	return e.labelW
+}
+
+// labelV returns the extent of the edge label across the ranks.
+func (b *builder) labelV(e *vedge) float64 {
+	if b.horizontal() {
+		return e.labelW
+	}
+	return e.labelH
+}
+
+// commonPath returns the longest common prefix of two cluster paths.
+func commonPath(one, two []*cluster) []*cluster {
+	return one[:commonLength(one, two)]
+}
+
+func commonLength(one, two []*cluster) int {
+	i := 0
+	for i < len(one) && i < len(two) && one[i] == two[i] {
+		i++
+	}
+	return i
+}
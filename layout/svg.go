@@ -0,0 +1,477 @@
+package layout
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/dot"
+)
+
+const (
+	arrowLength = 10.0
+	arrowWidth  = 7.0
+)
+
+// SVG computes the layout of the graph and returns it as an SVG document.
+func SVG(g *dot.Graph) string {
+	sb := new(strings.Builder)
+	Compute(g).WriteSVG(sb)
+	return sb.String()
+}
+
+// WriteSVG writes the layout as an SVG document to w, element by element.
+// It returns the number of bytes written and stops at the first error of w.
+func (l *Layout) WriteSVG(w io.Writer) (int64, error) {
+	out := dot.NewIndentWriter(w)
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%spt" height="%spt" viewBox="0 0 %s %s">`+"\n",
+		num(l.Bounds.Width), num(l.Bounds.Height), num(l.Bounds.Width), num(l.Bounds.Height))
+	fmt.Fprintf(out, `<g class="graph">`+"\n")
+	if bg := svgColor(l.Graph.Value("bgcolor"), ""); bg != "" {
+		fmt.Fprintf(out, `<rect x="0" y="0" width="%s" height="%s" fill="%s"/>`+"\n", num(l.Bounds.Width), num(l.Bounds.Height), bg)
+	}
+	for _, each := range l.Clusters {
+		writeCluster(out, each)
+	}
+	for _, each := range l.Edges {
+		writeEdge(out, l, each)
+	}
+	for _, each := range l.Nodes {
+		writeNode(out, l, each)
+	}
+	if label := l.Graph.Value("label"); label != nil {
+		lines := labelLines(label, map[byte]string{'G': l.Graph.GetID()})
+		fontSize := floatValue(l.Graph.Value("fontsize"), defaultFontSize)
+		_, h := textSize(lines, fontSize)
+		writeText(out, lines, Point{X: l.Bounds.Width / 2, Y: l.Bounds.Height - graphMargin - h/2}, fontSize, fontAttributes(l.Graph.Value("fontname"), l.Graph.Value("fontcolor")))
+	}
+	out.WriteString("</g>\n</svg>\n")
+	return out.Written(), out.Err()
+}
+
+func writeCluster(out *dot.IndentWriter, c *ClusterLayout) {
+	g := c.Graph
+	style := styles(g.Value("style"))
+	fill := "none"
+	if style["filled"] {
+		fill = svgColor(firstNonNil(g.Value("fillcolor"), g.Value("color"), g.Value("bgcolor")), "lightgrey")
+	} else if bg := svgColor(g.Value("bgcolor"), ""); bg != "" {
+		fill = bg
+	}
+	stroke := svgColor(firstNonNil(g.Value("pencolor"), g.Value("color")), "black")
+	fmt.Fprintf(out, `<g class="cluster" id="%s">`+"\n", html.EscapeString(g.GetID()))
+	rx := ""
+	if style["rounded"] {
+		rx = ` rx="8" ry="8"`
+	}
+	fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s"%s fill="%s" stroke="%s"%s/>`+"\n",
+		num(c.Bounds.X), num(c.Bounds.Y), num(c.Bounds.Width), num(c.Bounds.Height), rx, fill, stroke, strokeStyle(style, g.Value("penwidth")))
+	if label := g.Value("label"); label != nil {
+		lines := labelLines(label, map[byte]string{'G': g.GetID()})
+		fontSize := floatValue(g.Value("fontsize"), defaultFontSize)
+		_, h := textSize(lines, fontSize)
+		at := Point{X: c.Bounds.X + c.Bounds.Width/2, Y: c.Bounds.Y + clusterMargin/2 + h/2}
+		writeText(out, lines, at, fontSize, fontAttributes(g.Value("fontname"), g.Value("fontcolor")))
+	}
+	out.WriteString("</g>\n")
+}
+
+func writeNode(out *dot.IndentWriter, l *Layout, n *NodeLayout) {
+	node := n.Node
+	style := styles(node.EffectiveValue("style"))
+	if style["invis"] {
+		return
+	}
+	shape := stringValue(node.EffectiveValue("shape"))
+	stroke := svgColor(node.EffectiveValue("color"), "black")
+	fill := "none"
+	if style["filled"] {
+		fill = svgColor(firstNonNil(node.EffectiveValue("fillcolor"), node.EffectiveValue("color")), "lightgrey")
+	}
+	if shape == "point" {
+		fill = svgColor(firstNonNil(node.EffectiveValue("fillcolor"), node.EffectiveValue("color")), "black")
+	}
+	paint := fmt.Sprintf(` fill="%s" stroke="%s"%s`, fill, stroke, strokeStyle(style, node.EffectiveValue("penwidth")))
+	fmt.Fprintf(out, `<g class="node" id="n%d">`+"\n", node.Seq())
+	if title := node.ID(); title != "" {
+		fmt.Fprintf(out, "<title>%s</title>\n", html.EscapeString(title))
+	}
+	c, w, h := n.Center, n.Width/2, n.Height/2
+	switch shape {
+	case "plaintext", "plain", "none":
+	case "ellipse", "oval", "":
+		fmt.Fprintf(out, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s/>`+"\n", num(c.X), num(c.Y), num(w), num(h), paint)
+	case "circle", "point":
+		fmt.Fprintf(out, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s/>`+"\n", num(c.X), num(c.Y), num(w), num(h), paint)
+	case "doublecircle":
+		fmt.Fprintf(out, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s"%s/>`+"\n", num(c.X), num(c.Y), num(w), num(h), paint)
+		fmt.Fprintf(out, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" fill="none" stroke="%s"/>`+"\n", num(c.X), num(c.Y), num(w-4), num(h-4), stroke)
+	case "box", "rect", "rectangle", "square", "record", "mrecord", "component", "note", "tab", "folder", "box3d", "underline", "cylinder":
+		rx := ""
+		if style["rounded"] || shape == "mrecord" {
+			rx = ` rx="6" ry="6"`
+		}
+		fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s"%s%s/>`+"\n", num(c.X-w), num(c.Y-h), num(2*w), num(2*h), rx, paint)
+	default:
+		if points, ok := polygon(shape, c, w, h); ok {
+			fmt.Fprintf(out, `<polygon points="%s"%s/>`+"\n", pointList(points), paint)
+		} else {
+			fmt.Fprintf(out, `<rect x="%s" y="%s" width="%s" height="%s"%s/>`+"\n", num(c.X-w), num(c.Y-h), num(2*w), num(2*h), paint)
+		}
+	}
+	if shape != "point" {
+		lines := labelLines(node.EffectiveValue("label"), map[byte]string{'N': node.ID(), 'G': l.Graph.GetID()})
+		if shape == "record" || shape == "mrecord" {
+			lines = recordLines(lines)
+		}
+		writeText(out, lines, c, floatValue(node.EffectiveValue("fontsize"), defaultFontSize), fontAttributes(node.EffectiveValue("fontname"), node.EffectiveValue("fontcolor")))
+	}
+	out.WriteString("</g>\n")
+}
+
+// polygon returns the corners of a polygon shape.
+func polygon(shape string, c Point, w, h float64) ([]Point, bool) {
+	switch shape {
+	case "diamond", "mdiamond":
+		return []Point{{c.X, c.Y - h}, {c.X + w, c.Y}, {c.X, c.Y + h}, {c.X - w, c.Y}}, true
+	case "triangle":
+		return []Point{{c.X, c.Y - h}, {c.X + w, c.Y + h}, {c.X - w, c.Y + h}}, true
+	case "invtriangle":
+		return []Point{{c.X - w, c.Y - h}, {c.X + w, c.Y - h}, {c.X, c.Y + h}}, true
+	case "trapezium":
+		return []Point{{c.X - w/2, c.Y - h}, {c.X + w/2, c.Y - h}, {c.X + w, c.Y + h}, {c.X - w, c.Y + h}}, true
+	case "invtrapezium":
+		return []Point{{c.X - w, c.Y - h}, {c.X + w, c.Y - h}, {c.X + w/2, c.Y + h}, {c.X - w/2, c.Y + h}}, true
+	case "parallelogram":
+		return []Point{{c.X - w/2, c.Y - h}, {c.X + w, c.Y - h}, {c.X + w/2, c.Y + h}, {c.X - w, c.Y + h}}, true
+	case "house":
+		return []Point{{c.X, c.Y - h}, {c.X + w, c.Y - h/3}, {c.X + w, c.Y + h}, {c.X - w, c.Y + h}, {c.X - w, c.Y - h/3}}, true
+	case "invhouse":
+		return []Point{{c.X - w, c.Y - h}, {c.X + w, c.Y - h}, {c.X + w, c.Y + h/3}, {c.X, c.Y + h}, {c.X - w, c.Y + h/3}}, true
+	case "pentagon":
+		return regular(5, c, w, h), true
+	case "hexagon":
+		return []Point{{c.X - w/2, c.Y - h}, {c.X + w/2, c.Y - h}, {c.X + w, c.Y}, {c.X + w/2, c.Y + h}, {c.X - w/2, c.Y + h}, {c.X - w, c.Y}}, true
+	case "septagon":
+		return regular(7, c, w, h), true
+	case "octagon", "doubleoctagon", "tripleoctagon":
+		return regular(8, c, w, h), true
+	}
+	return nil, false
+}
+
+// regular returns the corners of a regular polygon with n sides, scaled to the width and height.
+func regular(n int, c Point, w, h float64) []Point {
+	list := []Point{}
+	for i := 0; i < n; i++ {
+		a := math.Pi/2 + math.Pi/float64(n) + 2*math.Pi*float64(i)/float64(n)
+		list = append(list, Point{X: c.X + w*math.Cos(a), Y: c.Y - h*math.Sin(a)})
+	}
+	return list
+}
+
+func writeEdge(out *dot.IndentWriter, l *Layout, el *EdgeLayout) {
+	e := el.Edge
+	style := styles(e.EffectiveValue("style"))
+	if style["invis"] || len(el.Points) < 2 {
+		return
+	}
+	color := svgColor(e.EffectiveValue("color"), "black")
+	dir := stringValue(e.EffectiveValue("dir"))
+	if dir == "" {
+		dir = "none"
+		if l.Graph.IsDirected() {
+			dir = "forward"
+		}
+	}
+	head := stringValue(e.EffectiveValue("arrowhead"))
+	if head == "" {
+		head = "normal"
+	}
+	tail := stringValue(e.EffectiveValue("arrowtail"))
+	if tail == "" {
+		tail = "normal"
+	}
+	if dir != "forward" && dir != "both" {
+		head = "none"
+	}
+	if dir != "back" && dir != "both" {
+		tail = "none"
+	}
+	points := append([]Point(nil), el.Points...)
+	var headTip, tailTip Point
+	if head != "none" {
+		if el.ArrowHead != nil {
+			headTip = *el.ArrowHead
+		} else {
+			headTip = points[len(points)-1]
+			points[len(points)-1] = shorten(headTip, points[len(points)-2])
+		}
+	}
+	if tail != "none" {
+		if el.ArrowTail != nil {
+			tailTip = *el.ArrowTail
+		} else {
+			tailTip = points[0]
+			points[0] = shorten(tailTip, points[1])
+		}
+	}
+	fmt.Fprintf(out, `<g class="edge" id="e%d_%d">`+"\n", e.From().Seq(), e.To().Seq())
+	fmt.Fprintf(out, "<title>%s</title>\n", html.EscapeString(e.From().ID()+"->"+e.To().ID()))
+	path := pathData(points)
+	if el.Spline {
+		path = splineData(points)
+	}
+	fmt.Fprintf(out, `<path d="%s" fill="none" stroke="%s"%s/>`+"\n", path, color, strokeStyle(style, e.EffectiveValue("penwidth")))
+	if head != "none" {
+		writeArrow(out, head, headTip, points[len(points)-1], color)
+	}
+	if tail != "none" {
+		writeArrow(out, tail, tailTip, points[0], color)
+	}
+	if label := e.EffectiveValue("label"); label != nil {
+		lines := labelLines(label, map[byte]string{'E': e.From().ID() + "->" + e.To().ID(), 'T': e.From().ID(), 'H': e.To().ID(), 'G': l.Graph.GetID()})
+		writeText(out, lines, el.LabelPosition, floatValue(e.EffectiveValue("fontsize"), defaultFontSize), fontAttributes(e.EffectiveValue("fontname"), e.EffectiveValue("fontcolor")))
+	}
+	out.WriteString("</g>\n")
+}
+
+// shorten returns the point at arrow length from the tip in the direction of the other point.
+func shorten(tip, other Point) Point {
+	dx, dy := other.X-tip.X, other.Y-tip.Y
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return tip
+	}
+	return Point{X: tip.X + dx/d*arrowLength, Y: tip.Y + dy/d*arrowLength}
+}
+
+// writeArrow writes an arrow of a type with its tip at the node boundary and its base at the end of the edge line.
+func writeArrow(out *dot.IndentWriter, arrow string, tip, base Point, color string) {
+	dx, dy := tip.X-base.X, tip.Y-base.Y
+	d := math.Hypot(dx, dy)
+	if d == 0 {
+		return
+	}
+	// unit vectors along and perpendicular to the arrow
+	ux, uy := dx/d, dy/d
+	px, py := -uy*arrowWidth/2, ux*arrowWidth/2
+	fill := color
+	if strings.HasPrefix(arrow, "o") || arrow == "empty" {
+		fill = "none"
+		arrow = strings.TrimPrefix(arrow, "o")
+	}
+	mid := Point{X: (tip.X + base.X) / 2, Y: (tip.Y + base.Y) / 2}
+	switch arrow {
+	case "dot":
+		fmt.Fprintf(out, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" fill="%s" stroke="%s"/>`+"\n", num(mid.X), num(mid.Y), num(d/2), num(d/2), fill, color)
+	case "diamond":
+		fmt.Fprintf(out, `<polygon points="%s" fill="%s" stroke="%s"/>`+"\n", pointList([]Point{tip, {mid.X + px, mid.Y + py}, base, {mid.X - px, mid.Y - py}}), fill, color)
+	case "box":
+		fmt.Fprintf(out, `<polygon points="%s" fill="%s" stroke="%s"/>`+"\n", pointList([]Point{
+			{tip.X + px, tip.Y + py}, {base.X + px, base.Y + py}, {base.X - px, base.Y - py}, {tip.X - px, tip.Y - py}}), fill, color)
+	case "tee":
+		fmt.Fprintf(out, `<polygon points="%s" fill="%s" stroke="%s"/>`+"\n", pointList([]Point{
+			{tip.X + px, tip.Y + py}, {tip.X - ux*3 + px, tip.Y - uy*3 + py}, {tip.X - ux*3 - px, tip.Y - uy*3 - py}, {tip.X - px, tip.Y - py}}), color, color)
+		fmt.Fprintf(out, `<path d="M%s,%s L%s,%s" stroke="%s"/>`+"\n", num(tip.X), num(tip.Y), num(base.X), num(base.Y), color)
+	case "vee":
+		fmt.Fprintf(out, `<polygon points="%s" fill="%s" stroke="%s"/>`+"\n", pointList([]Point{
+			tip, {base.X + px, base.Y + py}, {mid.X, mid.Y}, {base.X - px, base.Y - py}}), fill, color)
+	case "inv":
+		fmt.Fprintf(out, `<polygon points="%s" fill="%s" stroke="%s"/>`+"\n", pointList([]Point{
+			base, {tip.X + px, tip.Y + py}, {tip.X - px, tip.Y - py}}), fill, color)
+	default:
+		// normal and empty
+		fmt.Fprintf(out, `<polygon points="%s" fill="%s" stroke="%s"/>`+"\n", pointList([]Point{
+			tip, {base.X + px, base.Y + py}, {base.X - px, base.Y - py}}), fill, color)
+	}
+}
+
+// pathData returns the SVG path of a line or a smooth curve (Catmull-Rom) through the points.
+func pathData(points []Point) string {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "M%s,%s", num(points[0].X), num(points[0].Y))
+	if len(points) == 2 {
+		fmt.Fprintf(sb, " L%s,%s", num(points[1].X), num(points[1].Y))
+		return sb.String()
+	}
+	for i := 0; i < len(points)-1; i++ {
+		p0, p1, p2, p3 := points[max0(i-1)], points[i], points[i+1], points[minIndex(i+2, len(points)-1)]
+		c1 := Point{X: p1.X + (p2.X-p0.X)/6, Y: p1.Y + (p2.Y-p0.Y)/6}
+		c2 := Point{X: p2.X - (p3.X-p1.X)/6, Y: p2.Y - (p3.Y-p1.Y)/6}
+		fmt.Fprintf(sb, " C%s,%s %s,%s %s,%s", num(c1.X), num(c1.Y), num(c2.X), num(c2.Y), num(p2.X), num(p2.Y))
+	}
+	return sb.String()
+}
+
+// splineData returns the SVG path of a B-spline with control points as produced by Graphviz (1+3n points).
+func splineData(points []Point) string {
+	if (len(points)-1)%3 != 0 {
+		return pathData(points)
+	}
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "M%s,%s", num(points[0].X), num(points[0].Y))
+	for i := 1; i+2 < len(points); i += 3 {
+		fmt.Fprintf(sb, " C%s,%s %s,%s %s,%s", num(points[i].X), num(points[i].Y), num(points[i+1].X), num(points[i+1].Y), num(points[i+2].X), num(points[i+2].Y))
+	}
+	return sb.String()
+}
+
+func max0(i int) int {
+	if i < 0 {
+		return 0
+	}
+	return i
+}
+
+func minIndex(i, j int) int {
+	if i < j {
+		return i
+	}
+	return j
+}
+
+// writeText writes the lines centered at a point.
+func writeText(out *dot.IndentWriter, lines []string, at Point, fontSize float64, font string) {
+	if len(lines) == 0 {
+		return
+	}
+	step := fontSize * lineHeight
+	y := at.Y - step*float64(len(lines)-1)/2 + fontSize*0.35
+	for i, each := range lines {
+		if each == "" {
+			continue
+		}
+		fmt.Fprintf(out, `<text text-anchor="middle" x="%s" y="%s" font-size="%s"%s>%s</text>`+"\n",
+			num(at.X), num(y+float64(i)*step), num(fontSize), font, html.EscapeString(each))
+	}
+}
+
+func fontAttributes(name, color interface{}) string {
+	font := stringValue(name)
+	if font == "" {
+		font = "times,serif"
+	}
+	s := fmt.Sprintf(` font-family="%s"`, html.EscapeString(font))
+	if c := svgColor(color, ""); c != "" {
+		s += fmt.Sprintf(` fill="%s"`, c)
+	}
+	return s
+}
+
+// styles returns the set of comma separated styles.
+func styles(value interface{}) map[string]bool {
+	set := map[string]bool{}
+	for _, each := range strings.Split(stringValue(value), ",") {
+		if s := strings.TrimSpace(each); s != "" {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+// strokeStyle returns the SVG attributes for the dashed, dotted and bold styles and the penwidth.
+func strokeStyle(style map[string]bool, penwidth interface{}) string {
+	s := ""
+	width := floatValue(penwidth, 1)
+	if style["bold"] {
+		width = max(width, 2)
+	}
+	if width != 1 {
+		s += fmt.Sprintf(` stroke-width="%s"`, num(width))
+	}
+	if style["dashed"] {
+		s += ` stroke-dasharray="5,2"`
+	}
+	if style["dotted"] {
+		s += ` stroke-dasharray="1,5"`
+	}
+	return s
+}
+
+// svgColor returns the SVG notation of a Graphviz color ; the default if absent.
+func svgColor(value interface{}, defaultColor string) string {
+	s := stringValue(value)
+	// a color list takes the first color
+	if i := strings.IndexAny(s, ":;"); i != -1 {
+		s = s[:i]
+	}
+	// a color scheme like /x11/red
+	if i := strings.LastIndex(s, "/"); i != -1 {
+		s = s[i+1:]
+	}
+	if s == "" {
+		return defaultColor
+	}
+	if s == "transparent" {
+		return "none"
+	}
+	if strings.HasPrefix(s, "#") {
+		if len(s) == 9 {
+			// drop the alpha channel
+			return s[:7]
+		}
+		return s
+	}
+	if fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }); len(fields) == 3 {
+		hsv := []float64{}
+		for _, each := range fields {
+			f, err := strconv.ParseFloat(each, 64)
+			if err != nil {
+				return defaultColor
+			}
+			hsv = append(hsv, f)
+		}
+		return hsvToRGB(hsv[0], hsv[1], hsv[2])
+	}
+	return html.EscapeString(s)
+}
+
+// hsvToRGB converts a hue, saturation, value color (each 0..1) to #rrggbb.
+func hsvToRGB(h, s, v float64) string {
+	h = math.Mod(h, 1) * 6
+	i := math.Floor(h)
+	f := h - i
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch int(i) {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	return fmt.Sprintf("#%02x%02x%02x", int(r*255+0.5), int(g*255+0.5), int(b*255+0.5))
+}
+
+func firstNonNil(values ...interface{}) interface{} {
+	for _, each := range values {
+		if each != nil {
+			return each
+		}
+	}
+	return nil
+}
+
+func pointList(points []Point) string {
+	list := []string{}
+	for _, each := range points {
+		list = append(list, num(each.X)+","+num(each.Y))
+	}
+	return strings.Join(list, " ")
+}
+
+// num formats a coordinate with at most two decimals.
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,149 @@
+package layout
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/dot"
+)
+
+func TestSVGShapesAndStyles(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	a := g.Node("a").Box()
+	b := g.Node("b").Attr("shape", "diamond").Attr("style", "filled").Attr("fillcolor", "yellow")
+	c := g.Node("c").Attr("shape", "circle")
+	d := g.Node("d").Attr("shape", "hexagon")
+	a.Edge(b).Dashed()
+	a.Edge(c).Dotted()
+	a.Edge(d).Bold()
+	b.Edge(d).Attr("style", "invis")
+	s := SVG(g)
+	for _, each := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg"`,
+		`<rect x=`,
+		`<ellipse cx=`,
+		`fill="yellow"`,
+		`stroke-dasharray="5,2"`,
+		`stroke-dasharray="1,5"`,
+		`stroke-width="2"`,
+		`<title>a-&gt;b</title>`,
+		`>a</text>`,
+		`</svg>`,
+	} {
+		if !strings.Contains(s, each) {
+			t.Errorf("missing %q in %s", each, s)
+		}
+	}
+	if strings.Contains(s, `<title>b-&gt;d</title>`) {
+		t.Error("invisible edge was written")
+	}
+	if got, want := strings.Count(s, "<polygon"), 3+2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestSVGClusterAndLabels(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	g.Label("graph & label")
+	c := g.Subgraph("one", dot.ClusterOption{})
+	c.Attr("style", "filled")
+	c.Attr("color", "lightblue")
+	a := c.Node("a").Attr("label", dot.HTML("<b>bold</b><br/>line"))
+	b := g.Node("b").Attr("label", "two\nlines")
+	a.Edge(b, "ab")
+	s := SVG(g)
+	for _, each := range []string{
+		`<g class="cluster" id="cluster_s1">`,
+		`fill="lightblue"`,
+		`>bold</text>`,
+		`>line</text>`,
+		`>two</text>`,
+		`>lines</text>`,
+		`>ab</text>`,
+		`>graph &amp; label</text>`,
+	} {
+		if !strings.Contains(s, each) {
+			t.Errorf("missing %q in %s", each, s)
+		}
+	}
+}
+
+func TestSVGArrows(t *testing.T) {
+	g := dot.NewGraph(dot.Undirected)
+	a, b := g.Node("a"), g.Node("b")
+	a.Edge(b)
+	if got, want := strings.Count(SVG(g), "<polygon"), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	a.Edge(b).Attr("dir", "both").Attr("arrowhead", "odot")
+	s := SVG(g)
+	if got, want := strings.Count(s, "<polygon"), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if !strings.Contains(s, `fill="none" stroke="black"/>`) {
+		t.Errorf("missing open dot in %s", s)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("fail") }
+
+func TestWriteSVGError(t *testing.T) {
+	g := dot.NewGraph(dot.Directed)
+	g.Node("a")
+	n, err := Compute(g).WriteSVG(failingWriter{})
+	if err == nil {
+		t.Error("expected error")
+	}
+	if got, want := n, int64(0); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	sb := new(strings.Builder)
+	n, err = Compute(g).WriteSVG(sb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := n, int64(sb.Len()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestSVGColor(t *testing.T) {
+	for _, each := range []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "black"},
+		{"Red", "red"},
+		{"#ff000080", "#ff0000"},
+		{"/blues9/3", "3"},
+		{"red:blue", "red"},
+		{"0.0 1.0 1.0", "#ff0000"},
+		{"transparent", "none"},
+	} {
+		if got, want := svgColor(each.value, "black"), each.want; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestLabelLines(t *testing.T) {
+	names := map[byte]string{'N': "node"}
+	for _, each := range []struct {
+		value interface{}
+		want  string
+	}{
+		{nil, "node"},
+		{`one\ntwo\l`, "one|two"},
+		{"one\ntwo", "one|two"},
+		{dot.Literal(`"say \"hi\"\l"`), `say "hi"`},
+		{dot.HTML("<table><tr><td>A &amp; B</td></tr><tr><td>C</td></tr></table>"), "A & B|C"},
+		{`\N!`, "node!"},
+	} {
+		if got, want := strings.Join(labelLines(each.value, names), "|"), each.want; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
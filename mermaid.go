@@ -1,8 +1,12 @@
 package dot
 
 import (
+	"bytes"
 	"fmt"
 	"html"
+	"io"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -14,73 +18,387 @@ const (
 	MermaidLeftToRight
 )
 
-var (
-	MermaidShapeRound      = shape{"(", ")"}
-	MermaidShapeStadium    = shape{"([", "])"}
-	MermaidShapeSubroutine = shape{"[[", "]]"}
-	MermaidShapeCylinder   = shape{"[(", ")]"}
-	//Deprecated: use MermaidShapeCircle instead
-	MermaidShapeCirle            = shape{"((", "))"}
-	MermaidShapeCircle           = shape{"((", "))"}
-	MermaidShapeAsymmetric       = shape{">", "]"}
-	MermaidShapeRhombus          = shape{"{", "}"}
-	MermaidShapeTrapezoid        = shape{"[/", "\\]"}
-	MermaidShapeTrapezoidAlt     = shape{"[\\", "/]"}
-	MermaidShapeHexagon          = shape{"{{", "}}"}
-	MermaidShapeParallelogram    = shape{"[/", "/]"}
-	MermaidShapeParallelogramAlt = shape{"[\\", "\\]"}
-	// TODO more shapes see https://mermaid.js.org/syntax/flowchart.html#node-shapes
-)
-
-type shape struct {
-	open, close string
+// MermaidOptions controls the mermaid output of MermaidGraphWithOptions and MermaidFlowchartWithOptions.
+type MermaidOptions struct {
+	// Orientation is one of MermaidTopToBottom, MermaidTopDown, MermaidBottomToTop, MermaidRightToLeft or MermaidLeftToRight.
+	Orientation int
+	// ClassDefs are written as classDef statements, e.g. "important": "fill:#f96,stroke:#333".
+	// Use the node attribute "class" to assign one or more, separated by spaces.
+	// These are added to the classDef statements read by ParseMermaid.
+	ClassDefs map[string]string
+	// Title is written in the front matter ; if empty then the label attribute of the graph is taken.
+	Title string
+	// Theme is the name of a mermaid theme, e.g. "forest" or "base".
+	Theme string
+	// ThemeVariables are the variables of the theme, e.g. "primaryColor": "#BB2528".
+	ThemeVariables map[string]string
+	// Curve is the style of the links, e.g. "basis", "linear" or "stepBefore".
+	Curve string
+	// HTMLLabels sets htmlLabels of the flowchart config if not nil.
+	HTMLLabels *bool
+	// AccTitle is written as accTitle ; if empty then the title is taken.
+	AccTitle string
+	// AccDescr is written as accDescr ; if empty then the comment attribute of the graph is taken.
+	AccDescr string
 }
 
 func MermaidGraph(g *Graph, orientation int) string {
-	return diagram(g, "graph", orientation)
+	return diagram(g, "graph", MermaidOptions{Orientation: orientation})
 }
 
 func MermaidFlowchart(g *Graph, orientation int) string {
-	return diagram(g, "flowchart", orientation)
+	return diagram(g, "flowchart", MermaidOptions{Orientation: orientation})
+}
+
+// MermaidGraphWithOptions returns the graph as mermaid graph using the options.
+func MermaidGraphWithOptions(g *Graph, options MermaidOptions) string {
+	return diagram(g, "graph", options)
+}
+
+// MermaidFlowchartWithOptions returns the graph as mermaid flowchart using the options.
+func MermaidFlowchartWithOptions(g *Graph, options MermaidOptions) string {
+	return diagram(g, "flowchart", options)
+}
+
+// WriteMermaidGraph writes the graph as mermaid graph, like MermaidGraph, while counting the bytes.
+// Writing stops at the first error of w, which is returned.
+func WriteMermaidGraph(w io.Writer, g *Graph, orientation int) (int64, error) {
+	iw := NewIndentWriter(w)
+	writeDiagram(iw, g, "graph", MermaidOptions{Orientation: orientation})
+	return iw.Written(), iw.Err()
+}
+
+// WriteMermaidFlowchart is WriteMermaidGraph for a mermaid flowchart.
+func WriteMermaidFlowchart(w io.Writer, g *Graph, orientation int) (int64, error) {
+	iw := NewIndentWriter(w)
+	writeDiagram(iw, g, "flowchart", MermaidOptions{Orientation: orientation})
+	return iw.Written(), iw.Err()
 }
 
 func escape(value string) string {
 	return fmt.Sprintf(`"%s"`, html.EscapeString(value))
 }
 
-func diagram(g *Graph, diagramType string, orientation int) string {
-	sb := new(strings.Builder)
-	sb.WriteString(diagramType)
-	sb.WriteRune(' ')
-	switch orientation {
+func diagram(g *Graph, diagramType string, options MermaidOptions) string {
+	b := new(bytes.Buffer)
+	writeDiagram(NewIndentWriter(b), g, diagramType, options)
+	return b.String()
+}
+
+func writeDiagram(out *IndentWriter, g *Graph, diagramType string, options MermaidOptions) {
+	g.rlock()
+	defer g.runlock()
+	title := options.Title
+	if title == "" {
+		if label, ok := g.attributes["label"]; ok {
+			title = mermaidAttributeText(label)
+		}
+	}
+	diagramFrontMatter(out, title, options)
+	out.WriteString(diagramType)
+	out.WriteString(" ")
+	switch options.Orientation {
 	case MermaidTopDown, MermaidTopToBottom:
-		sb.WriteString("TD")
+		out.WriteString("TD")
 	case MermaidBottomToTop:
-		sb.WriteString("BT")
+		out.WriteString("BT")
 	case MermaidRightToLeft:
-		sb.WriteString("RL")
+		out.WriteString("RL")
 	case MermaidLeftToRight:
-		sb.WriteString("LR")
+		out.WriteString("LR")
 	default:
-		sb.WriteString("TD")
+		out.WriteString("TD")
+	}
+	writeEnd(out)
+	accTitle := options.AccTitle
+	if accTitle == "" {
+		accTitle = title
+	}
+	if accTitle != "" {
+		fmt.Fprintf(out, "\taccTitle: %s\n", strings.Join(strings.Fields(accTitle), " "))
+	}
+	accDescr := options.AccDescr
+	if accDescr == "" {
+		if comment, ok := g.attributes["comment"]; ok {
+			accDescr = mermaidAttributeText(comment)
+		}
+	}
+	if accDescr = strings.TrimSpace(accDescr); strings.Contains(accDescr, "\n") {
+		fmt.Fprintf(out, "\taccDescr {\n%s\n\t}\n", strings.Replace(accDescr, "}", "", -1))
+	} else if accDescr != "" {
+		fmt.Fprintf(out, "\taccDescr: %s\n", accDescr)
+	}
+	state := &mermaidState{subgraphIDs: mermaidSubgraphIDs(g), subgraphNames: map[string]string{}}
+	for sub, id := range state.subgraphIDs {
+		state.subgraphNames[sub.id] = id
+	}
+	diagramGraph(g, out, state)
+	diagramSubgraphs(g, out, state)
+	diagramClasses(g, out, options.ClassDefs)
+}
+
+// diagramFrontMatter writes the YAML front matter with the title and the config of the options, if any.
+func diagramFrontMatter(out *IndentWriter, title string, options MermaidOptions) {
+	config := new(strings.Builder)
+	if options.Theme != "" {
+		fmt.Fprintf(config, "  theme: %s\n", yamlString(options.Theme))
+	}
+	if len(options.ThemeVariables) > 0 {
+		config.WriteString("  themeVariables:\n")
+		names := []string{}
+		for each := range options.ThemeVariables {
+			names = append(names, each)
+		}
+		sort.Strings(names)
+		for _, each := range names {
+			fmt.Fprintf(config, "    %s: %s\n", each, yamlString(options.ThemeVariables[each]))
+		}
+	}
+	if options.Curve != "" || options.HTMLLabels != nil {
+		config.WriteString("  flowchart:\n")
+		if options.Curve != "" {
+			fmt.Fprintf(config, "    curve: %s\n", yamlString(options.Curve))
+		}
+		if options.HTMLLabels != nil {
+			fmt.Fprintf(config, "    htmlLabels: %t\n", *options.HTMLLabels)
+		}
+	}
+	if title == "" && config.Len() == 0 {
+		return
+	}
+	out.WriteString("---\n")
+	if title != "" {
+		fmt.Fprintf(out, "title: %s\n", yamlString(title))
+	}
+	if config.Len() > 0 {
+		out.WriteString("config:\n")
+		out.WriteString(config.String())
+	}
+	out.WriteString("---\n")
+}
+
+var yamlPlainPattern = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_ .-]*$`)
+
+// yamlString returns the string as is if it is plain text and not a YAML keyword, double-quoted otherwise.
+func yamlString(s string) string {
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return fmt.Sprintf("%q", s)
+	}
+	if yamlPlainPattern.MatchString(s) && !strings.HasSuffix(s, " ") {
+		return s
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// diagramClasses writes the classDef statements and the class statements for the nodes.
+// A node without a "class" attribute gets a class derived from its fillcolor, color, fontcolor and penwidth, if any.
+// Nodes with the same derived style share a class named c<number>.
+func diagramClasses(g *Graph, out *IndentWriter, classDefs map[string]string) {
+	all := map[string]string{}
+	for name, style := range g.Root().classDefs {
+		all[name] = style
+	}
+	for name, style := range classDefs {
+		all[name] = style
+	}
+	names := []string{}
+	for each := range all {
+		names = append(names, each)
+	}
+	sort.Strings(names)
+	defs := [][2]string{}
+	for _, each := range names {
+		defs = append(defs, [2]string{each, all[each]})
+	}
+	derived := map[string]string{}
+	classOrder := []string{}
+	members := map[string][]string{}
+	var nodes []Node
+	g.collectNodes(&nodes)
+	for _, each := range nodes {
+		var classes []string
+		if c, ok := each.attributes["class"]; ok {
+			classes = strings.Fields(mermaidAttributeText(c))
+		} else if style := mermaidClassStyle(each.graph.effectiveAttributes(each.attributes, true)); style != "" {
+			name, ok := derived[style]
+			if !ok {
+				name = fmt.Sprintf("c%d", len(derived)+1)
+				derived[style] = name
+				defs = append(defs, [2]string{name, style})
+			}
+			classes = []string{name}
+		}
+		for _, class := range classes {
+			if _, ok := members[class]; !ok {
+				classOrder = append(classOrder, class)
+			}
+			members[class] = append(members[class], mermaidNodeID(g, each))
+		}
+	}
+	for _, each := range defs {
+		fmt.Fprintf(out, "\tclassDef %s %s;\n", each[0], each[1])
+	}
+	for _, each := range classOrder {
+		fmt.Fprintf(out, "\tclass %s %s;\n", strings.Join(members[each], ","), each)
+	}
+}
+
+// mermaidClassStyle returns the style of a class for the fillcolor, color, fontcolor and penwidth attributes ; empty if none.
+func mermaidClassStyle(attributes map[string]interface{}) string {
+	parts := []string{}
+	if v, ok := attributes["fillcolor"]; ok {
+		parts = append(parts, "fill:"+mermaidColor(v))
+	}
+	if v, ok := attributes["color"]; ok {
+		parts = append(parts, "stroke:"+mermaidColor(v))
+	}
+	if v, ok := attributes["fontcolor"]; ok {
+		parts = append(parts, "color:"+mermaidColor(v))
+	}
+	if f, ok := floatValue(attributes["penwidth"]); ok {
+		parts = append(parts, fmt.Sprintf("stroke-width:%gpx", f))
+	}
+	return strings.Join(parts, ",")
+}
+
+// mermaidColor returns a CSS color without commas, as these separate the properties of a style.
+func mermaidColor(value interface{}) string {
+	if r, g, b, a, ok := colorRGBA(value); ok && a < 1 {
+		return fmt.Sprintf("#%02x%02x%02x%02x", r, g, b, uint8(a*255+0.5))
+	}
+	return cssColor(value)
+}
+
+// mermaidState holds what is shared by all (sub)graphs while writing a diagram.
+type mermaidState struct {
+	// edgeCount is the number of links written so far
+	edgeCount int
+	// subgraphIDs has the mermaid identifier of each subgraph
+	subgraphIDs map[*Graph]string
+	// subgraphNames maps the id of each subgraph (e.g. cluster_s1), as used by lhead and ltail, to its mermaid identifier
+	subgraphNames map[string]string
+}
+
+// diagramSubgraphs writes the subgraphs of the graph, and their subgraphs, as nested subgraph blocks.
+// The rankdir attribute of a subgraph is written as direction and its colors and style as style statement.
+func diagramSubgraphs(g *Graph, out *IndentWriter, state *mermaidState) {
+	for _, key := range g.orderedSubgraphsKeys() {
+		each := g.subgraphs[key]
+		id := state.subgraphIDs[each]
+		label := key
+		if l, ok := each.attributes["label"]; ok {
+			label = fmt.Sprintf("%v", l)
+		}
+		fmt.Fprintf(out, "subgraph %s [%s];\n", id, mermaidSubgraphLabel(label))
+		if rankdir, ok := each.attributes["rankdir"]; ok {
+			if dir, ok := mermaidDirections[strings.ToUpper(fmt.Sprintf("%v", rankdir))]; ok {
+				fmt.Fprintf(out, "\tdirection %s\n", dir)
+			}
+		}
+		if style := mermaidSubgraphStyle(each.attributes); style != "" {
+			fmt.Fprintf(out, "\tstyle %s %s\n", id, style)
+		}
+		diagramGraph(each, out, state)
+		diagramSubgraphs(each, out, state)
+		fmt.Fprintln(out, "end;")
+	}
+}
+
+// mermaidSubgraphStyle returns the style of a subgraph for the style, fillcolor (or bgcolor), color, fontcolor and penwidth attributes.
+// A style with a colon is taken as mermaid style ; of other styles, filled, dashed, dotted and bold are mapped.
+func mermaidSubgraphStyle(attributes map[string]interface{}) string {
+	if style := mermaidAttributeText(attributes["style"]); attributes["style"] != nil && strings.Contains(style, ":") {
+		return style
+	}
+	parts := []string{}
+	styles := styleValues(attributes["style"])
+	fill, ok := attributes["fillcolor"]
+	if !ok {
+		fill, ok = attributes["bgcolor"]
+	}
+	if !ok {
+		// Graphviz fills a cluster with the color if there is no fillcolor
+		for _, each := range styles {
+			if each == "filled" {
+				fill, ok = attributes["color"]
+			}
+		}
+	}
+	if ok {
+		parts = append(parts, "fill:"+mermaidColor(fill))
 	}
-	writeEnd(sb)
-	diagramGraph(g, sb)
-	for _, id := range g.sortedSubgraphsKeys() {
-		each := g.subgraphs[id]
-		fmt.Fprintf(sb, "subgraph %s [%s];\n", id, each.attributes["label"])
-		diagramGraph(each, sb)
-		fmt.Fprintln(sb, "end;")
+	if v, ok := attributes["pencolor"]; ok {
+		parts = append(parts, "stroke:"+mermaidColor(v))
+	} else if v, ok := attributes["color"]; ok {
+		parts = append(parts, "stroke:"+mermaidColor(v))
 	}
-	return sb.String()
+	if v, ok := attributes["fontcolor"]; ok {
+		parts = append(parts, "color:"+mermaidColor(v))
+	}
+	if f, ok := floatValue(attributes["penwidth"]); ok {
+		parts = append(parts, fmt.Sprintf("stroke-width:%gpx", f))
+	}
+	for _, each := range styles {
+		switch each {
+		case "dashed":
+			parts = append(parts, "stroke-dasharray:5 5")
+		case "dotted":
+			parts = append(parts, "stroke-dasharray:2 2")
+		case "bold":
+			parts = append(parts, "stroke-width:3px")
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// mermaidSubgraphIDs returns the identifier of each subgraph in mermaid output, which must be unique in the diagram.
+// This is the key of the subgraph if that is a valid mermaid identifier that is not used by a node or another subgraph.
+// Otherwise, invalid characters are replaced and the index of the subgraph is added, e.g. "my_api_s3".
+func mermaidSubgraphIDs(root *Graph) map[*Graph]string {
+	used := map[string]bool{}
+	var nodes []Node
+	root.collectNodes(&nodes)
+	for _, each := range nodes {
+		used[mermaidNodeID(root, each)] = true
+	}
+	ids := map[*Graph]string{}
+	var walk func(g *Graph)
+	walk = func(g *Graph) {
+		for _, key := range g.orderedSubgraphsKeys() {
+			each := g.subgraphs[key]
+			id := key
+			if !mermaidIDPattern.MatchString(key) || isMermaidKeyword(key) || used[key] {
+				id = fmt.Sprintf("%s_s%d", mermaidSafeID(key), each.index)
+			}
+			used[id] = true
+			ids[each] = id
+			walk(each)
+		}
+	}
+	walk(root)
+	return ids
 }
 
-func diagramGraph(g *Graph, sb *strings.Builder) {
+var mermaidPlainLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9_ ]*$`)
+
+// mermaidSubgraphLabel returns the label as is if it only has letters, digits, underscores and spaces ; escaped otherwise.
+func mermaidSubgraphLabel(label string) string {
+	if mermaidPlainLabelPattern.MatchString(label) {
+		return label
+	}
+	return escape(label)
+}
+
+// diagramGraph writes the nodes and edges of the graph.
+// An edge with an lhead or ltail attribute that names a subgraph (e.g. cluster_s1) is written as link to or from that subgraph.
+func diagramGraph(g *Graph, out *IndentWriter, state *mermaidState) {
 	// graph nodes
-	for _, key := range g.sortedNodesKeys() {
+	for _, key := range g.orderedNodesKeys() {
 		nodeShape := MermaidShapeRound
 		each := g.nodes[key]
-		if s := each.GetAttr("shape"); s != nil {
+		attributes := each.graph.effectiveAttributes(each.attributes, true)
+		if s := attributes["shape"]; s != nil {
 			// could be a shape or a string
 			shapeString, ok := s.(string)
 			if ok {
@@ -97,101 +415,235 @@ func diagramGraph(g *Graph, sb *strings.Builder) {
 			}
 		}
 		txt := "?"
-		if label := each.GetAttr("label"); label != nil {
+		if label := each.attributes["label"]; label != nil {
 			// take string only
 			slabel, ok := label.(string)
 			if ok {
 				txt = slabel
 			}
 		}
-		fmt.Fprintf(sb, "\tn%d%s%s%s;\n", each.seq, nodeShape.open, escape(txt), nodeShape.close)
-		if style := each.GetAttr("style"); style != nil {
-			fmt.Fprintf(sb, "\tstyle n%d %s\n", each.seq, style.(string))
+		if nodeShape.open == "" {
+			// expanded notation of mermaid v11
+			fmt.Fprintf(out, "\t%s@{ shape: %s, label: %s };\n", mermaidNodeID(g, each), nodeShape.name, escape(txt))
+		} else {
+			fmt.Fprintf(out, "\t%s%s%s%s;\n", mermaidNodeID(g, each), nodeShape.open, escape(txt), nodeShape.close)
+		}
+		// a DOT style such as filled or rounded is not a mermaid style
+		if style := each.attributes["style"]; style != nil && strings.Contains(mermaidAttributeText(style), ":") {
+			fmt.Fprintf(out, "\tstyle %s %s\n", mermaidNodeID(g, each), mermaidAttributeText(style))
+		}
+		if click := mermaidClick(attributes); click != "" {
+			fmt.Fprintf(out, "\tclick %s %s\n", mermaidNodeID(g, each), click)
 		}
 	}
 	// all edges
-	// graph edges
-	denoteEdge := "-->"
-	if g.graphType == "graph" {
-		denoteEdge = "---"
-	}
-	edgeCount := 0
-	for _, each := range g.sortedEdgesFromKeys() {
-		all := g.edgesFrom[each]
-		for _, each := range all {
-			// The edge can override the link style
-			link := denoteEdge
-			if l := each.GetAttr("link"); l != nil {
-				// take string only
-				slink, ok := l.(string)
-				if ok {
-					link = slink
-				}
-			}
-			escapedLabel := ""
-			if label := each.GetAttr("label"); label != nil {
-				slabel, ok := label.(string)
-				if !ok {
-					// make it a string
-					slabel = fmt.Sprintf("%v", label)
-				}
-				if label != "" {
-					escapedLabel = fmt.Sprintf("|%s|", escape(slabel))
-				}
-			}
-			id := ""
-			if edgeNeedsID(each) {
-				id = fmt.Sprintf("e%d@", edgeCount)
+	for _, each := range g.orderedEdges() {
+		from, to := each.from, each.to
+		// The edge can override the link style
+		link, reversed := mermaidLink(g, each)
+		if l := each.attributes["link"]; l != nil {
+			// take string only
+			slink, ok := l.(string)
+			if ok {
+				link, reversed = slink, false
 			}
-			fmt.Fprintf(sb, "\tn%d %s%s%s n%d;\n", each.from.seq, id, link, escapedLabel, each.to.seq)
-			// check for linkStyle
-			if style := each.GetAttr("linkStyle"); style != nil {
-				fmt.Fprintf(sb, "\tlinkStyle %d %s\n", edgeCount, style.(string))
+		}
+		fromID, toID := mermaidNodeID(g, from), mermaidNodeID(g, to)
+		// compound edges
+		if id, ok := state.subgraphNames[fmt.Sprintf("%v", each.attributes["ltail"])]; ok {
+			fromID = id
+		}
+		if id, ok := state.subgraphNames[fmt.Sprintf("%v", each.attributes["lhead"])]; ok {
+			toID = id
+		}
+		if reversed {
+			fromID, toID = toID, fromID
+		}
+		escapedLabel := ""
+		if label := each.attributes["label"]; label != nil && link != "~~~" {
+			slabel, ok := label.(string)
+			if !ok {
+				// make it a string
+				slabel = fmt.Sprintf("%v", label)
 			}
-			// check for animate
-			if animate := each.GetAttr("animate"); animate != nil {
-				fmt.Fprintf(sb, "\te%d@{animate: %s}\n", edgeCount, animate.(string))
+			if label != "" {
+				escapedLabel = fmt.Sprintf("|%s|", escape(slabel))
 			}
-			edgeCount++
 		}
+		id := ""
+		if edgeNeedsID(each) {
+			id = fmt.Sprintf("e%d@", state.edgeCount)
+		}
+		fmt.Fprintf(out, "\t%s %s%s%s %s;\n", fromID, id, link, escapedLabel, toID)
+		// check for linkStyle
+		if style := each.attributes["linkStyle"]; style != nil {
+			fmt.Fprintf(out, "\tlinkStyle %d %s\n", state.edgeCount, mermaidAttributeText(style))
+		} else if style := mermaidLinkStyle(each.attributes); style != "" {
+			fmt.Fprintf(out, "\tlinkStyle %d %s\n", state.edgeCount, style)
+		}
+		// check for animate
+		if animate := each.attributes["animate"]; animate != nil {
+			fmt.Fprintf(out, "\te%d@{animate: %s}\n", state.edgeCount, mermaidAttributeText(animate))
+		}
+		state.edgeCount++
+	}
+}
+
+// mermaidClick returns the link of a click statement for the href (or URL), tooltip and target attributes ;
+// empty if there is no href because mermaid only shows a tooltip for a link or a callback.
+func mermaidClick(attributes map[string]interface{}) string {
+	href, ok := attributes["href"]
+	if !ok {
+		if href, ok = attributes["URL"]; !ok {
+			return ""
+		}
+	}
+	click := fmt.Sprintf(`href "%s"`, strings.Replace(mermaidAttributeText(href), `"`, "%22", -1))
+	if tooltip, ok := attributes["tooltip"]; ok {
+		click += " " + escape(mermaidAttributeText(tooltip))
+	}
+	if target, ok := attributes["target"]; ok {
+		switch t := mermaidAttributeText(target); t {
+		case "_blank", "_self", "_parent", "_top":
+			click += " " + t
+		}
+	}
+	return click
+}
+
+// mermaidAttributeText returns the value of an attribute as text ; a Literal without its quotes.
+func mermaidAttributeText(value interface{}) string {
+	if l, ok := value.(Literal); ok {
+		return unquoteLiteral(l)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// mermaidLink returns the link for the style, dir, arrowhead and arrowtail attributes of the edge:
+// dashed and dotted become a dotted link (-.->), bold a thick link (==>) and invis an invisible link (~~~).
+// The arrows are > (e.g. normal), o (dot, odot) or x (e.g. tee, box), none if the arrowhead is none or the dir is none.
+// An edge with dir=back is reversed because mermaid has no link with only a tail arrow.
+func mermaidLink(g *Graph, e Edge) (link string, reversed bool) {
+	attributes := e.graph.effectiveAttributes(e.attributes, false)
+	dir := "forward"
+	if !g.Root().IsDirected() {
+		dir = "none"
+	}
+	if v, ok := attributes["dir"]; ok {
+		dir = fmt.Sprintf("%v", v)
+	}
+	head, tail := "", ""
+	switch dir {
+	case "forward":
+		head = mermaidArrow(attributes["arrowhead"], ">")
+	case "back":
+		head, reversed = mermaidArrow(attributes["arrowtail"], ">"), true
+	case "both":
+		head = mermaidArrow(attributes["arrowhead"], ">")
+		tail = mermaidArrow(attributes["arrowtail"], "<")
+		if head == "" {
+			// mermaid has no link with only a tail arrow
+			head, tail, reversed = mermaidArrow(attributes["arrowtail"], ">"), "", true
+		}
+	}
+	line := "-"
+	for _, each := range styleValues(attributes["style"]) {
+		switch each {
+		case "dashed", "dotted":
+			line = "."
+		case "bold":
+			line = "="
+		case "invis":
+			return "~~~", false
+		}
+	}
+	switch line {
+	case ".":
+		link = "-.-" + head
+	case "=":
+		if head == "" {
+			head = "="
+		}
+		link = "==" + head
+	default:
+		if head == "" {
+			head = "-"
+		}
+		link = "--" + head
+	}
+	if tail != "" {
+		link = tail + link
+	}
+	return link, reversed
+}
+
+// mermaidArrow returns the mermaid arrow for a Graphviz arrow type ; empty for none.
+func mermaidArrow(arrowType interface{}, normal string) string {
+	if arrowType == nil {
+		return normal
+	}
+	switch s := strings.ToLower(fmt.Sprintf("%v", arrowType)); {
+	case s == "none":
+		return ""
+	case strings.HasSuffix(s, "dot"):
+		return "o"
+	case strings.HasSuffix(s, "tee"), strings.HasSuffix(s, "box"), strings.HasSuffix(s, "crow"):
+		return "x"
+	}
+	return normal
+}
+
+// mermaidLinkStyle returns the style for the color and penwidth of an edge ; empty if none.
+func mermaidLinkStyle(attributes map[string]interface{}) string {
+	parts := []string{}
+	if v, ok := attributes["color"]; ok {
+		parts = append(parts, "stroke:"+mermaidColor(v))
+	}
+	if f, ok := floatValue(attributes["penwidth"]); ok {
+		parts = append(parts, fmt.Sprintf("stroke-width:%gpx", f))
+	}
+	return strings.Join(parts, ",")
+}
+
+var mermaidIDPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// mermaidNodeID returns the identifier of the node in mermaid output.
+// This is n<seq> unless the root graph has the NodeIDOption.
+// Node ids that are not valid mermaid identifiers (or are keywords) are made valid
+// by replacing invalid characters and adding the sequence to keep them unique.
+func mermaidNodeID(g *Graph, n Node) string {
+	if !g.Root().useNodeIDs {
+		return fmt.Sprintf("n%d", n.seq)
+	}
+	if mermaidIDPattern.MatchString(n.id) && !isMermaidKeyword(n.id) {
+		return n.id
+	}
+	return fmt.Sprintf("%s_n%d", mermaidSafeID(n.id), n.seq)
+}
+
+// mermaidSafeID replaces all characters that are not letters, digits or underscores.
+func mermaidSafeID(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+func isMermaidKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "end", "graph", "flowchart", "subgraph", "style", "class", "classdef", "click", "linkstyle", "direction", "call", "href":
+		return true
 	}
+	return false
 }
 
 func edgeNeedsID(e Edge) bool {
 	// TODO more conditions?
-	return e.GetAttr("animate") != nil
-}
-
-func writeEnd(sb *strings.Builder) {
-	sb.WriteString(";\n")
-}
-
-func lookupShape(shapeName string) (shape, bool) {
-	switch shapeName {
-	case "round", "box":
-		return MermaidShapeRound, true
-	case "asymmetric":
-		return MermaidShapeAsymmetric, true
-	case "circle":
-		return MermaidShapeCircle, true
-	case "cylinder":
-		return MermaidShapeCylinder, true
-	case "rhombux":
-		return MermaidShapeRhombus, true
-	case "stadium":
-		return MermaidShapeStadium, true
-	case "subroutine":
-		return MermaidShapeSubroutine, true
-	case "trapezoid":
-		return MermaidShapeTrapezoid, true
-	case "trapezoid-alt":
-		return MermaidShapeTrapezoidAlt, true
-	case "hexagon":
-		return MermaidShapeHexagon, true
-	case "parallelogram":
-		return MermaidShapeParallelogram, true
-	case "parallelogram-alt":
-		return MermaidShapeParallelogramAlt, true
-	}
-	return shape{}, false
+	return e.attributes["animate"] != nil
+}
+
+func writeEnd(out *IndentWriter) {
+	out.WriteString(";\n")
 }
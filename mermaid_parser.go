@@ -0,0 +1,1174 @@
+package dot
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseMermaid reads a mermaid flowchart (graph or flowchart) and returns a directed Graph with its nodes, edges and subgraphs.
+//
+// The direction (e.g. LR) becomes the rankdir attribute. Node texts become labels and node shapes are kept as
+// MermaidShape values for the shape attribute, also those of the expanded notation, e.g. A@{ shape: doc, label: "text" } ;
+// these are written as the Graphviz shape that looks most alike (e.g. oval for a stadium).
+// Subgraphs are created with the ClusterOption, keyed by their mermaid id, and their title is the label.
+// A node is placed in the first subgraph that mentions it, or in the graph if no subgraph does.
+// A link to or from a subgraph becomes an edge to or from its first node with the lhead or ltail attribute, and compound=true.
+// Links keep their label: dotted links (-.->) become dashed edges, thick links (==>) bold and invisible links (~~~) invis.
+// Links without arrow have dir=none, links with two arrows dir=both ; the circle and cross arrows become dot and tee.
+// The style, classDef, class (also :::) and linkStyle statements set the fillcolor, color, fontcolor, penwidth and style attributes.
+// The class names are kept in the class attribute and the classDef statements are written again by MermaidGraph.
+// A classDef named default and linkStyle default set the NodeDefaults and EdgeDefaults.
+// Click statements with a link set the href, tooltip and target attributes ; callbacks are skipped.
+// The title of the front matter (or accTitle) becomes the label of the graph and accDescr its comment.
+// Syntax errors are returned as *SyntaxError with the line and column. The options are applied to the new Graph ;
+// use NodeIDOption to write the mermaid ids again and InsertionOrder to keep the order of declaration.
+func ParseMermaid(r io.Reader, options ...GraphOption) (*Graph, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &mermaidParser{src: []rune(string(data)), line: 1, column: 1, current: -1, linkIDs: map[string]bool{}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.build(options)
+}
+
+// mermaidParser reads all statements before building the graph because a node belongs to the first subgraph
+// that mentions it, which can be after it is used in a link.
+type mermaidParser struct {
+	src          []rune
+	pos          int
+	line, column int
+	direction    string
+	statements   []*mermaidStatement
+	subgraphs    []mermaidSubgraph
+	// title is from the front matter ; accTitle and accDescr from the accessibility statements
+	title, accTitle, accDescr string
+	// linkIDs has the ids of links, e.g. e1 in A e1@--> B
+	linkIDs map[string]bool
+	// current is the index of the open subgraph ; -1 for the graph
+	current int
+}
+
+type mermaidSubgraph struct {
+	key, title string
+	parent     int
+	line       int
+}
+
+// mermaidStatement is a chain of links, a node or one of the keyword statements.
+type mermaidStatement struct {
+	kind         string
+	line, column int
+	// subgraph is the index of the enclosing subgraph ; -1 for the graph
+	subgraph int
+	// groups are the nodes separated by links ; each group can have multiple nodes separated by &
+	groups [][]mermaidNodeRef
+	links  []mermaidLinkRef
+	// ids are the nodes, classes or link indices of a statement
+	ids   []string
+	value string
+	// tooltip and target of a click statement
+	tooltip, target string
+}
+
+type mermaidNodeRef struct {
+	id       string
+	text     string
+	hasText  bool
+	shape    shape
+	hasShape bool
+	classes  []string
+	// data is the content of @{ ... } for a node or a link with an id
+	data map[string]string
+}
+
+type mermaidLinkRef struct {
+	id string
+	// line is one of '-', '.', '=' or '~'
+	line       rune
+	head, tail rune
+	length     int
+	label      string
+	hasLabel   bool
+}
+
+func (p *mermaidParser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: p.line, Column: p.column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *mermaidParser) eof() bool { return p.pos >= len(p.src) }
+
+// peek returns the rune at offset from the current position ; 0 if beyond the input.
+func (p *mermaidParser) peek(offset int) rune {
+	if p.pos+offset >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos+offset]
+}
+
+func (p *mermaidParser) hasPrefix(s string) bool {
+	for i, r := range []rune(s) {
+		if p.peek(i) != r {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *mermaidParser) next() rune {
+	r := p.src[p.pos]
+	p.pos++
+	if r == '\n' {
+		p.line++
+		p.column = 1
+	} else {
+		p.column++
+	}
+	return r
+}
+
+func (p *mermaidParser) skip(s string) {
+	for range []rune(s) {
+		p.next()
+	}
+}
+
+func (p *mermaidParser) skipSpaces() {
+	for !p.eof() && (p.peek(0) == ' ' || p.peek(0) == '\t' || p.peek(0) == '\r') {
+		p.next()
+	}
+}
+
+// atStatementEnd returns true at the end of the input, a line, a statement (;) or at a comment.
+func (p *mermaidParser) atStatementEnd() bool {
+	return p.eof() || p.peek(0) == '\n' || p.peek(0) == ';' || p.hasPrefix("%%")
+}
+
+// endStatement skips the spaces, a comment and the separator that end a statement.
+func (p *mermaidParser) endStatement() error {
+	p.skipSpaces()
+	if !p.atStatementEnd() {
+		return p.errorf("unexpected %q", p.peek(0))
+	}
+	p.skipRest()
+	return nil
+}
+
+// skipRest skips the remainder of the line, or of the statement if it ends with a semicolon.
+func (p *mermaidParser) skipRest() {
+	for !p.eof() {
+		if p.hasPrefix("%%") {
+			for !p.eof() && p.peek(0) != '\n' {
+				p.next()
+			}
+			continue
+		}
+		if r := p.next(); r == '\n' || r == ';' {
+			return
+		}
+	}
+}
+
+// rest returns the remainder of the statement without surrounding spaces.
+func (p *mermaidParser) rest() string {
+	start := p.pos
+	for !p.atStatementEnd() {
+		p.next()
+	}
+	return strings.TrimSpace(string(p.src[start:p.pos]))
+}
+
+func isMermaidIDRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (p *mermaidParser) word() string {
+	start := p.pos
+	for !p.eof() && isMermaidIDRune(p.peek(0)) {
+		p.next()
+	}
+	return string(p.src[start:p.pos])
+}
+
+func (p *mermaidParser) parse() error {
+	p.parseFrontMatter()
+	// header
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			return p.errorf("expected graph or flowchart")
+		}
+		if !p.atStatementEnd() {
+			break
+		}
+		p.skipRest()
+	}
+	if keyword := p.word(); keyword != "graph" && keyword != "flowchart" {
+		return p.errorf("expected graph or flowchart but got %q", keyword)
+	}
+	p.skipSpaces()
+	if !p.atStatementEnd() {
+		dir, err := p.parseDirection()
+		if err != nil {
+			return err
+		}
+		p.direction = dir
+	}
+	if err := p.endStatement(); err != nil {
+		return err
+	}
+	for {
+		p.skipSpaces()
+		if p.eof() {
+			break
+		}
+		if p.atStatementEnd() {
+			p.skipRest()
+			continue
+		}
+		if err := p.parseStatement(); err != nil {
+			return err
+		}
+	}
+	if p.current != -1 {
+		open := p.subgraphs[p.current]
+		return &SyntaxError{Line: open.line, Column: 1, Msg: fmt.Sprintf("subgraph %q has no end", open.key)}
+	}
+	return nil
+}
+
+// parseFrontMatter reads the YAML between --- lines at the start of the input ; only the title is kept.
+func (p *mermaidParser) parseFrontMatter() {
+	for !p.eof() && unicode.IsSpace(p.peek(0)) {
+		p.next()
+	}
+	if !p.hasPrefix("---") {
+		return
+	}
+	p.restOfLine()
+	for !p.eof() {
+		line := p.restOfLine()
+		if strings.HasPrefix(line, "---") {
+			return
+		}
+		if strings.HasPrefix(line, "title:") {
+			p.title = yamlValue(strings.TrimPrefix(line, "title:"))
+		}
+	}
+}
+
+// restOfLine returns the remainder of the line and skips the line end.
+func (p *mermaidParser) restOfLine() string {
+	start := p.pos
+	for !p.eof() && p.peek(0) != '\n' {
+		p.next()
+	}
+	line := string(p.src[start:p.pos])
+	if !p.eof() {
+		p.next()
+	}
+	return strings.TrimSpace(line)
+}
+
+// yamlValue returns a scalar value without its quotes.
+func yamlValue(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			return unquoted
+		}
+	}
+	if len(s) > 1 && strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'") {
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1)
+	}
+	return s
+}
+
+var mermaidDirections = map[string]string{
+	"TB": "TB", "TD": "TB", "BT": "BT", "LR": "LR", "RL": "RL",
+	"v": "TB", "^": "BT", ">": "LR", "<": "RL",
+}
+
+func (p *mermaidParser) parseDirection() (string, error) {
+	word := p.rest()
+	if dir, ok := mermaidDirections[word]; ok {
+		return dir, nil
+	}
+	return "", p.errorf("unknown direction %q", word)
+}
+
+func (p *mermaidParser) parseStatement() error {
+	line, column := p.line, p.column
+	start := p.pos
+	keyword := p.word()
+	// keywords are followed by a space or the end of the statement
+	if !p.eof() && !p.atStatementEnd() && p.peek(0) != ' ' && p.peek(0) != '\t' && keyword != "accTitle" && keyword != "accDescr" {
+		keyword = ""
+	}
+	st := &mermaidStatement{kind: keyword, line: line, column: column, subgraph: p.current}
+	p.skipSpaces()
+	switch keyword {
+	case "subgraph":
+		return p.parseSubgraph(line)
+	case "end":
+		if p.current == -1 {
+			return &SyntaxError{Line: line, Column: column, Msg: "end without subgraph"}
+		}
+		p.current = p.subgraphs[p.current].parent
+		return p.endStatement()
+	case "direction":
+		dir, err := p.parseDirection()
+		if err != nil {
+			return err
+		}
+		st.value = dir
+	case "style", "classDef":
+		ids := p.rest()
+		i := strings.IndexAny(ids, " \t")
+		if i == -1 {
+			return p.errorf("expected %s and style", map[string]string{"style": "id", "classDef": "class"}[keyword])
+		}
+		st.ids = splitTrimmed(ids[:i], ",")
+		st.value = strings.TrimSpace(ids[i:])
+	case "class":
+		ids := p.rest()
+		i := strings.LastIndexAny(ids, " \t")
+		if i == -1 {
+			return p.errorf("expected ids and class")
+		}
+		st.ids = splitTrimmed(ids[:i], ",")
+		st.value = strings.TrimSpace(ids[i:])
+	case "linkStyle":
+		ids := p.rest()
+		i := strings.IndexAny(ids, " \t")
+		if i == -1 {
+			return p.errorf("expected indices and style")
+		}
+		st.ids = splitTrimmed(ids[:i], ",")
+		st.value = strings.TrimSpace(ids[i:])
+	case "click":
+		words := mermaidFields(p.rest())
+		if len(words) < 2 {
+			return p.errorf("expected node id and link")
+		}
+		st.ids, words = words[:1], words[1:]
+		if words[0] == "href" {
+			words = words[1:]
+		} else if !strings.HasPrefix(words[0], `"`) {
+			// a callback
+			return p.endStatement()
+		}
+		if len(words) == 0 || !strings.HasPrefix(words[0], `"`) {
+			return p.errorf("expected link")
+		}
+		st.value = mermaidText(words[0])
+		for _, each := range words[1:] {
+			if strings.HasPrefix(each, `"`) {
+				st.tooltip = mermaidText(each)
+			} else {
+				st.target = each
+			}
+		}
+	case "accTitle":
+		if p.peek(0) != ':' {
+			return p.errorf("expected ':' after accTitle")
+		}
+		p.next()
+		p.accTitle = p.restOfLine()
+		return nil
+	case "accDescr":
+		switch p.peek(0) {
+		case ':':
+			p.next()
+			p.accDescr = p.restOfLine()
+		case '{':
+			line, column := p.line, p.column
+			p.next()
+			start := p.pos
+			for !p.eof() && p.peek(0) != '}' {
+				p.next()
+			}
+			if p.eof() {
+				return &SyntaxError{Line: line, Column: column, Msg: "accDescr has no closing }"}
+			}
+			lines := []string{}
+			for _, each := range strings.Split(string(p.src[start:p.pos]), "\n") {
+				if each = strings.TrimSpace(each); each != "" {
+					lines = append(lines, each)
+				}
+			}
+			p.accDescr = strings.Join(lines, "\n")
+			p.next()
+			return p.endStatement()
+		default:
+			return p.errorf("expected ':' or '{' after accDescr")
+		}
+		return nil
+	default:
+		// not a keyword ; start again
+		p.pos, p.line, p.column = start, line, column
+		st.kind = "chain"
+		if err := p.parseChain(st); err != nil {
+			return err
+		}
+	}
+	p.statements = append(p.statements, st)
+	return p.endStatement()
+}
+
+// mermaidFields splits at spaces that are not within double quotes ; quoted fields keep their quotes.
+func mermaidFields(s string) (list []string) {
+	quoted, start := false, -1
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			if start == -1 {
+				start = i
+			}
+		case (r == ' ' || r == '\t') && !quoted:
+			if start != -1 {
+				list = append(list, s[start:i])
+				start = -1
+			}
+		default:
+			if start == -1 {
+				start = i
+			}
+		}
+	}
+	if start != -1 {
+		list = append(list, s[start:])
+	}
+	return
+}
+
+func splitTrimmed(s, sep string) (list []string) {
+	for _, each := range strings.Split(s, sep) {
+		if each = strings.TrimSpace(each); each != "" {
+			list = append(list, each)
+		}
+	}
+	return
+}
+
+// parseSubgraph reads the id and title of a subgraph: "id", "id[title]", "id [title]" or just a title.
+func (p *mermaidParser) parseSubgraph(line int) error {
+	rest := p.rest()
+	if rest == "" {
+		return p.errorf("expected subgraph id or title")
+	}
+	key, title := rest, rest
+	if i := strings.Index(rest, "["); i > 0 && strings.HasSuffix(rest, "]") {
+		key = strings.TrimSpace(rest[:i])
+		title = mermaidText(strings.TrimSpace(rest[i+1 : len(rest)-1]))
+	} else {
+		key = mermaidText(rest)
+		title = key
+	}
+	p.subgraphs = append(p.subgraphs, mermaidSubgraph{key: key, title: title, parent: p.current, line: line})
+	p.current = len(p.subgraphs) - 1
+	return p.endStatement()
+}
+
+// parseChain reads nodes separated by links, e.g. A & B --> C -->|yes| D, or the data of a node or link: id@{ ... }.
+func (p *mermaidParser) parseChain(st *mermaidStatement) error {
+	for {
+		group := []mermaidNodeRef{}
+		for {
+			ref, err := p.parseNodeRef()
+			if err != nil {
+				return err
+			}
+			group = append(group, ref)
+			p.skipSpaces()
+			if p.peek(0) != '&' {
+				break
+			}
+			p.next()
+			p.skipSpaces()
+		}
+		st.groups = append(st.groups, group)
+		if p.atStatementEnd() {
+			return nil
+		}
+		link, err := p.parseLink()
+		if err != nil {
+			return err
+		}
+		st.links = append(st.links, link)
+		p.skipSpaces()
+	}
+}
+
+// mermaidShapeSyntax lists the shapes, longest open first, to find the shape of a node.
+var mermaidShapeSyntax = []shape{
+	MermaidShapeDoubleCircle,
+	MermaidShapeStadium, MermaidShapeCircle, MermaidShapeSubroutine, MermaidShapeCylinder,
+	MermaidShapeParallelogram, MermaidShapeTrapezoid, MermaidShapeParallelogramAlt, MermaidShapeTrapezoidAlt, MermaidShapeHexagon,
+	MermaidShapeRound, MermaidShapeRectangle, MermaidShapeRhombus, MermaidShapeAsymmetric,
+}
+
+func (p *mermaidParser) parseNodeRef() (mermaidNodeRef, error) {
+	ref := mermaidNodeRef{}
+	if ref.id = p.word(); ref.id == "" {
+		if p.atStatementEnd() {
+			return ref, p.errorf("expected node id")
+		}
+		return ref, p.errorf("expected node id but got %q", p.peek(0))
+	}
+	// all shapes with the longest open that matches
+	candidates := []shape{}
+	for _, each := range mermaidShapeSyntax {
+		if p.hasPrefix(each.open) && (len(candidates) == 0 || len(candidates[0].open) == len(each.open)) {
+			candidates = append(candidates, each)
+		}
+	}
+	if len(candidates) > 0 {
+		line, column := p.line, p.column
+		p.skip(candidates[0].open)
+		text, found, ok, err := p.parseShapeText(candidates)
+		if err != nil {
+			return ref, err
+		}
+		if !ok {
+			return ref, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("node %q has no closing %q", ref.id, candidates[0].close)}
+		}
+		ref.shape, ref.hasShape = found, true
+		ref.text, ref.hasText = text, true
+	}
+	if p.hasPrefix("@{") {
+		p.next()
+		line, column := p.line, p.column
+		data, err := p.parseData()
+		if err != nil {
+			return ref, err
+		}
+		ref.data = data
+		if name, ok := data["shape"]; ok {
+			s, ok := lookupShape(name)
+			if !ok {
+				return ref, &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf("unknown shape %q", name)}
+			}
+			ref.shape, ref.hasShape = s, true
+		}
+		if label, ok := data["label"]; ok {
+			ref.text, ref.hasText = label, true
+		}
+	}
+	for p.hasPrefix(":::") {
+		p.skip(":::")
+		class := p.word()
+		if class == "" {
+			return ref, p.errorf("expected class name after :::")
+		}
+		ref.classes = append(ref.classes, class)
+	}
+	return ref, nil
+}
+
+// parseShapeText reads the (quoted) text of a node up to the close of one of the candidate shapes ;
+// ok is false if none is found on the same line.
+func (p *mermaidParser) parseShapeText(candidates []shape) (text string, found shape, ok bool, err error) {
+	closing := func() (shape, bool) {
+		for _, each := range candidates {
+			if p.hasPrefix(each.close) {
+				return each, true
+			}
+		}
+		return shape{}, false
+	}
+	if p.peek(0) == '"' {
+		quoted, err := p.parseQuoted()
+		if err != nil {
+			return "", shape{}, false, err
+		}
+		p.skipSpaces()
+		if s, ok := closing(); ok {
+			p.skip(s.close)
+			return quoted, s, true, nil
+		}
+		return "", shape{}, false, nil
+	}
+	start := p.pos
+	for !p.eof() && p.peek(0) != '\n' {
+		if s, ok := closing(); ok {
+			text = string(p.src[start:p.pos])
+			p.skip(s.close)
+			return mermaidText(strings.TrimSpace(text)), s, true, nil
+		}
+		p.next()
+	}
+	return "", shape{}, false, nil
+}
+
+// parseQuoted reads a double-quoted text ; entities are unescaped.
+func (p *mermaidParser) parseQuoted() (string, error) {
+	line, column := p.line, p.column
+	p.next()
+	start := p.pos
+	for !p.eof() && p.peek(0) != '"' {
+		p.next()
+	}
+	if p.eof() {
+		return "", &SyntaxError{Line: line, Column: column, Msg: "unterminated string"}
+	}
+	text := string(p.src[start:p.pos])
+	p.next()
+	return mermaidText(text), nil
+}
+
+// mermaidEntityPattern matches a mermaid entity code such as #quot; or #35; that is not an HTML entity like &#35;.
+var mermaidEntityPattern = regexp.MustCompile(`(^|[^&])#([a-zA-Z]+|[0-9]+);`)
+
+// mermaidDataPattern matches a key and value of the data of a node or link, e.g. animate: true.
+var mermaidDataPattern = regexp.MustCompile(`([a-zA-Z_]+)\s*:\s*("[^"]*"|[^,}]*)`)
+
+// mermaidText removes the quotes of a text and unescapes HTML entities and mermaid entity codes like #quot; and #35;.
+func mermaidText(s string) string {
+	if len(s) > 1 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		s = s[1 : len(s)-1]
+	}
+	if strings.Contains(s, "#") {
+		s = mermaidEntityPattern.ReplaceAllStringFunc(s, func(m string) string {
+			return strings.Replace(m, "#", "&", 1)
+		})
+	}
+	return html.UnescapeString(s)
+}
+
+// parseLink reads a link with its optional id, label and arrows, e.g. e1@-->, -.->, ==>|label|, -- label --> or <-->.
+func (p *mermaidParser) parseLink() (mermaidLinkRef, error) {
+	link := mermaidLinkRef{}
+	// id@
+	start, line, column := p.pos, p.line, p.column
+	if id := p.word(); id != "" && p.peek(0) == '@' {
+		p.next()
+		link.id = id
+		p.linkIDs[id] = true
+	} else {
+		p.pos, p.line, p.column = start, line, column
+	}
+	if r := p.peek(0); r == '<' || ((r == 'o' || r == 'x') && (p.peek(1) == '-' || p.peek(1) == '=')) {
+		link.tail = p.next()
+	}
+	open, err := p.parseLinkLine(&link)
+	if err != nil {
+		return link, err
+	}
+	if open {
+		// -- label -->, -. label .-> or == label ==>
+		if err := p.parseLinkText(&link); err != nil {
+			return link, err
+		}
+	}
+	p.skipSpaces()
+	if p.peek(0) == '|' {
+		p.next()
+		start := p.pos
+		for !p.eof() && p.peek(0) != '|' && p.peek(0) != '\n' {
+			p.next()
+		}
+		if p.peek(0) != '|' {
+			return link, p.errorf("link label has no closing |")
+		}
+		link.label, link.hasLabel = mermaidText(strings.TrimSpace(string(p.src[start:p.pos]))), true
+		p.next()
+	}
+	return link, nil
+}
+
+// parseLinkLine reads the line and head of a link ; open is true if it is the start of a link with text.
+func (p *mermaidParser) parseLinkLine(link *mermaidLinkRef) (open bool, err error) {
+	count := 0
+	switch p.peek(0) {
+	case '-':
+		p.next()
+		link.line = '-'
+		if p.peek(0) == '.' {
+			link.line = '.'
+			for p.peek(0) == '.' {
+				p.next()
+				count++
+			}
+			if p.peek(0) != '-' {
+				return true, nil
+			}
+			p.next()
+		} else {
+			count = 1
+			for p.peek(0) == '-' {
+				p.next()
+				count++
+			}
+			if count == 2 && !isMermaidArrow(p.peek(0), p.peek(1)) {
+				return true, nil
+			}
+			count--
+		}
+	case '=':
+		link.line = '='
+		for p.peek(0) == '=' {
+			p.next()
+			count++
+		}
+		if count == 2 && !isMermaidArrow(p.peek(0), p.peek(1)) {
+			return true, nil
+		}
+		count--
+	case '~':
+		link.line = '~'
+		for p.peek(0) == '~' {
+			p.next()
+			count++
+		}
+		if count < 3 {
+			return false, p.errorf("expected ~~~")
+		}
+		link.length = count - 2
+		return false, nil
+	default:
+		if p.atStatementEnd() {
+			return false, p.errorf("expected link")
+		}
+		return false, p.errorf("expected link but got %q", p.peek(0))
+	}
+	if count < 1 {
+		return false, p.errorf("incomplete link")
+	}
+	if isMermaidArrow(p.peek(0), p.peek(1)) {
+		link.head = p.next()
+	} else if link.line != '.' {
+		// a link without arrow has one more line character, e.g. ---
+		count--
+	}
+	if count < 1 {
+		return false, p.errorf("incomplete link")
+	}
+	link.length = count
+	return false, nil
+}
+
+// isMermaidArrow returns true if r is an arrow head ; o and x only if not followed by a letter of a node id.
+func isMermaidArrow(r, next rune) bool {
+	return r == '>' || ((r == 'o' || r == 'x') && !isMermaidIDRune(next))
+}
+
+// parseLinkText reads the text of a link such as -- text --> up to the second part of the link.
+func (p *mermaidParser) parseLinkText(link *mermaidLinkRef) error {
+	line, column := p.line, p.column
+	start := p.pos
+	for !p.eof() && p.peek(0) != '\n' {
+		end := p.pos
+		switch {
+		case link.line == '-' && p.hasPrefix("--"), link.line == '=' && p.hasPrefix("=="), link.line == '.' && p.hasPrefix(".-"):
+			link.label, link.hasLabel = mermaidText(strings.TrimSpace(string(p.src[start:end]))), true
+			if link.line == '.' {
+				p.skip(".-")
+				if isMermaidArrow(p.peek(0), p.peek(1)) {
+					link.head = p.next()
+				}
+				link.length = 1
+				return nil
+			}
+			closing := mermaidLinkRef{}
+			if _, err := p.parseLinkLine(&closing); err != nil {
+				return err
+			}
+			link.head, link.length = closing.head, closing.length
+			return nil
+		}
+		p.next()
+	}
+	return &SyntaxError{Line: line, Column: column, Msg: "link text has no end of link"}
+}
+
+// parseData reads the data of a node or link, e.g. { animate: true }, as keys and unquoted values.
+func (p *mermaidParser) parseData() (map[string]string, error) {
+	line, column := p.line, p.column
+	p.next()
+	start := p.pos
+	for !p.eof() && p.peek(0) != '}' {
+		if p.peek(0) == '"' {
+			if _, err := p.parseQuoted(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		p.next()
+	}
+	if p.eof() {
+		return nil, &SyntaxError{Line: line, Column: column, Msg: "data has no closing }"}
+	}
+	body := string(p.src[start:p.pos])
+	p.next()
+	data := map[string]string{}
+	for _, each := range mermaidDataPattern.FindAllStringSubmatch(body, -1) {
+		value := each[2]
+		if strings.HasPrefix(value, `"`) {
+			value = mermaidText(value)
+		}
+		data[each[1]] = strings.TrimSpace(value)
+	}
+	return data, nil
+}
+
+// build creates the graph from the statements.
+func (p *mermaidParser) build(options []GraphOption) (*Graph, error) {
+	g := NewGraph(Directed)
+	for _, each := range options {
+		each.Apply(g)
+	}
+	if p.title != "" {
+		g.Attr("label", p.title)
+	} else if p.accTitle != "" {
+		g.Attr("label", p.accTitle)
+	}
+	if p.accDescr != "" {
+		g.Attr("comment", p.accDescr)
+	}
+	if p.direction != "" && p.direction != "TB" {
+		g.Attr("rankdir", p.direction)
+	}
+	graphs := make([]*Graph, len(p.subgraphs))
+	subgraphsByKey := map[string]*Graph{}
+	for i, each := range p.subgraphs {
+		parent := g
+		if each.parent != -1 {
+			parent = graphs[each.parent]
+		}
+		graphs[i] = parent.Subgraph(each.key, ClusterOption{})
+		graphs[i].Attr("label", each.title)
+		subgraphsByKey[each.key] = graphs[i]
+	}
+	graphOf := func(index int) *Graph {
+		if index == -1 {
+			return g
+		}
+		return graphs[index]
+	}
+	// a node belongs to the first subgraph that mentions it
+	home := map[string]int{}
+	order := []string{}
+	for _, st := range p.statements {
+		for _, group := range st.groups {
+			for _, ref := range group {
+				if p.linkIDs[ref.id] {
+					continue
+				}
+				if _, ok := subgraphsByKey[ref.id]; ok {
+					continue
+				}
+				index, ok := home[ref.id]
+				if !ok {
+					order = append(order, ref.id)
+				}
+				if !ok || (index == -1 && st.subgraph != -1) {
+					home[ref.id] = st.subgraph
+				}
+			}
+		}
+	}
+	// a link to or from a subgraph uses its first node and the lhead or ltail attribute
+	firstNode := map[int]string{}
+	for _, id := range order {
+		for index := home[id]; index != -1; index = p.subgraphs[index].parent {
+			if _, ok := firstNode[index]; !ok {
+				firstNode[index] = id
+			}
+		}
+	}
+	nodes := map[string]Node{}
+	node := func(id string) Node {
+		n, ok := nodes[id]
+		if !ok {
+			index, ok := home[id]
+			if !ok {
+				index = -1
+			}
+			n = graphOf(index).Node(id)
+			nodes[id] = n
+		}
+		return n
+	}
+	// endpoint returns the node of a link end ; for a subgraph that is its first node and the subgraph
+	endpoint := func(id string, st *mermaidStatement) (Node, *Graph, error) {
+		sub, ok := subgraphsByKey[id]
+		if !ok {
+			return nodes[id], nil, nil
+		}
+		for i, each := range graphs {
+			if each == sub {
+				if first, ok := firstNode[i]; ok {
+					return node(first), sub, nil
+				}
+			}
+		}
+		return Node{}, nil, &SyntaxError{Line: st.line, Column: st.column, Msg: fmt.Sprintf("subgraph %q has no nodes to link", id)}
+	}
+	edges := []Edge{}
+	edgesByID := map[string]Edge{}
+	// class members
+	classes := map[string][]AttributesMap{}
+	classOrder := []string{}
+	addToClass := func(name string, a AttributesMap) {
+		if _, ok := classes[name]; !ok {
+			classOrder = append(classOrder, name)
+		}
+		classes[name] = append(classes[name], a)
+	}
+	classDefs := map[string]string{}
+	for _, st := range p.statements {
+		switch st.kind {
+		case "chain":
+			// the data of a link, e.g. e1@{ animate: true }
+			if len(st.groups) == 1 && len(st.groups[0]) == 1 && p.linkIDs[st.groups[0][0].id] {
+				ref := st.groups[0][0]
+				if e, ok := edgesByID[ref.id]; ok {
+					if v, ok := ref.data["animate"]; ok {
+						e.Attr("animate", v)
+					}
+					continue
+				}
+				return nil, &SyntaxError{Line: st.line, Column: st.column, Msg: fmt.Sprintf("link %q is used before it is defined", ref.id)}
+			}
+			for _, group := range st.groups {
+				for _, ref := range group {
+					if _, ok := subgraphsByKey[ref.id]; ok {
+						continue
+					}
+					n := node(ref.id)
+					if ref.hasText {
+						n.Attr("label", ref.text)
+					}
+					if ref.hasShape {
+						n.Attr("shape", ref.shape)
+					}
+					for _, each := range ref.classes {
+						addToClass(each, n.AttributesMap)
+					}
+				}
+			}
+			for i, link := range st.links {
+				for _, from := range st.groups[i] {
+					for _, to := range st.groups[i+1] {
+						fromNode, fromSub, err := endpoint(from.id, st)
+						if err != nil {
+							return nil, err
+						}
+						toNode, toSub, err := endpoint(to.id, st)
+						if err != nil {
+							return nil, err
+						}
+						e := fromNode.Edge(toNode)
+						if fromSub != nil {
+							e.Attr("ltail", fromSub.id)
+						}
+						if toSub != nil {
+							e.Attr("lhead", toSub.id)
+						}
+						if fromSub != nil || toSub != nil {
+							g.Attr("compound", "true")
+						}
+						mermaidLinkAttributes(e, link)
+						edges = append(edges, e)
+						if link.id != "" {
+							edgesByID[link.id] = e
+						}
+					}
+				}
+			}
+		case "direction":
+			if st.value != "TB" || st.subgraph != -1 {
+				graphOf(st.subgraph).Attr("rankdir", st.value)
+			}
+		case "classDef":
+			for _, each := range st.ids {
+				classDefs[each] = st.value
+			}
+		case "class":
+			for _, each := range st.ids {
+				if sub, ok := subgraphsByKey[each]; ok {
+					addToClass(st.value, sub.AttributesMap)
+				} else {
+					addToClass(st.value, node(each).AttributesMap)
+				}
+			}
+		case "style":
+			for _, each := range st.ids {
+				if sub, ok := subgraphsByKey[each]; ok {
+					mermaidStyleAttributes(sub.AttributesMap, st.value, true)
+				} else {
+					mermaidStyleAttributes(node(each).AttributesMap, st.value, true)
+				}
+			}
+		case "click":
+			n := node(st.ids[0])
+			n.Attr("href", st.value)
+			if st.tooltip != "" {
+				n.Attr("tooltip", st.tooltip)
+			}
+			if st.target != "" {
+				n.Attr("target", st.target)
+			}
+		case "linkStyle":
+			for _, each := range st.ids {
+				if each == "default" {
+					mermaidStyleAttributes(g.EdgeDefaults(), st.value, false)
+					continue
+				}
+				index, err := strconv.Atoi(each)
+				if err != nil || index < 0 || index >= len(edges) {
+					return nil, &SyntaxError{Line: st.line, Column: st.column, Msg: fmt.Sprintf("linkStyle has invalid link index %q", each)}
+				}
+				mermaidStyleAttributes(edges[index].AttributesMap, st.value, false)
+			}
+		}
+	}
+	if style, ok := classDefs["default"]; ok {
+		mermaidStyleAttributes(g.NodeDefaults(), style, true)
+	}
+	for _, name := range classOrder {
+		for _, each := range classes[name] {
+			mermaidAddClass(each, name)
+		}
+		style, ok := classDefs[name]
+		if !ok {
+			continue
+		}
+		for _, each := range classes[name] {
+			mermaidStyleAttributes(each, style, true)
+		}
+	}
+	g.classDefs = classDefs
+	return g, nil
+}
+
+// mermaidLinkAttributes sets the style, dir, arrowhead, arrowtail, label and minlen attributes of the edge for the link.
+func mermaidLinkAttributes(e Edge, link mermaidLinkRef) {
+	switch link.line {
+	case '.':
+		e.Attr("style", "dashed")
+	case '=':
+		e.Attr("style", "bold")
+	case '~':
+		e.Attr("style", "invis")
+	}
+	arrows := map[rune]string{'o': "dot", 'x': "tee"}
+	switch {
+	case link.head != 0 && link.tail != 0:
+		e.Attr("dir", "both")
+	case link.tail != 0:
+		e.Attr("dir", "back")
+	case link.head == 0 && link.line != '~':
+		e.Attr("dir", "none")
+	}
+	if arrow, ok := arrows[link.head]; ok {
+		e.Attr("arrowhead", arrow)
+	}
+	if arrow, ok := arrows[link.tail]; ok {
+		e.Attr("arrowtail", arrow)
+	}
+	if link.hasLabel {
+		e.Attr("label", link.label)
+	}
+	if link.length > 1 {
+		e.Attr("minlen", link.length)
+	}
+}
+
+// mermaidStyleAttributes sets the Graphviz attributes for the CSS properties of a mermaid style:
+// fill (if filled), stroke, color, stroke-width and stroke-dasharray.
+func mermaidStyleAttributes(a AttributesMap, css string, filled bool) {
+	for _, each := range mermaidStyleSplit(css) {
+		kv := strings.SplitN(each, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		switch key {
+		case "fill":
+			if filled {
+				a.Attr("fillcolor", mermaidDotColor(value))
+				mermaidAddStyle(a, "filled")
+			}
+		case "stroke":
+			a.Attr("color", mermaidDotColor(value))
+		case "color":
+			a.Attr("fontcolor", mermaidDotColor(value))
+		case "stroke-width":
+			if f, err := strconv.ParseFloat(strings.TrimSuffix(value, "px"), 64); err == nil {
+				a.Attr("penwidth", f)
+			}
+		case "stroke-dasharray":
+			mermaidAddStyle(a, "dashed")
+		}
+	}
+}
+
+// mermaidStyleSplit splits the properties of a style at the commas that are not within parentheses.
+func mermaidStyleSplit(css string) (list []string) {
+	depth, start := 0, 0
+	for i, r := range css {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				list = append(list, css[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(list, css[start:])
+}
+
+// mermaidAddClass adds a name to the space separated class attribute unless present.
+func mermaidAddClass(a AttributesMap, name string) {
+	current := []string{}
+	if v, ok := a.attributes["class"]; ok {
+		current = strings.Fields(mermaidAttributeText(v))
+	}
+	for _, each := range current {
+		if each == name {
+			return
+		}
+	}
+	a.Attr("class", strings.Join(append(current, name), " "))
+}
+
+// mermaidAddStyle adds a value to the style attribute unless present.
+func mermaidAddStyle(a AttributesMap, value string) {
+	current := a.Value("style")
+	for _, each := range styleValues(current) {
+		if each == value {
+			return
+		}
+	}
+	if current == nil || current == "" {
+		a.Attr("style", value)
+		return
+	}
+	a.Attr("style", fmt.Sprintf("%v,%s", current, value))
+}
+
+// mermaidDotColor returns the color for Graphviz, which does not accept the short hex notation (#f96).
+func mermaidDotColor(value string) string {
+	if strings.HasPrefix(value, "#") && (len(value) == 4 || len(value) == 5) {
+		long := "#"
+		for _, r := range value[1:] {
+			long += string(r) + string(r)
+		}
+		return long
+	}
+	return value
+}
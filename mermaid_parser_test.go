@@ -0,0 +1,380 @@
+package dot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMermaidSimple(t *testing.T) {
+	g, err := ParseMermaid(strings.NewReader("flowchart LR\n  A[Start] -->|go| B(End)\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph  {rankdir="LR";n1[label="Start",shape="box"];n2[label="End",shape="box"];n1->n2[label="go"];}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := flatten(MermaidFlowchart(g, MermaidLeftToRight)), `flowchart LR;n1["Start"];n2("End");n1 -->|"go"| n2;`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidShapes(t *testing.T) {
+	src := `graph TD
+	a[rect] & b(round) & c([stadium]) & d[[sub]] & e[(db)]
+	f((circle)) & g(((double))) & h>asym] & i{rhombus} & j{{hex}}
+	k[/para/] & l[\alt\] & m[/trap\] & n[\inv/] & o["quoted ] text"]`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id    string
+		shape shape
+		label string
+	}{
+		{"a", MermaidShapeRectangle, "rect"},
+		{"b", MermaidShapeRound, "round"},
+		{"c", MermaidShapeStadium, "stadium"},
+		{"d", MermaidShapeSubroutine, "sub"},
+		{"e", MermaidShapeCylinder, "db"},
+		{"f", MermaidShapeCircle, "circle"},
+		{"g", MermaidShapeDoubleCircle, "double"},
+		{"h", MermaidShapeAsymmetric, "asym"},
+		{"i", MermaidShapeRhombus, "rhombus"},
+		{"j", MermaidShapeHexagon, "hex"},
+		{"k", MermaidShapeParallelogram, "para"},
+		{"l", MermaidShapeParallelogramAlt, "alt"},
+		{"m", MermaidShapeTrapezoid, "trap"},
+		{"n", MermaidShapeTrapezoidAlt, "inv"},
+		{"o", MermaidShapeRectangle, "quoted ] text"},
+	}
+	for _, each := range tests {
+		n, ok := g.FindNodeById(each.id)
+		if !ok {
+			t.Fatalf("missing node %s", each.id)
+		}
+		if got, want := n.Value("shape"), each.shape; got != want {
+			t.Errorf("%s: got [%v] want [%v]", each.id, got, want)
+		}
+		if got, want := n.Value("label"), each.label; got != want {
+			t.Errorf("%s: got [%v] want [%v]", each.id, got, want)
+		}
+	}
+	if got, want := flatten(MermaidGraph(g, MermaidTopDown)), `graph TD;a["rect"];b("round");c(["stadium"]);d[["sub"]];e[("db")];`+
+		`f(("circle"));g((("double")));h>"asym"];i{"rhombus"};j{{"hex"}};k[/"para"/];l[\"alt"\];m[/"trap"\];n[\"inv"/];o["quoted ] text"];`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// the DOT shapes
+	if got, want := g.String(), `d[label="sub",shape="component"]`; !strings.Contains(got, want) {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidLinks(t *testing.T) {
+	src := `flowchart LR
+	a --> b
+	a --- b
+	a -.-> b
+	a ==> b
+	a ~~~ b
+	a <--> b
+	a --o b
+	a --x b
+	a -- text --> b
+	a -. dotted .-> b
+	a == thick ==> b
+	a ----> b
+	a e1@--> b
+	e1@{ animate: true }`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(MermaidFlowchart(g, MermaidLeftToRight)), `flowchart LR;a("a");b("b");`+
+		`a --> b;a --- b;a -.-> b;a ==> b;a ~~~ b;a <--> b;a --o b;a --x b;`+
+		`a -->|"text"| b;a -.->|"dotted"| b;a ==>|"thick"| b;a --> b;a e12@--> b;e12@{animate: true}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	edges := g.FindEdges(g.Node("a"), g.Node("b"))
+	if got, want := edges[11].Value("minlen"), 3; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := edges[9].Value("style"), "dashed"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidChainsAndSubgraphs(t *testing.T) {
+	src := `%% comment
+graph TB
+	c1 --> a2
+	subgraph one [first one]
+		a1 --> a2
+		subgraph inner
+			direction LR
+			x & y --> z
+		end
+	end
+	subgraph my group
+		c1
+	end; c1 --> x`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	one, ok := g.FindSubgraph("one")
+	if !ok {
+		t.Fatal("missing subgraph one")
+	}
+	if got, want := one.Value("label"), "first one"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	inner, _ := one.FindSubgraph("inner")
+	if got, want := inner.Value("rankdir"), "LR"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(inner.FindNodes()), 3; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	group, _ := g.FindSubgraph("my group")
+	if _, ok := group.nodes["c1"]; !ok {
+		t.Error("c1 must be in my group")
+	}
+	if _, ok := one.nodes["a2"]; !ok {
+		t.Error("a2 must be in one")
+	}
+	var edges []Edge
+	g.collectEdges(&edges)
+	if got, want := len(edges), 5; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidStyles(t *testing.T) {
+	src := `flowchart LR
+	a:::hot --> b --> c
+	style b fill:#f9f,stroke:#333,stroke-width:4px
+	classDef hot fill:red,color:white
+	classDef default stroke:gray
+	class c hot
+	linkStyle 1 stroke:blue,stroke-width:2px,stroke-dasharray: 5 5
+	linkStyle default stroke:green`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := g.Node("a"), g.Node("b"), g.Node("c")
+	if got, want := b.Value("fillcolor"), "#ff99ff"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := b.Value("penwidth"), 4.0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	for _, each := range []Node{a, c} {
+		if got, want := each.Value("fillcolor"), "red"; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+		if got, want := each.Value("fontcolor"), "white"; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+		if got, want := each.Value("style"), "filled"; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+	if got, want := g.NodeDefaults().Value("color"), "gray"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := g.EdgeDefaults().Value("color"), "green"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	e := g.FindEdges(b, c)[0]
+	if got, want := e.Value("color"), "blue"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := e.Value("style"), "dashed"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidClassesRoundTrip(t *testing.T) {
+	src := `flowchart TD
+	A --> B:::small
+	classDef big font-size:20px
+	classDef small fill:#eee
+	class A,B big`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Node("B").Value("class"), "small big"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := flatten(MermaidFlowchart(g, MermaidTopDown)), `flowchart TD;A("A");B("B");A --> B;classDef big font-size:20px;classDef small fill:#eee;class A,B big;class B small;`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src          string
+		line, column int
+	}{
+		{"sequenceDiagram\n", 1, 16},
+		{"graph XY\n", 1, 9},
+		{"graph TD\n  a[open\n", 2, 4},
+		{"graph TD\n  a --> \n", 2, 9},
+		{"graph TD\n  end\n", 2, 3},
+		{"graph TD\n  subgraph one\n  a\n", 2, 1},
+		{"graph TD\n  a --> b\n  linkStyle 3 stroke:red\n", 3, 3},
+		{"graph TD\n  a -- text b\n", 2, 7},
+		{"graph TD\n  a b\n", 2, 5},
+	}
+	for _, each := range tests {
+		_, err := ParseMermaid(strings.NewReader(each.src))
+		if err == nil {
+			t.Errorf("expected error for %q", each.src)
+			continue
+		}
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected *SyntaxError for %q, got %T", each.src, err)
+			continue
+		}
+		if syntaxErr.Line != each.line || syntaxErr.Column != each.column {
+			t.Errorf("%q: got line %d column %d want line %d column %d (%v)", each.src, syntaxErr.Line, syntaxErr.Column, each.line, each.column, err)
+		}
+	}
+}
+
+func TestParseMermaidRoundTrip(t *testing.T) {
+	di := NewGraph(Directed, NodeIDOption{})
+	sub := di.Subgraph("api")
+	a := di.Node("a").Attr("shape", MermaidShapeCylinder).Label("a & b")
+	b := sub.Node("b").Attr("shape", MermaidShapeHexagon)
+	a.Edge(b, "x").Dotted()
+	b.Edge(a).Bold().Attr("dir", "both")
+	src := MermaidFlowchart(di, MermaidLeftToRight)
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := MermaidFlowchart(g, MermaidLeftToRight), src; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidExpandedShapes(t *testing.T) {
+	src := `flowchart TD
+	a@{ shape: doc, label: "spec, design" } --> b@{ shape: lined-cylinder }
+	c@{ shape: diamond }`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(MermaidFlowchart(g, MermaidTopDown)), `flowchart TD;a@{ shape: doc, label: "spec, design" };b@{ shape: lin-cyl, label: "b" };c{"c"};a --> b;`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if _, err := ParseMermaid(strings.NewReader("flowchart TD\n  a@{ shape: blob }")); err == nil {
+		t.Error("expected error for unknown shape")
+	}
+}
+
+func TestParseMermaidClick(t *testing.T) {
+	src := `flowchart LR
+	a --> b
+	click a href "subsystem.svg" "the sub system" _blank
+	click b "https://example.com"
+	click a callback "ignored"`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := g.Node("a"), g.Node("b")
+	if got, want := a.Value("href"), "subsystem.svg"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := a.Value("tooltip"), "the sub system"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := a.Value("target"), "_blank"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := b.Value("href"), "https://example.com"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidFrontMatterAndAccessibility(t *testing.T) {
+	src := `---
+title: "Order: flow"
+config:
+  theme: base
+---
+flowchart LR
+	accTitle: Orders
+	accDescr {
+		How an order
+		is processed
+	}
+	a --> b`
+	g, err := ParseMermaid(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Value("label"), "Order: flow"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := g.Value("comment"), "How an order\nis processed"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(g.FindNodes()), 2; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMermaidSubgraphLinks(t *testing.T) {
+	src := `flowchart LR
+	c1-->a2
+	subgraph one
+		direction TB
+		a1-->a2
+	end
+	subgraph two
+		b1-->b2
+	end
+	one --> two
+	c1 --> two
+	style one fill:#f9f`
+	g, err := ParseMermaid(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.Value("compound"), "true"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	one, _ := g.FindSubgraph("one")
+	if got, want := one.Value("rankdir"), "TB"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	two, _ := g.FindSubgraph("two")
+	a2, _ := g.FindNodeById("a2")
+	b1, _ := g.FindNodeById("b1")
+	edges := g.FindEdges(a2, b1)
+	if len(edges) != 1 {
+		t.Fatalf("expected one edge a2->b1, got %d", len(edges))
+	}
+	if got, want := edges[0].Value("ltail"), one.GetID(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := edges[0].Value("lhead"), two.GetID(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := flatten(MermaidFlowchart(g, MermaidLeftToRight)), `flowchart LR;c1("c1");one --> two;c1 --> a2;c1 --> two;`+
+		`subgraph one [one];direction TBstyle one fill:#ff99ffa1("a1");a2("a2");a1 --> a2;end;`+
+		`subgraph two [two];b1("b1");b2("b2");b1 --> b2;end;`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if _, err := ParseMermaid(strings.NewReader("flowchart LR\n\tsubgraph empty\n\tend\n\ta --> empty")); err == nil {
+		t.Error("expected error for link to subgraph without nodes")
+	}
+}
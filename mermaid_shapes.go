@@ -0,0 +1,264 @@
+package dot
+
+import "strings"
+
+// The shapes with a bracket notation, e.g. A([text]) for a stadium.
+var (
+	MermaidShapeRectangle  = shape{"[", "]", "rect"}
+	MermaidShapeRound      = shape{"(", ")", "rounded"}
+	MermaidShapeStadium    = shape{"([", "])", "stadium"}
+	MermaidShapeSubroutine = shape{"[[", "]]", "fr-rect"}
+	MermaidShapeCylinder   = shape{"[(", ")]", "cyl"}
+	//Deprecated: use MermaidShapeCircle instead
+	MermaidShapeCirle            = shape{"((", "))", "circle"}
+	MermaidShapeCircle           = shape{"((", "))", "circle"}
+	MermaidShapeDoubleCircle     = shape{"(((", ")))", "dbl-circ"}
+	MermaidShapeAsymmetric       = shape{">", "]", "odd"}
+	MermaidShapeRhombus          = shape{"{", "}", "diam"}
+	MermaidShapeTrapezoid        = shape{"[/", "\\]", "trap-b"}
+	MermaidShapeTrapezoidAlt     = shape{"[\\", "/]", "trap-t"}
+	MermaidShapeHexagon          = shape{"{{", "}}", "hex"}
+	MermaidShapeParallelogram    = shape{"[/", "/]", "lean-r"}
+	MermaidShapeParallelogramAlt = shape{"[\\", "\\]", "lean-l"}
+)
+
+// The shapes that are written using the expanded notation of mermaid v11, e.g. A@{ shape: doc, label: "text" }.
+// See https://mermaid.js.org/syntax/flowchart.html#complete-list-of-new-shapes
+var (
+	MermaidShapeText               = shape{name: "text"}
+	MermaidShapeCard               = shape{name: "notch-rect"}
+	MermaidShapeLinedRectangle     = shape{name: "lin-rect"}
+	MermaidShapeSmallCircle        = shape{name: "sm-circ"}
+	MermaidShapeFramedCircle       = shape{name: "fr-circ"}
+	MermaidShapeFork               = shape{name: "fork"}
+	MermaidShapeHourglass          = shape{name: "hourglass"}
+	MermaidShapeBrace              = shape{name: "brace"}
+	MermaidShapeBraceRight         = shape{name: "brace-r"}
+	MermaidShapeBraces             = shape{name: "braces"}
+	MermaidShapeBolt               = shape{name: "bolt"}
+	MermaidShapeDocument           = shape{name: "doc"}
+	MermaidShapeDelay              = shape{name: "delay"}
+	MermaidShapeHorizontalCylinder = shape{name: "h-cyl"}
+	MermaidShapeLinedCylinder      = shape{name: "lin-cyl"}
+	MermaidShapeCurvedTrapezoid    = shape{name: "curv-trap"}
+	MermaidShapeDividedRectangle   = shape{name: "div-rect"}
+	MermaidShapeTriangle           = shape{name: "tri"}
+	MermaidShapeWindowPane         = shape{name: "win-pane"}
+	MermaidShapeFilledCircle       = shape{name: "f-circ"}
+	MermaidShapeLinedDocument      = shape{name: "lin-doc"}
+	MermaidShapeNotchedPentagon    = shape{name: "notch-pent"}
+	MermaidShapeFlippedTriangle    = shape{name: "flip-tri"}
+	MermaidShapeSlopedRectangle    = shape{name: "sl-rect"}
+	MermaidShapeDocuments          = shape{name: "docs"}
+	MermaidShapeStackedRectangle   = shape{name: "st-rect"}
+	MermaidShapeFlag               = shape{name: "flag"}
+	MermaidShapeBowTieRectangle    = shape{name: "bow-rect"}
+	MermaidShapeCrossedCircle      = shape{name: "cross-circ"}
+	MermaidShapeTaggedDocument     = shape{name: "tag-doc"}
+	MermaidShapeTaggedRectangle    = shape{name: "tag-rect"}
+)
+
+// shape is a mermaid node shape ; open and close are empty if the shape has no bracket notation.
+type shape struct {
+	open, close string
+	// name is the short name of the shape in the expanded notation
+	name string
+}
+
+// mermaidShapes has all shapes, the ones with a bracket notation first.
+var mermaidShapes = []shape{
+	MermaidShapeRectangle, MermaidShapeRound, MermaidShapeStadium, MermaidShapeSubroutine, MermaidShapeCylinder,
+	MermaidShapeCircle, MermaidShapeDoubleCircle, MermaidShapeAsymmetric, MermaidShapeRhombus, MermaidShapeTrapezoid,
+	MermaidShapeTrapezoidAlt, MermaidShapeHexagon, MermaidShapeParallelogram, MermaidShapeParallelogramAlt,
+	MermaidShapeText, MermaidShapeCard, MermaidShapeLinedRectangle, MermaidShapeSmallCircle, MermaidShapeFramedCircle,
+	MermaidShapeFork, MermaidShapeHourglass, MermaidShapeBrace, MermaidShapeBraceRight, MermaidShapeBraces,
+	MermaidShapeBolt, MermaidShapeDocument, MermaidShapeDelay, MermaidShapeHorizontalCylinder, MermaidShapeLinedCylinder,
+	MermaidShapeCurvedTrapezoid, MermaidShapeDividedRectangle, MermaidShapeTriangle, MermaidShapeWindowPane,
+	MermaidShapeFilledCircle, MermaidShapeLinedDocument, MermaidShapeNotchedPentagon, MermaidShapeFlippedTriangle,
+	MermaidShapeSlopedRectangle, MermaidShapeDocuments, MermaidShapeStackedRectangle, MermaidShapeFlag,
+	MermaidShapeBowTieRectangle, MermaidShapeCrossedCircle, MermaidShapeTaggedDocument, MermaidShapeTaggedRectangle,
+}
+
+// mermaidShapeAliases has the alias names of mermaid, and the names used by earlier versions of this package, for each short name.
+var mermaidShapeAliases = map[string][]string{
+	"rect":       {"proc", "process", "rectangle"},
+	"rounded":    {"event", "round"},
+	"stadium":    {"pill", "terminal"},
+	"fr-rect":    {"subprocess", "subproc", "framed-rectangle", "subroutine"},
+	"cyl":        {"db", "database", "cylinder"},
+	"circle":     {"circ"},
+	"odd":        {"asymmetric"},
+	"diam":       {"decision", "diamond", "question", "rhombus", "rhombux"},
+	"hex":        {"hexagon", "prepare"},
+	"lean-r":     {"lean-right", "in-out", "parallelogram"},
+	"lean-l":     {"lean-left", "out-in", "parallelogram-alt"},
+	"trap-b":     {"priority", "trapezoid", "trapezoid-bottom"},
+	"trap-t":     {"manual", "inv-trapezoid", "trapezoid-top", "trapezoid-alt"},
+	"dbl-circ":   {"double-circle"},
+	"notch-rect": {"card", "notched-rectangle"},
+	"lin-rect":   {"lined-rectangle", "lined-process", "lin-proc", "shaded-process"},
+	"sm-circ":    {"start", "small-circle"},
+	"fr-circ":    {"stop", "framed-circle"},
+	"fork":       {"join"},
+	"hourglass":  {"collate"},
+	"brace":      {"comment", "brace-l"},
+	"bolt":       {"com-link", "lightning-bolt"},
+	"doc":        {"document"},
+	"delay":      {"half-rounded-rectangle"},
+	"h-cyl":      {"das", "horizontal-cylinder"},
+	"lin-cyl":    {"disk", "lined-cylinder"},
+	"curv-trap":  {"curved-trapezoid", "display"},
+	"div-rect":   {"div-proc", "divided-rectangle", "divided-process"},
+	"tri":        {"extract", "triangle"},
+	"win-pane":   {"internal-storage", "window-pane"},
+	"f-circ":     {"junction", "filled-circle"},
+	"lin-doc":    {"lined-document"},
+	"notch-pent": {"loop-limit", "notched-pentagon"},
+	"flip-tri":   {"manual-file", "flipped-triangle"},
+	"sl-rect":    {"manual-input", "sloped-rectangle"},
+	"docs":       {"documents", "st-doc", "stacked-document"},
+	"st-rect":    {"procs", "processes", "stacked-rectangle"},
+	"flag":       {"paper-tape"},
+	"bow-rect":   {"stored-data", "bow-tie-rectangle"},
+	"cross-circ": {"summary", "crossed-circle"},
+	"tag-doc":    {"tagged-document"},
+	"tag-rect":   {"tag-proc", "tagged-rectangle", "tagged-process"},
+}
+
+// graphvizMermaidShapes has the Graphviz node shapes (lowercase) for each mermaid shape that is closest to them.
+//
+//	Graphviz                                        mermaid
+//	box, mrecord                                    rounded (the default)
+//	rect, rectangle, square, polygon, record        rect
+//	ellipse, oval, egg                              stadium
+//	circle                                          circle
+//	point                                           f-circ (filled circle)
+//	doublecircle                                    dbl-circ
+//	mcircle                                         cross-circ
+//	diamond, mdiamond                               diam
+//	triangle                                        tri
+//	invtriangle                                     flip-tri
+//	trapezium                                       trap-b
+//	invtrapezium                                    trap-t
+//	parallelogram                                   lean-r
+//	house, invhouse                                 notch-pent
+//	pentagon, hexagon, septagon, octagon,
+//	doubleoctagon, tripleoctagon                    hex
+//	msquare                                         win-pane
+//	star                                            bolt
+//	plaintext, plain, none, underline               text
+//	cylinder                                        cyl
+//	note                                            doc
+//	tab, folder                                     tag-rect
+//	box3d                                           st-rect
+//	component                                       fr-rect
+//	cds, rarrow, larrow, rpromoter, lpromoter       odd
+//	other synthetic biology shapes (e.g. promoter)  rect
+var graphvizMermaidShapes = map[string][]string{
+	"rounded": {"box", "mrecord"},
+	"rect": {"rect", "rectangle", "square", "polygon", "record",
+		"promoter", "terminator", "utr", "primersite", "restrictionsite", "fivepoverhang", "threepoverhang",
+		"noverhang", "assembly", "signature", "insulator", "ribosite", "rnastab", "proteasesite", "proteinstab"},
+	"stadium":    {"ellipse", "oval", "egg"},
+	"circle":     {"circle"},
+	"f-circ":     {"point"},
+	"dbl-circ":   {"doublecircle"},
+	"cross-circ": {"mcircle"},
+	"diam":       {"diamond", "mdiamond"},
+	"tri":        {"triangle"},
+	"flip-tri":   {"invtriangle"},
+	"trap-b":     {"trapezium"},
+	"trap-t":     {"invtrapezium"},
+	"lean-r":     {"parallelogram"},
+	"notch-pent": {"house", "invhouse"},
+	"hex":        {"pentagon", "hexagon", "septagon", "octagon", "doubleoctagon", "tripleoctagon"},
+	"win-pane":   {"msquare"},
+	"bolt":       {"star"},
+	"text":       {"plaintext", "plain", "none", "underline"},
+	"cyl":        {"cylinder"},
+	"doc":        {"note"},
+	"tag-rect":   {"tab", "folder"},
+	"st-rect":    {"box3d"},
+	"fr-rect":    {"component"},
+	"odd":        {"cds", "rarrow", "larrow", "rpromoter", "lpromoter"},
+}
+
+// mermaidDotShapes maps mermaid shapes to the Graphviz shapes that look most alike ; box if absent.
+var mermaidDotShapes = map[string]string{
+	"stadium":    "oval",
+	"fr-rect":    "component",
+	"cyl":        "cylinder",
+	"circle":     "circle",
+	"dbl-circ":   "doublecircle",
+	"odd":        "cds",
+	"diam":       "diamond",
+	"trap-b":     "trapezium",
+	"trap-t":     "invtrapezium",
+	"hex":        "hexagon",
+	"lean-r":     "parallelogram",
+	"lean-l":     "parallelogram",
+	"text":       "plaintext",
+	"notch-rect": "note",
+	"sm-circ":    "circle",
+	"fr-circ":    "doublecircle",
+	"bolt":       "star",
+	"doc":        "note",
+	"h-cyl":      "cylinder",
+	"lin-cyl":    "cylinder",
+	"tri":        "triangle",
+	"win-pane":   "Msquare",
+	"f-circ":     "point",
+	"lin-doc":    "note",
+	"notch-pent": "house",
+	"flip-tri":   "invtriangle",
+	"docs":       "box3d",
+	"st-rect":    "box3d",
+	"cross-circ": "Mcircle",
+	"tag-doc":    "note",
+	"tag-rect":   "folder",
+	"brace":      "plaintext",
+	"brace-r":    "plaintext",
+	"braces":     "plaintext",
+}
+
+// String returns the Graphviz shape such that a node with a mermaid shape can also be written in DOT notation.
+func (s shape) String() string {
+	if name, ok := mermaidDotShapes[s.name]; ok {
+		return name
+	}
+	return "box"
+}
+
+// lookupShape returns the mermaid shape for a short name or alias of mermaid, or for a Graphviz shape (see graphvizMermaidShapes).
+// A name of both mermaid and Graphviz, e.g. cylinder, is taken as mermaid name.
+func lookupShape(shapeName string) (shape, bool) {
+	name := strings.ToLower(shapeName)
+	if s, ok := mermaidShapeNamed(name); ok {
+		return s, true
+	}
+	for short, aliases := range mermaidShapeAliases {
+		for _, each := range aliases {
+			if each == name {
+				return mermaidShapeNamed(short)
+			}
+		}
+	}
+	for short, names := range graphvizMermaidShapes {
+		for _, each := range names {
+			if each == name {
+				return mermaidShapeNamed(short)
+			}
+		}
+	}
+	return shape{}, false
+}
+
+// mermaidShapeNamed returns the shape with the short name.
+func mermaidShapeNamed(name string) (shape, bool) {
+	for _, each := range mermaidShapes {
+		if each.name == name {
+			return each, true
+		}
+	}
+	return shape{}, false
+}
@@ -1,6 +1,7 @@
 package dot
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -118,6 +119,18 @@ func TestMermaidSubgraph(t *testing.T) {
 	}
 }
 
+func TestMermaidNestedSubgraphs(t *testing.T) {
+	di := NewGraph(Directed)
+	outer := di.Subgraph("my system")
+	inner := outer.Subgraph("api")
+	inner.Node("a").Edge(inner.Subgraph("db & cache").Node("b"))
+	di.Subgraph("end").Subgraph("api").Node("c")
+	mf := MermaidFlowchart(di, MermaidLeftToRight)
+	if got, want := flatten(mf), `flowchart LR;subgraph end_s6 [end];subgraph api [api];n8("c");end;end;subgraph my_system_s1 [my system];subgraph api_s2 [api];n3("a");n3 --> n5;subgraph db___cache_s4 ["db &amp; cache"];n5("b");end;end;end;`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
 func TestMermaidFromBoxShape(t *testing.T) {
 	graph := NewGraph(Directed)
 	graph.Node("A").Box()
@@ -140,6 +153,16 @@ func TestLookupShape(t *testing.T) {
 		{"circle", "circle", MermaidShapeCircle, true},
 		{"cylinder", "cylinder", MermaidShapeCylinder, true},
 		{"rhombux", "rhombux", MermaidShapeRhombus, true},
+		{"rhombus", "rhombus", MermaidShapeRhombus, true},
+		{"diamond", "diamond", MermaidShapeRhombus, true},
+		{"ellipse", "ellipse", MermaidShapeStadium, true},
+		{"note", "note", MermaidShapeDocument, true},
+		{"folder", "folder", MermaidShapeTaggedRectangle, true},
+		{"doublecircle", "doublecircle", MermaidShapeDoubleCircle, true},
+		{"box3d", "box3d", MermaidShapeStackedRectangle, true},
+		{"Mdiamond", "Mdiamond", MermaidShapeRhombus, true},
+		{"doc", "doc", MermaidShapeDocument, true},
+		{"lined-cylinder", "lined-cylinder", MermaidShapeLinedCylinder, true},
 		{"stadium", "stadium", MermaidShapeStadium, true},
 		{"subroutine", "subroutine", MermaidShapeSubroutine, true},
 		{"trapezoid", "trapezoid", MermaidShapeTrapezoid, true},
@@ -171,3 +194,205 @@ func TestMermaidAnimatedLinkStyleEdge(t *testing.T) {
 		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
 	}
 }
+
+func TestWriteMermaidFlowchart(t *testing.T) {
+	di := NewGraph(Directed)
+	di.Node("A").Edge(di.Node("B"))
+	b := new(strings.Builder)
+	n, err := WriteMermaidFlowchart(b, di, MermaidLeftToRight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), MermaidFlowchart(di, MermaidLeftToRight); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := n, int64(b.Len()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	n, err = WriteMermaidGraph(&failingWriter{limit: 4}, di, MermaidLeftToRight)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if got, want := n, int64(4); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestMermaidNodeIDOption(t *testing.T) {
+	di := NewGraph(Directed, NodeIDOption{})
+	di.Node("A").Edge(di.Node("my node")).Edge(di.Node("end"))
+	if got, want := flatten(MermaidFlowchart(di, MermaidLeftToRight)), `flowchart LR;A("A");end_n3("end");my_node_n2("my node");A --> my_node_n2;my_node_n2 --> end_n3;`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestMermaidClasses(t *testing.T) {
+	di := NewGraph(Directed)
+	di.NodeDefaults().Attr("fontcolor", "white")
+	a := di.Node("a").Attr("fillcolor", "red").Attr("penwidth", 2)
+	b := di.Node("b").Attr("fillcolor", "red").Attr("penwidth", 2)
+	c := di.Node("c").Attr("class", "important")
+	d := di.Subgraph("one").Node("d").Attr("color", "#00000080")
+	a.Edge(b)
+	c.Edge(d)
+	mf := MermaidFlowchartWithOptions(di, MermaidOptions{
+		Orientation: MermaidLeftToRight,
+		ClassDefs:   map[string]string{"important": "fill:#f96,stroke:#333"},
+	})
+	if got, want := flatten(mf), `flowchart LR;n1("a");n2("b");n3("c");n1 --> n2;n3 --> n5;subgraph one [one];n5("d");end;`+
+		`classDef important fill:#f96,stroke:#333;classDef c1 fill:#ff0000,color:#ffffff,stroke-width:2px;classDef c2 stroke:#00000080,color:#ffffff;`+
+		`class n1,n2 c1;class n3 important;class n5 c2;`; got != want {
+		t.Errorf("got [%[1]v:%[1]T] want [%[2]v:%[2]T]", got, want)
+	}
+}
+
+func TestMermaidEdgeLinks(t *testing.T) {
+	di := NewGraph(Directed)
+	a, b := di.Node("a"), di.Node("b")
+	a.Edge(b).Dashed()
+	a.Edge(b).Bold()
+	a.Edge(b).Attr("dir", "both")
+	a.Edge(b).Attr("dir", "none")
+	a.Edge(b).Attr("arrowhead", "odot")
+	a.Edge(b).Attr("arrowhead", "tee")
+	a.Edge(b, "hidden").Attr("style", "invis")
+	a.Edge(b).Attr("dir", "back")
+	a.Edge(b).Attr("color", "red").Attr("penwidth", 2)
+	if got, want := flatten(MermaidFlowchart(di, MermaidLeftToRight)), `flowchart LR;n1("a");n2("b");`+
+		`n1 -.-> n2;n1 ==> n2;n1 <--> n2;n1 --- n2;n1 --o n2;n1 --x n2;n1 ~~~ n2;n2 --> n1;`+
+		`n1 --> n2;linkStyle 8 stroke:#ff0000,stroke-width:2px`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestMermaidLinkStyleIndexInSubgraphs(t *testing.T) {
+	di := NewGraph(Directed)
+	sub := di.Subgraph("one")
+	di.Node("a").Edge(di.Node("b"))
+	sub.EdgeDefaults().Attr("style", "dotted")
+	sub.Node("c").Edge(sub.Node("d")).Attr("linkStyle", "stroke:red")
+	if got, want := flatten(MermaidFlowchart(di, MermaidLeftToRight)), `flowchart LR;n2("a");n3("b");n2 --> n3;`+
+		`subgraph one [one];n4("c");n5("d");n4 -.-> n5;linkStyle 1 stroke:redend;`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestMermaidExpandedShapes(t *testing.T) {
+	di := NewGraph(Directed)
+	di.Node("a").Attr("shape", MermaidShapeDocument).Label("spec & design")
+	di.Node("b").Attr("shape", "cylinder")
+	di.Node("c").Attr("shape", "note")
+	di.Node("d").Attr("shape", "rhombus")
+	if got, want := flatten(MermaidFlowchart(di, MermaidLeftToRight)), `flowchart LR;n1@{ shape: doc, label: "spec &amp; design" };n2[("b")];n3@{ shape: doc, label: "c" };n4{"d"};`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	// in DOT the closest Graphviz shape is written
+	if got, want := flatten(di.String()), `n1[label="spec & design",shape="note"]`; !strings.Contains(got, want) {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestGraphvizMermaidShapesComplete(t *testing.T) {
+	for short, names := range graphvizMermaidShapes {
+		if _, ok := mermaidShapeNamed(short); !ok {
+			t.Errorf("unknown mermaid shape %q", short)
+		}
+		for _, each := range names {
+			if _, ok := lookupShape(each); !ok {
+				t.Errorf("no mermaid shape for %q", each)
+			}
+		}
+	}
+	for short := range mermaidShapeAliases {
+		if _, ok := mermaidShapeNamed(short); !ok {
+			t.Errorf("unknown mermaid shape %q", short)
+		}
+	}
+}
+
+func TestMermaidClick(t *testing.T) {
+	di := NewGraph(Directed)
+	di.Node("a").Attr("href", "subsystem.svg").Attr("tooltip", `the "sub" system`).Attr("target", "_blank")
+	di.Node("b").Attr("URL", Literal(`"https://example.com/?q=1"`))
+	di.Node("c").Attr("tooltip", "no link")
+	if got, want := flatten(MermaidFlowchart(di, MermaidLeftToRight)), `flowchart LR;n1("a");click n1 href "subsystem.svg" "the &#34;sub&#34; system" _blank`+
+		`n2("b");click n2 href "https://example.com/?q=1"n3("c");`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestMermaidFrontMatterAndAccessibility(t *testing.T) {
+	di := NewGraph(Directed)
+	di.Attr("label", "Order flow")
+	di.Attr("comment", "How an order\nis processed")
+	di.Node("a")
+	html := false
+	mf := MermaidFlowchartWithOptions(di, MermaidOptions{
+		Orientation:    MermaidLeftToRight,
+		Theme:          "base",
+		ThemeVariables: map[string]string{"primaryColor": "#BB2528", "fontSize": "16px"},
+		Curve:          "basis",
+		HTMLLabels:     &html,
+	})
+	want := `---
+title: Order flow
+config:
+  theme: base
+  themeVariables:
+    fontSize: 16px
+    primaryColor: "#BB2528"
+  flowchart:
+    curve: basis
+    htmlLabels: false
+---
+flowchart LR;
+	accTitle: Order flow
+	accDescr {
+How an order
+is processed
+	}
+	n1("a");
+`
+	if got := mf; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	mf = MermaidFlowchartWithOptions(NewGraph(Directed), MermaidOptions{Title: "yes", AccTitle: "Orders", AccDescr: "All orders"})
+	if got, want := flatten(mf), `---title: "yes"---flowchart TD;accTitle: OrdersaccDescr: All orders`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestMermaidSubgraphDirectionAndStyle(t *testing.T) {
+	di := NewGraph(Directed)
+	one := di.Subgraph("one", ClusterOption{})
+	one.Attr("rankdir", "LR")
+	one.Attr("fillcolor", "lightgrey")
+	one.Attr("color", "blue")
+	one.Attr("style", "dashed")
+	one.Node("a").Edge(one.Node("b"))
+	two := di.Subgraph("two")
+	two.Attr("style", "fill:#f9f,stroke:#333")
+	two.Node("c")
+	di.Edge(di.Node("x"), one.Node("a")).Attr("lhead", one.GetID())
+	di.Edge(two.Node("c"), one.Node("b")).Attr("ltail", two.GetID()).Attr("lhead", one.GetID())
+	if got, want := flatten(MermaidFlowchart(di, MermaidTopDown)), `flowchart TD;n6("x");two --> one;n6 --> one;`+
+		`subgraph one [one];direction LRstyle one fill:#d3d3d3,stroke:#0000ff,stroke-dasharray:5 5n2("a");n3("b");n2 --> n3;end;`+
+		`subgraph two [two];style two fill:#f9f,stroke:#333n5("c");end;`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestMermaidFromParsedDOT(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph {
+	a [style="rounded\,filled"]
+	b [style="fill:#f9f"]
+	a -> b [linkStyle="stroke:red", animate=true]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Node("x").Attr("style", Literal("filled"))
+	if got, want := flatten(MermaidFlowchart(g, MermaidTopDown)), `flowchart TD;n1("a");n2("b");style n2 fill:#f9fn3("x");n1 e0@--> n2;linkStyle 0 stroke:rede0@{animate: true}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -11,6 +11,10 @@ type Node struct {
 // ID returns the assigned id to this node.
 func (n Node) ID() string { return n.id }
 
+// Seq returns the sequence number of the node, unique within the root graph.
+// Unless the NodeIDOption is used, the node is written as n<seq>.
+func (n Node) Seq() int { return n.seq }
+
 // Attr sets label=value and return the Node
 func (n Node) Attr(label string, value interface{}) Node {
 	n.AttributesMap.Attr(label, value)
@@ -39,7 +43,22 @@ func (n Node) EdgesTo(toNode Node) []Edge {
 
 // GetAttr returns the value stored by a name. Returns nil if missing.
 func (n Node) GetAttr(name string) interface{} {
-	return n.attributes[name]
+	return n.AttributesMap.Value(name)
+}
+
+// EffectiveValue returns the value of the attribute of the node. If absent then the default value
+// is taken from the NodeDefaults of its graph or one of its parents. Returns nil if not set.
+func (n Node) EffectiveValue(name string) interface{} {
+	n.rlock()
+	defer n.runlock()
+	if v, ok := n.attributes[name]; ok {
+		return v
+	}
+	if n.graph == nil {
+		return nil
+	}
+	v, _ := n.graph.inheritedNodeDefault(name)
+	return v
 }
 
 // ReverseEdge sets label=value and returns the Edge for chaining.
@@ -62,6 +81,8 @@ func (n Node) NewRecordBuilder() *recordBuilder {
 
 // HasDefaultLabel returns true if the node has a label set from its id.
 func (n Node) HasDefaultLabel() bool {
+	n.rlock()
+	defer n.runlock()
 	l, ok := n.attributes["label"]
 	if !ok {
 		return false
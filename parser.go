@@ -0,0 +1,637 @@
+package dot
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SyntaxError is returned by Parse if the input is not valid DOT and by ParseMermaid if the input is not a valid mermaid flowchart.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("dot: syntax error at line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Parse reads a graph in DOT notation and returns the Graph with all its nodes, edges and subgraphs.
+// Subgraphs with a name starting with "cluster" are created using the ClusterOption ; the name is kept as id of the subgraph.
+// A node is put in the last named subgraph, nested in its current one, that mentions it (e.g. a -> b; subgraph cluster_1 { a; b }).
+// Subgraphs without a name that only group nodes and edges, such as the operand of a -> {b c}, are left out.
+// Default attributes (node [..] and edge [..] statements) are kept in NodeDefaults and EdgeDefaults
+// if they precede all nodes or edges of their (sub)graph ; otherwise they are copied onto the nodes and edges that follow them.
+// Quoted values that contain escape sequences (e.g. \l) are stored as Literal, <...> values as HTML.
+// Only the first graph of the input is read. The options are applied to the new Graph ;
+// use InsertionOrder to keep the order of declaration.
+func Parse(r io.Reader, options ...GraphOption) (*Graph, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		scanner:  newScanner(string(data)),
+		nodes:    map[string]Node{},
+		grouping: map[*Graph]bool{},
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return p.parseGraph(options)
+}
+
+type parser struct {
+	scanner *scanner
+	tok     token
+	// nodes are shared by all (sub)graphs ; DOT node names are global to the graph.
+	nodes map[string]Node
+	// anonymous counts the subgraphs without a name.
+	anonymous int
+	// grouping has the subgraphs without a name ; these only group statements.
+	grouping map[*Graph]bool
+	directed bool
+}
+
+// scope holds the graph to which statements apply and the default attributes in effect.
+type scope struct {
+	parent       *scope
+	graph        *Graph
+	nodeDefaults map[string]interface{}
+	edgeDefaults map[string]interface{}
+	// nodesCreated and edgesCreated are true if the scope or a nested one created a node or edge.
+	// Once true, default statements can no longer be written as such because they would apply to those too.
+	nodesCreated bool
+	edgesCreated bool
+	// mentioned collects the nodes referenced in this scope, used when a subgraph is an edge operand.
+	mentioned []Node
+}
+
+func (s *scope) child(g *Graph) *scope {
+	return &scope{
+		parent:       s,
+		graph:        g,
+		nodeDefaults: copyAttributes(s.nodeDefaults),
+		edgeDefaults: copyAttributes(s.edgeDefaults),
+	}
+}
+
+func (s *scope) nodeCreated() {
+	for each := s; each != nil; each = each.parent {
+		each.nodesCreated = true
+	}
+}
+
+func (s *scope) edgeCreated() {
+	for each := s; each != nil; each = each.parent {
+		each.edgesCreated = true
+	}
+}
+
+// mention records the node unless already recorded.
+func (s *scope) mention(n Node) {
+	for _, each := range s.mentioned {
+		if each.id == n.id {
+			return
+		}
+	}
+	s.mentioned = append(s.mentioned, n)
+}
+
+func copyAttributes(m map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// endpoint is one side of an edge: a node with an optional port.
+type endpoint struct {
+	node Node
+	port string
+}
+
+func (p *parser) advance() error {
+	tok, err := p.scanner.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Line: p.tok.line, Column: p.tok.column, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) expect(kind tokenKind, what string) error {
+	if p.tok.kind != kind {
+		return p.errorf("expected %s but got %s", what, p.tok)
+	}
+	return p.advance()
+}
+
+// skip advances if the current token is of the given kind.
+func (p *parser) skip(kind tokenKind) (bool, error) {
+	if p.tok.kind != kind {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) isID() bool {
+	return p.tok.kind == tokenID || p.tok.kind == tokenQuoted || p.tok.kind == tokenHTML
+}
+
+// parseID reads an ID as a string ; used for names of graphs, nodes and ports.
+func (p *parser) parseID() (string, error) {
+	value, err := p.parseValue()
+	if err != nil {
+		return "", err
+	}
+	switch v := value.(type) {
+	case HTML:
+		return string(v), nil
+	case Literal:
+		return unquoteLiteral(v), nil
+	}
+	return value.(string), nil
+}
+
+// parseValue reads an ID as an attribute value: a string, a Literal or an HTML value.
+// Quoted strings can be concatenated using '+'.
+func (p *parser) parseValue() (interface{}, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenID:
+		if isKeyword(tok.text) {
+			return nil, p.errorf("unexpected keyword %s", tok)
+		}
+		return tok.text, p.advance()
+	case tokenHTML:
+		return HTML(tok.text), p.advance()
+	case tokenQuoted:
+		text := tok.text
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for p.tok.kind == tokenPlus {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if p.tok.kind != tokenQuoted {
+				return nil, p.errorf("expected quoted string after '+' but got %s", p.tok)
+			}
+			text += p.tok.text
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		if strings.Contains(text, `\`) {
+			// keep escape sequences as is
+			return Literal(`"` + strings.Replace(text, `"`, `\"`, -1) + `"`), nil
+		}
+		return text, nil
+	}
+	return nil, p.errorf("expected identifier but got %s", tok)
+}
+
+// unquoteLiteral returns the content of a Literal created by parseValue.
+func unquoteLiteral(l Literal) string {
+	s := strings.TrimSuffix(strings.TrimPrefix(string(l), `"`), `"`)
+	return strings.Replace(s, `\"`, `"`, -1)
+}
+
+func isKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "strict", "graph", "digraph", "subgraph", "node", "edge":
+		return true
+	}
+	return false
+}
+
+// graph : [ strict ] (graph | digraph) [ ID ] '{' stmt_list '}'
+func (p *parser) parseGraph(extra []GraphOption) (*Graph, error) {
+	var options []GraphOption
+	if p.tok.isKeyword("strict") {
+		options = append(options, Strict)
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case p.tok.isKeyword("graph"):
+		options = append(options, Undirected)
+	case p.tok.isKeyword("digraph"):
+		options = append(options, Directed)
+		p.directed = true
+	default:
+		return nil, p.errorf("expected graph or digraph but got %s", p.tok)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	g := NewGraph(append(options, extra...)...)
+	if p.isID() {
+		id, err := p.parseID()
+		if err != nil {
+			return nil, err
+		}
+		g.ID(id)
+	}
+	if err := p.expect(tokenLeftBrace, "'{'"); err != nil {
+		return nil, err
+	}
+	root := &scope{graph: g, nodeDefaults: map[string]interface{}{}, edgeDefaults: map[string]interface{}{}}
+	if err := p.parseStatements(root); err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenRightBrace, "'}'"); err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.errorf("unexpected %s after graph", p.tok)
+	}
+	return g, nil
+}
+
+// stmt_list : [ stmt [ ';' ] stmt_list ]
+func (p *parser) parseStatements(s *scope) error {
+	for p.tok.kind != tokenRightBrace {
+		if p.tok.kind == tokenEOF {
+			return p.errorf("expected '}' but got %s", p.tok)
+		}
+		if err := p.parseStatement(s); err != nil {
+			return err
+		}
+		if _, err := p.skip(tokenSemicolon); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stmt : node_stmt | edge_stmt | attr_stmt | ID '=' ID | subgraph
+func (p *parser) parseStatement(s *scope) error {
+	switch {
+	case p.tok.isKeyword("graph"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		return p.parseAttributes(func(key string, value interface{}) {
+			s.graph.Attr(key, value)
+		})
+	case p.tok.isKeyword("node"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		return p.parseAttributes(func(key string, value interface{}) {
+			s.nodeDefaults[key] = value
+			if !s.nodesCreated {
+				s.graph.NodeDefaults().Attr(key, value)
+			}
+		})
+	case p.tok.isKeyword("edge"):
+		if err := p.advance(); err != nil {
+			return err
+		}
+		return p.parseAttributes(func(key string, value interface{}) {
+			s.edgeDefaults[key] = value
+			if !s.edgesCreated {
+				s.graph.EdgeDefaults().Attr(key, value)
+			}
+		})
+	case p.tok.isKeyword("subgraph") || p.tok.kind == tokenLeftBrace:
+		nodes, err := p.parseSubgraph(s)
+		if err != nil {
+			return err
+		}
+		return p.parseEdgeTail(s, endpointsOf(nodes))
+	case p.isID():
+		id, err := p.parseID()
+		if err != nil {
+			return err
+		}
+		if p.tok.kind == tokenEqual {
+			if err := p.advance(); err != nil {
+				return err
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return err
+			}
+			s.graph.Attr(id, value)
+			return nil
+		}
+		// a port in a node statement is allowed but has no meaning
+		port, err := p.parsePort()
+		if err != nil {
+			return err
+		}
+		n := p.node(s, id)
+		if p.tok.kind == tokenDirectedEdge || p.tok.kind == tokenUndirectedEdge {
+			return p.parseEdgeTail(s, []endpoint{{node: n, port: port}})
+		}
+		return p.parseAttributes(func(key string, value interface{}) {
+			n.Attr(key, value)
+		})
+	}
+	return p.errorf("unexpected %s", p.tok)
+}
+
+// port : ':' ID [ ':' compass_pt ] | ':' compass_pt
+func (p *parser) parsePort() (string, error) {
+	port := ""
+	for p.tok.kind == tokenColon {
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+		id, err := p.parseID()
+		if err != nil {
+			return "", err
+		}
+		if port != "" {
+			port += ":"
+		}
+		port += id
+	}
+	return port, nil
+}
+
+// parseEdgeTail reads the edge operators and operands that follow the first operand, if any,
+// followed by an optional attribute list.
+// edgeRHS : edgeop (node_id | subgraph) [ edgeRHS ]
+func (p *parser) parseEdgeTail(s *scope, from []endpoint) error {
+	if p.tok.kind != tokenDirectedEdge && p.tok.kind != tokenUndirectedEdge {
+		// subgraph statement
+		if p.tok.kind == tokenLeftBracket {
+			return p.errorf("attributes not allowed after subgraph")
+		}
+		return nil
+	}
+	operands := [][]endpoint{from}
+	for p.tok.kind == tokenDirectedEdge || p.tok.kind == tokenUndirectedEdge {
+		if p.directed && p.tok.kind == tokenUndirectedEdge {
+			return p.errorf("undirected edge '--' in digraph")
+		}
+		if !p.directed && p.tok.kind == tokenDirectedEdge {
+			return p.errorf("directed edge '->' in graph")
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if p.tok.isKeyword("subgraph") || p.tok.kind == tokenLeftBrace {
+			nodes, err := p.parseSubgraph(s)
+			if err != nil {
+				return err
+			}
+			operands = append(operands, endpointsOf(nodes))
+			continue
+		}
+		id, err := p.parseID()
+		if err != nil {
+			return err
+		}
+		port, err := p.parsePort()
+		if err != nil {
+			return err
+		}
+		operands = append(operands, []endpoint{{node: p.node(s, id), port: port}})
+	}
+	var edges []Edge
+	for i := 0; i < len(operands)-1; i++ {
+		for _, tail := range operands[i] {
+			for _, head := range operands[i+1] {
+				// the nodes can have been moved since they were mentioned
+				e := s.graph.EdgeWithPorts(p.nodes[tail.node.id], p.nodes[head.node.id], tail.port, head.port)
+				s.edgeCreated()
+				// the edge can be owned by a parent graph that does not have the defaults of this scope
+				for k, v := range s.edgeDefaults {
+					if inherited, ok := e.graph.inheritedEdgeDefault(k); !ok || inherited != v {
+						e.Attr(k, v)
+					}
+				}
+				edges = append(edges, e)
+			}
+		}
+	}
+	return p.parseAttributes(func(key string, value interface{}) {
+		for _, each := range edges {
+			each.Attr(key, value)
+		}
+	})
+}
+
+func endpointsOf(nodes []Node) (list []endpoint) {
+	for _, each := range nodes {
+		list = append(list, endpoint{node: each})
+	}
+	return
+}
+
+// subgraph : [ subgraph [ ID ] ] '{' stmt_list '}'
+// Returns all nodes mentioned in the subgraph.
+func (p *parser) parseSubgraph(s *scope) ([]Node, error) {
+	name := ""
+	if p.tok.isKeyword("subgraph") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.isID() {
+			id, err := p.parseID()
+			if err != nil {
+				return nil, err
+			}
+			name = id
+		}
+	}
+	if err := p.expect(tokenLeftBrace, "'{'"); err != nil {
+		return nil, err
+	}
+	anonymous := name == ""
+	if anonymous {
+		p.anonymous++
+		name = fmt.Sprintf("_anonymous_%d", p.anonymous)
+	}
+	sub, created := s.graph.subgraphs[name], false
+	if sub == nil {
+		var options []GraphOption
+		if strings.HasPrefix(name, "cluster") {
+			options = append(options, ClusterOption{})
+		}
+		sub = s.graph.Subgraph(name, options...)
+		// Subgraph sets the label to the name ; DOT does not.
+		sub.Delete("label")
+		created = true
+		if anonymous {
+			p.grouping[sub] = true
+		} else {
+			// keep the name as id such that lhead and ltail still refer to it
+			sub.id = name
+		}
+	}
+	inner := s.child(sub)
+	if err := p.parseStatements(inner); err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokenRightBrace, "'}'"); err != nil {
+		return nil, err
+	}
+	mentioned := []Node{}
+	for _, each := range inner.mentioned {
+		// the node can have been moved since it was mentioned
+		mentioned = append(mentioned, p.nodes[each.id])
+	}
+	// {rank=same; a; b} is the common way to align nodes
+	if rank, ok := p.rankGroupType(sub); created && ok {
+		delete(s.graph.subgraphs, name)
+		s.graph.AddToRank(rank, name, mentioned...)
+	} else if created && anonymous && p.isGrouping(sub) {
+		// such as the operand of a -> {b c}
+		for _, key := range sub.orderedNodesKeys() {
+			p.moveNode(sub.nodes[key], s.graph)
+		}
+		delete(s.graph.subgraphs, name)
+		delete(p.grouping, sub)
+	}
+	for _, each := range mentioned {
+		s.mention(p.nodes[each.id])
+	}
+	return mentioned, nil
+}
+
+// isGrouping returns true if the subgraph has nothing of its own but nodes and edges.
+func (p *parser) isGrouping(sub *Graph) bool {
+	return len(sub.attributes) == 0 && len(sub.nodeDefaults.attributes) == 0 && len(sub.edgeDefaults.attributes) == 0 &&
+		len(sub.subgraphs) == 0 && len(sub.sameRank) == 0
+}
+
+// moveNode puts the node in the graph ; the edges and rank groups that refer to it are updated
+// and each edge is kept by the nearest common (sub)graph of its nodes.
+func (p *parser) moveNode(n Node, to *Graph) {
+	delete(n.graph.nodes, n.id)
+	n.graph = to
+	to.nodes[n.id] = n
+	p.nodes[n.id] = n
+	root := to.Root()
+	edges := []Edge{}
+	var collect func(g *Graph)
+	collect = func(g *Graph) {
+		for _, list := range g.edgesFrom {
+			edges = append(edges, list...)
+		}
+		g.edgesFrom = map[string][]Edge{}
+		for _, nodes := range g.sameRank {
+			for i, each := range nodes {
+				if each.id == n.id {
+					nodes[i] = n
+				}
+			}
+		}
+		for _, each := range g.subgraphs {
+			collect(each)
+		}
+	}
+	collect(root)
+	sort.Slice(edges, func(i, j int) bool { return edges[i].seq < edges[j].seq })
+	for _, e := range edges {
+		if e.from.id == n.id {
+			e.from = n
+		}
+		if e.to.id == n.id {
+			e.to = n
+		}
+		e.graph = root.edgeOwner(e.from, e.to)
+		e.graph.edgesFrom[e.from.id] = append(e.graph.edgesFrom[e.from.id], e)
+	}
+}
+
+// rankGroupType returns the rank type if the subgraph only has the rank attribute and no nodes, edges or subgraphs of its own.
+func (p *parser) rankGroupType(sub *Graph) (RankType, bool) {
+	if len(sub.attributes) != 1 || len(sub.nodes) != 0 || len(sub.edgesFrom) != 0 || len(sub.subgraphs) != 0 {
+		return "", false
+	}
+	value, _ := sub.attributes["rank"].(string)
+	switch rank := RankType(value); rank {
+	case RankSame, RankMin, RankMax, RankSource, RankSink:
+		return rank, true
+	}
+	return "", false
+}
+
+// node returns the node with the given name ; creates one in the scope graph if absent.
+func (p *parser) node(s *scope, id string) Node {
+	n, ok := p.nodes[id]
+	if ok {
+		// a node that is mentioned in a (nested) subgraph of its graph is moved into it ;
+		// subgraphs without a name only group statements.
+		target := s.graph
+		for p.grouping[target] {
+			target = target.parent
+		}
+		if target != n.graph && isAncestor(n.graph, target) {
+			p.moveNode(n, target)
+			n = p.nodes[id]
+		}
+	} else {
+		n = s.graph.Node(id)
+		s.nodeCreated()
+		for k, v := range s.nodeDefaults {
+			// Node sets the label so a default label must be copied
+			if inherited, ok := n.graph.inheritedNodeDefault(k); !ok || inherited != v || k == "label" {
+				n.Attr(k, v)
+			}
+		}
+		p.nodes[id] = n
+	}
+	s.mention(n)
+	return n
+}
+
+// isAncestor returns true if the graph is the other graph or one of its parents.
+func isAncestor(g, other *Graph) bool {
+	for each := other; each != nil; each = each.parent {
+		if each == g {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAttributes reads zero or more attribute lists and calls set for each key=value pair.
+// attr_list : '[' [ a_list ] ']' [ attr_list ]
+// a_list : ID '=' ID [ (';' | ',') ] [ a_list ]
+func (p *parser) parseAttributes(set func(key string, value interface{})) error {
+	for p.tok.kind == tokenLeftBracket {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		for p.tok.kind != tokenRightBracket {
+			key, err := p.parseID()
+			if err != nil {
+				return err
+			}
+			var value interface{} = "true"
+			if ok, err := p.skip(tokenEqual); err != nil {
+				return err
+			} else if ok {
+				if value, err = p.parseValue(); err != nil {
+					return err
+				}
+			}
+			set(key, value)
+			if p.tok.kind == tokenComma || p.tok.kind == tokenSemicolon {
+				if err := p.advance(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,341 @@
+package dot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSimple(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph G { a -> b [label="what"]; }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph G {n1[label="a"];n2[label="b"];n1->n2[label="what"];}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	di := NewGraph(Directed)
+	di.ID("test")
+	di.Attr("rankdir", "LR")
+	a := di.Node("A").Box()
+	b := di.Node("B").Attr("label", HTML("<B>bold</B>"))
+	c := di.Node("C").Attr("label", Literal(`"left\l"`))
+	a.Edge(b, "ab").Dashed()
+	di.EdgeWithPorts(b, c, "p1", "n")
+	g, err := Parse(strings.NewReader(di.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := g.String(), di.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseStrictUndirected(t *testing.T) {
+	g, err := Parse(strings.NewReader(`strict graph { a -- b -- c }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `strict graph  {n1[label="a"];n2[label="b"];n3[label="c"];n1--n2;n2--n3;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseKeywordsCaseInsensitive(t *testing.T) {
+	g, err := Parse(strings.NewReader(`DiGraph { Node [shape=box] a }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph  {node[shape="box"];n1[label="a"];}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseClustersAndDefaults(t *testing.T) {
+	src := `digraph {
+	node [shape=box]
+	edge [color=gray]
+	subgraph cluster_one {
+		label = "One"
+		node [color=red]
+		a -> b
+	}
+	c
+	a -> c
+}`
+	g, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph  {node[shape="box"];edge[color="gray"];subgraph cluster_one {node[color="red"];label="One";n2[label="a"];n3[label="b"];n2->n3;}n4[label="c"];n2->n4;}`
+	if got := flatten(g.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	sub, ok := g.FindSubgraph("cluster_one")
+	if !ok {
+		t.Fatal("missing subgraph")
+	}
+	if got, want := sub.GetID(), "cluster_one"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParsePortsAndCompassPoints(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph { a:p1:ne -> b:s }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph  {n1[label="a"];n2[label="b"];n1:p1:ne->n2:s;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseHTMLLabel(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph { a [label=<<table><tr><td>A</td></tr></table>>] }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := g.FindNodeById("a")
+	if got, want := n.Value("label"), HTML("<table><tr><td>A</td></tr></table>"); got != want {
+		t.Errorf("got [%v:%T] want [%v:%T]", got, got, want, want)
+	}
+}
+
+func TestParseEdgeWithSubgraphOperand(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph { a -> {b c} [color=blue] }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := g.FindNodeById("a")
+	b, _ := g.FindNodeById("b")
+	c, _ := g.FindNodeById("c")
+	for _, each := range []Node{b, c} {
+		edges := g.FindEdges(a, each)
+		if len(edges) != 1 {
+			t.Fatalf("expected one edge a->%s", each.ID())
+		}
+		if got, want := edges[0].Value("color"), "blue"; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+}
+
+func TestParseSameRank(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph { a; b; {rank=same; a; b} }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph  {n1[label="a"];n2[label="b"];{rank=same; n1;n2;};}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseCommentsAndStrings(t *testing.T) {
+	src := `# preprocessor line
+/* block
+   comment */
+digraph {
+	// line comment
+	a [label="multi" + "part", tooltip="say \"hi\""]
+	b [label="left\l"]
+	c [width=1.5, fixedsize]
+}`
+	g, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := g.FindNodeById("a")
+	if got, want := a.Value("label"), "multipart"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := a.Value("tooltip"), `say "hi"`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	b, _ := g.FindNodeById("b")
+	if got, want := b.Value("label"), Literal(`"left\l"`); got != want {
+		t.Errorf("got [%v:%T] want [%v:%T]", got, got, want, want)
+	}
+	c, _ := g.FindNodeById("c")
+	if got, want := c.Value("width"), "1.5"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := c.Value("fixedsize"), "true"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src          string
+		line, column int
+	}{
+		{"digraph {\n  a -> \n}", 3, 1},
+		{"graph { a -> b }", 1, 11},
+		{"digraph { a [label=] }", 1, 20},
+		{"digraph {\n\ta\n", 3, 1},
+		{"digraph { a [label=\"open ] }", 1, 20},
+		{"tree { }", 1, 1},
+		{"digraph { 1a }", 1, 12},
+	}
+	for _, each := range tests {
+		_, err := Parse(strings.NewReader(each.src))
+		if err == nil {
+			t.Errorf("expected error for %q", each.src)
+			continue
+		}
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("expected *SyntaxError for %q, got %T", each.src, err)
+			continue
+		}
+		if syntaxErr.Line != each.line || syntaxErr.Column != each.column {
+			t.Errorf("%q: got line %d column %d want line %d column %d (%v)", each.src, syntaxErr.Line, syntaxErr.Column, each.line, each.column, err)
+		}
+	}
+}
+
+func TestParseDefaultsAfterNodes(t *testing.T) {
+	src := `digraph {
+	a -> b
+	node [shape=box]
+	edge [color=gray]
+	c -> d
+	subgraph cluster_x {
+		edge [style=dashed]
+		c -> a
+	}
+}`
+	g, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph  {subgraph cluster_x {edge[style="dashed"];n1[label="a"];n3[label="c",shape="box"];n3->n1[color="gray"];}n2[label="b"];n4[label="d",shape="box"];n1->n2;n3->n4[color="gray"];}`
+	if got := flatten(g.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseDefaultLabel(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph { node [label=""] a }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph  {node[label=""];n1[label=""];}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseInsertionOrder(t *testing.T) {
+	src := "digraph {\n\tz -> y;\n\ty -> x;\n\tsubgraph b {}\n\tsubgraph a {}\n}"
+	g, err := Parse(strings.NewReader(src), InsertionOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph  {subgraph b {}subgraph a {}n1[label="z"];n2[label="y"];n3[label="x"];n1->n2;n2->n3;}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseRankGroups(t *testing.T) {
+	g, err := Parse(strings.NewReader(`digraph { a; b; c; {rank=min; a} {rank = sink; b; c} }`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := flatten(g.String()), `digraph  {n1[label="a"];n2[label="b"];n3[label="c"];{rank=min; n1;};{rank=sink; n2;n3;};}`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseUndirectedSubgraphsRoundTrip(t *testing.T) {
+	src := `graph { subgraph cluster_x { a -- b; subgraph cluster_y { b -- c } } c -- d }`
+	g, err := Parse(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(g.String(), "->") {
+		t.Errorf("directed edge in [%v]", g.String())
+	}
+	again, err := Parse(strings.NewReader(g.String()), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := again.IsDirected(), false; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	for _, each := range [][2]string{{"a", "b"}, {"b", "c"}, {"c", "d"}} {
+		from, _ := again.FindNodeById(each[0])
+		to, _ := again.FindNodeById(each[1])
+		if got, want := len(again.FindEdges(from, to)), 1; got != want {
+			t.Errorf("%s--%s: got [%v] want [%v]", each[0], each[1], got, want)
+		}
+	}
+}
+
+func TestParseKeepsSubgraphIDs(t *testing.T) {
+	src := `digraph { compound=true; subgraph cluster_x { a } subgraph cluster_y { b } subgraph foo { c } a -> b [lhead=cluster_y] }`
+	g, err := Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Parse(strings.NewReader(g.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"cluster_x", "cluster_y", "foo"} {
+		sub, ok := again.FindSubgraph(id)
+		if !ok {
+			t.Fatalf("missing subgraph %s in %s", id, g.String())
+		}
+		if got, want := sub.GetID(), id; got != want {
+			t.Errorf("got [%v] want [%v]", got, want)
+		}
+	}
+	if got, want := flatten(again.String()), flatten(g.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseMovesNodesIntoSubgraph(t *testing.T) {
+	src := `digraph { a -> b; subgraph cluster_1 { a; b } c -> d }`
+	g, err := Parse(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph  {subgraph cluster_1 {a[label="a"];b[label="b"];a->b;}c[label="c"];d[label="d"];c->d;}`
+	if got := flatten(g.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	again, err := Parse(strings.NewReader(g.String()), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := flatten(again.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	a, _ := g.FindNodeById("a")
+	b, _ := g.FindNodeById("b")
+	if got, want := len(g.FindEdges(a, b)), 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestParseDropsAnonymousOperands(t *testing.T) {
+	src := `digraph { a -> {b c}; subgraph cluster_1 { d -> {e} } {node [shape=box] f} }`
+	g, err := Parse(strings.NewReader(src), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph  {subgraph s9 {node[shape="box"];f[label="f"];}subgraph cluster_1 {d[label="d"];e[label="e"];d->e;}a[label="a"];b[label="b"];c[label="c"];a->b;a->c;}`
+	if got := flatten(g.String()); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	again, err := Parse(strings.NewReader(g.String()), NodeIDOption{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(again.FindNodes()), 6; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,228 @@
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// PlantUML returns the graph in PlantUML notation, using the component and usecase elements.
+//
+// Nodes are written as elements depending on their shape, e.g. ellipse becomes usecase, box becomes rectangle
+// and cylinder becomes database. Use the node attribute "element" to set the PlantUML element, e.g. "actor".
+// Clusters are written as packages and other subgraphs as rectangles ; the attribute "element" overrides this.
+// The fillcolor, color, fontcolor and style (dashed, dotted, bold) attributes of nodes and subgraphs are kept.
+// Edges keep their label, color, style (dashed, dotted, bold, invis), penwidth and dir attributes.
+func PlantUML(g *Graph) string {
+	b := new(bytes.Buffer)
+	WritePlantUML(b, g)
+	return b.String()
+}
+
+// WritePlantUML writes the graph in PlantUML notation to w, statement by statement.
+// It stops at the first error of w and returns it with the number of bytes written.
+func WritePlantUML(w io.Writer, g *Graph) (int64, error) {
+	g.rlock()
+	defer g.runlock()
+	out := NewIndentWriter(w)
+	out.WriteString("@startuml\n")
+	if label, ok := g.attributes["label"]; ok {
+		fmt.Fprintf(out, "title %s\n", plantumlText(label))
+	}
+	if rankdir := strings.ToUpper(fmt.Sprintf("%v", g.attributes["rankdir"])); rankdir == "LR" || rankdir == "RL" {
+		out.WriteString("left to right direction\n")
+	}
+	plantumlGraph(g, out, "")
+	var edges []Edge
+	g.collectEdges(&edges)
+	for _, each := range edges {
+		plantumlEdge(g, each, out)
+	}
+	out.WriteString("@enduml\n")
+	return out.Written(), out.Err()
+}
+
+// plantumlElements maps Graphviz node shapes to PlantUML elements ; other shapes become a rectangle.
+var plantumlElements = map[string]string{
+	"ellipse":      "usecase",
+	"oval":         "usecase",
+	"circle":       "circle",
+	"doublecircle": "circle",
+	"point":        "circle",
+	"cylinder":     "database",
+	"component":    "component",
+	"folder":       "folder",
+	"tab":          "folder",
+	"note":         "file",
+	"box3d":        "node",
+	"hexagon":      "hexagon",
+	"plaintext":    "label",
+	"plain":        "label",
+	"none":         "label",
+	"underline":    "label",
+}
+
+func plantumlGraph(g *Graph, out *IndentWriter, indent string) {
+	for _, key := range g.orderedNodesKeys() {
+		each := g.nodes[key]
+		attributes := g.effectiveAttributes(each.attributes, true)
+		element := "usecase"
+		if s, ok := attributes["shape"]; ok {
+			element = "rectangle"
+			if e, ok := plantumlElements[strings.ToLower(fmt.Sprintf("%v", s))]; ok {
+				element = e
+			}
+		}
+		if e, ok := attributes["element"]; ok {
+			element = fmt.Sprintf("%v", e)
+		}
+		label := interface{}(each.id)
+		if v, ok := attributes["label"]; ok {
+			label = v
+		}
+		fmt.Fprintf(out, "%s%s \"%s\" as %s%s\n", indent, element, plantumlText(label), plantumlID(g, each), plantumlStyle(attributes))
+	}
+	for _, key := range g.orderedSubgraphsKeys() {
+		each := g.subgraphs[key]
+		element := "rectangle"
+		if strings.HasPrefix(each.id, "cluster") {
+			element = "package"
+		}
+		if e, ok := each.attributes["element"]; ok {
+			element = fmt.Sprintf("%v", e)
+		}
+		label := interface{}(key)
+		if v, ok := each.attributes["label"]; ok {
+			label = v
+		}
+		fmt.Fprintf(out, "%s%s \"%s\" as %s%s {\n", indent, element, plantumlText(label), each.id, plantumlStyle(each.attributes))
+		plantumlGraph(each, out, indent+"  ")
+		fmt.Fprintf(out, "%s}\n", indent)
+	}
+}
+
+func plantumlEdge(g *Graph, e Edge, out *IndentWriter) {
+	attributes := e.graph.effectiveAttributes(e.attributes, false)
+	options := []string{}
+	if v, ok := attributes["color"]; ok {
+		options = append(options, plantumlColor(v))
+	}
+	for _, each := range styleValues(attributes["style"]) {
+		switch each {
+		case "dashed", "dotted", "bold":
+			options = append(options, each)
+		case "invis":
+			options = append(options, "hidden")
+		}
+	}
+	if f, ok := floatValue(attributes["penwidth"]); ok {
+		options = append(options, fmt.Sprintf("thickness=%g", f))
+	}
+	line := "--"
+	if len(options) > 0 {
+		line = fmt.Sprintf("-[%s]-", strings.Join(options, ","))
+	}
+	dir := "forward"
+	if !g.IsDirected() {
+		dir = "none"
+	}
+	if v, ok := attributes["dir"]; ok {
+		dir = fmt.Sprintf("%v", v)
+	}
+	if fmt.Sprintf("%v", attributes["arrowhead"]) == "none" && dir == "forward" {
+		dir = "none"
+	}
+	switch dir {
+	case "forward":
+		line += ">"
+	case "back":
+		line = "<" + line
+	case "both":
+		line = "<" + line + ">"
+	}
+	fmt.Fprintf(out, "%s %s %s", plantumlID(g, e.from), line, plantumlID(g, e.to))
+	if label, ok := attributes["label"]; ok {
+		fmt.Fprintf(out, " : %s", plantumlText(label))
+	}
+	out.WriteString("\n")
+}
+
+// plantumlStyle returns the colors and line style of an element, e.g. " #lightblue;line:red;line.dashed".
+func plantumlStyle(attributes map[string]interface{}) string {
+	parts := []string{}
+	if v, ok := attributes["fillcolor"]; ok {
+		parts = append(parts, plantumlColor(v))
+	}
+	if v, ok := attributes["color"]; ok {
+		parts = append(parts, "line:"+strings.TrimPrefix(plantumlColor(v), "#"))
+	}
+	if v, ok := attributes["fontcolor"]; ok {
+		parts = append(parts, "text:"+strings.TrimPrefix(plantumlColor(v), "#"))
+	}
+	for _, each := range styleValues(attributes["style"]) {
+		switch each {
+		case "dashed", "dotted", "bold":
+			parts = append(parts, "line."+each)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	if !strings.HasPrefix(parts[0], "#") {
+		parts[0] = "#" + parts[0]
+	}
+	return " " + strings.Join(parts, ";")
+}
+
+// plantumlColor returns the first color of a color list as #name or #rrggbb.
+func plantumlColor(value interface{}) string {
+	s := strings.Trim(fmt.Sprintf("%v", value), `"`)
+	if i := strings.IndexAny(s, ":;"); i != -1 {
+		s = s[:i]
+	}
+	if i := strings.LastIndex(s, "/"); i != -1 {
+		s = s[i+1:]
+	}
+	return "#" + strings.TrimPrefix(s, "#")
+}
+
+var plantumlIDPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// plantumlID returns the alias of the node ; n<seq> unless the root graph has the NodeIDOption.
+// Node ids that are not valid aliases are made valid by replacing invalid characters and adding the sequence.
+func plantumlID(g *Graph, n Node) string {
+	if !g.Root().useNodeIDs {
+		return fmt.Sprintf("n%d", n.seq)
+	}
+	if plantumlIDPattern.MatchString(n.id) {
+		return n.id
+	}
+	safe := strings.Map(func(r rune) rune {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, n.id)
+	return fmt.Sprintf("%s_n%d", safe, n.seq)
+}
+
+// plantumlText returns the label as text for PlantUML. Line breaks become \n, backslashes are escaped,
+// double quotes become an entity and the tags of HTML labels are removed.
+func plantumlText(label interface{}) string {
+	s := fmt.Sprintf("%v", label)
+	switch label.(type) {
+	case HTML:
+		s = htmlText(s)
+	case Literal:
+		s = strings.Trim(s, `"`)
+		s = strings.Replace(s, `\"`, `"`, -1)
+	}
+	// Graphviz escaped line breaks
+	s = strings.NewReplacer(`\n`, "\n", `\l`, "\n", `\r`, "\n").Replace(s)
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	s = strings.Replace(s, `"`, "&#34;", -1)
+	return s
+}
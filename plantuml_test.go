@@ -0,0 +1,68 @@
+package dot
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPlantUML(t *testing.T) {
+	g := NewGraph(Directed)
+	g.Attr("rankdir", "LR")
+	g.Label("Services")
+	c := g.Subgraph("backend", ClusterOption{})
+	c.Attrs("fillcolor", "lightgrey", "style", "dashed")
+	api := c.Node("api").Attr("shape", "component")
+	db := c.Node("db").Attr("shape", "cylinder").Attr("fillcolor", "#e0e0e0").Attr("color", "blue")
+	user := g.Node("user").Attr("element", "actor")
+	web := g.Node("web").Attr("shape", "box").Label(`web "ui"\nfrontend`)
+	user.Edge(web, "uses").Attr("style", "bold")
+	web.Edge(api, "calls: rest").Attr("style", "dashed").Attr("color", "red")
+	api.Edge(db).Attr("style", "dotted").Attr("dir", "both").Attr("penwidth", 2)
+	api.Edge(web).Attr("dir", "none")
+	if got, want := PlantUML(g), `@startuml
+title Services
+left to right direction
+actor "user" as n4
+rectangle "web &#34;ui&#34;\nfrontend" as n5
+package "backend" as cluster_s1 #lightgrey;line.dashed {
+  component "api" as n2
+  database "db" as n3 #e0e0e0;line:blue
+}
+n2 -- n5
+n4 -[bold]-> n5 : uses
+n5 -[#red,dashed]-> n2 : calls: rest
+n2 <-[dotted,thickness=2]-> n3
+@enduml
+`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestPlantUMLUndirected(t *testing.T) {
+	g := NewGraph(Undirected, NodeIDOption{})
+	s := g.Subgraph("group")
+	a := s.Node("a b").Attr("label", HTML("<b>A</b><br/>&amp;"))
+	b := s.Node("b")
+	a.Edge(b)
+	buf := new(bytes.Buffer)
+	if _, err := WritePlantUML(buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), `@startuml
+rectangle "group" as s1 {
+  usecase "A\n&" as a_b_n2
+  usecase "b" as b
+}
+a_b_n2 -- b
+@enduml
+`; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	n, err := WritePlantUML(&failingWriter{limit: 10}, g)
+	if err == nil {
+		t.Error("expected error")
+	}
+	if got, want := n, int64(10); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
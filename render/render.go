@@ -0,0 +1,222 @@
+// Package render runs a local Graphviz executable to layout and render a dot.Graph.
+//
+//	out, err := render.Renderer{Engine: render.Neato}.Render(ctx, g, render.SVG)
+//
+// Copyright (c) Ernest Micklei. MIT License
+package render
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/emicklei/dot"
+)
+
+// Engine is a Graphviz layout engine, passed as -K<engine>.
+type Engine string
+
+const (
+	Dot   Engine = "dot"
+	Neato Engine = "neato"
+	Fdp   Engine = "fdp"
+	Sfdp  Engine = "sfdp"
+	Circo Engine = "circo"
+	Twopi Engine = "twopi"
+)
+
+// Format is a Graphviz output format, passed as -T<format>.
+type Format string
+
+const (
+	SVG   Format = "svg"
+	PNG   Format = "png"
+	PDF   Format = "pdf"
+	JSON  Format = "json"
+	Plain Format = "plain"
+	XDot  Format = "xdot"
+)
+
+// DefaultExecutable is the name of the Graphviz executable that is looked up in the PATH.
+const DefaultExecutable = "dot"
+
+// Renderer runs a Graphviz executable. The zero value uses the dot executable and the dot engine.
+type Renderer struct {
+	// Executable is the name or path of the Graphviz executable ; DefaultExecutable if empty.
+	Executable string
+	// Engine is the layout engine ; Dot if empty.
+	Engine Engine
+	// Args are extra command line arguments, e.g. -Gdpi=300
+	Args []string
+}
+
+// Result is the output of a successful run.
+type Result struct {
+	Output []byte
+	// Warnings are the messages that Graphviz reported on stderr.
+	Warnings []Message
+}
+
+// Render writes the graph to the Graphviz executable and returns its output in the requested format.
+// The context can be used to cancel the run or set a timeout ; the error then wraps the context error.
+// If Graphviz fails then the error is an *Error with the reported messages.
+func (r Renderer) Render(ctx context.Context, g *dot.Graph, format Format) (*Result, error) {
+	stdout := new(bytes.Buffer)
+	warnings, err := r.run(ctx, g, format, stdout)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Output: stdout.Bytes(), Warnings: warnings}, nil
+}
+
+// RenderTo is like Render but writes the output to w. It returns the warnings reported by Graphviz.
+func (r Renderer) RenderTo(ctx context.Context, w io.Writer, g *dot.Graph, format Format) ([]Message, error) {
+	return r.run(ctx, g, format, w)
+}
+
+// Command returns the command line arguments for the format, without the executable.
+func (r Renderer) Command(format Format) []string {
+	engine := r.Engine
+	if engine == "" {
+		engine = Dot
+	}
+	return append([]string{"-K" + string(engine), "-T" + string(format)}, r.Args...)
+}
+
+func (r Renderer) run(ctx context.Context, g *dot.Graph, format Format, stdout io.Writer) ([]Message, error) {
+	if format == "" {
+		return nil, fmt.Errorf("render: missing output format")
+	}
+	executable := r.Executable
+	if executable == "" {
+		executable = DefaultExecutable
+	}
+	stdin := new(bytes.Buffer)
+	if _, err := g.WriteTo(stdin); err != nil {
+		return nil, err
+	}
+	stderr := new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, executable, r.Command(format)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, fmt.Errorf("render: %s %w", executable, ctxErr)
+	}
+	messages := parseMessages(stderr.String())
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			// not started, e.g. executable not found
+			return nil, fmt.Errorf("render: %w", err)
+		}
+		return nil, &Error{ExitCode: exitErr.ExitCode(), Messages: messages, Stderr: stderr.String()}
+	}
+	return messages, nil
+}
+
+// Severity tells whether a message is a warning or an error.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Message is a warning or error reported by Graphviz on stderr.
+type Message struct {
+	Severity Severity
+	// Line is the line number in the DOT source, if reported ; zero otherwise.
+	Line int
+	Text string
+}
+
+// String returns the message as reported by Graphviz.
+func (m Message) String() string {
+	if m.Severity == SeverityWarning {
+		return "Warning: " + m.Text
+	}
+	return "Error: " + m.Text
+}
+
+// Error is returned when the Graphviz executable exits with a non-zero code.
+type Error struct {
+	ExitCode int
+	// Messages has all warnings and errors reported on stderr.
+	Messages []Message
+	// Stderr is the unparsed output on stderr.
+	Stderr string
+}
+
+// Error returns the first error message or the exit code if none was reported.
+func (e *Error) Error() string {
+	for _, each := range e.Messages {
+		if each.Severity == SeverityError {
+			return "render: " + each.String()
+		}
+	}
+	if len(e.Messages) > 0 {
+		return "render: " + e.Messages[0].String()
+	}
+	return fmt.Sprintf("render: graphviz exited with code %d", e.ExitCode)
+}
+
+// Errors returns the messages with SeverityError.
+func (e *Error) Errors() (list []Message) {
+	for _, each := range e.Messages {
+		if each.Severity == SeverityError {
+			list = append(list, each)
+		}
+	}
+	return
+}
+
+// parseMessages splits stderr output into messages. A message starts with "Warning:" or "Error:" ;
+// other lines are added to the text of the previous message or become an error message themselves.
+func parseMessages(stderr string) (list []Message) {
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var m Message
+		switch {
+		case strings.HasPrefix(line, "Warning:"):
+			m = Message{Severity: SeverityWarning, Text: strings.TrimSpace(strings.TrimPrefix(line, "Warning:"))}
+		case strings.HasPrefix(line, "Error:"):
+			m = Message{Severity: SeverityError, Text: strings.TrimSpace(strings.TrimPrefix(line, "Error:"))}
+		case len(list) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")):
+			last := &list[len(list)-1]
+			last.Text += "\n" + strings.TrimSpace(line)
+			if last.Line == 0 {
+				last.Line = lineNumber(line)
+			}
+			continue
+		default:
+			m = Message{Severity: SeverityError, Text: strings.TrimSpace(line)}
+		}
+		m.Line = lineNumber(m.Text)
+		list = append(list, m)
+	}
+	return
+}
+
+// lineNumber returns the number following "line" in a message like "syntax error in line 3 near 'x'".
+func lineNumber(text string) int {
+	i := strings.Index(text, "line ")
+	if i == -1 {
+		return 0
+	}
+	n := 0
+	for _, r := range text[i+len("line "):] {
+		if r < '0' || r > '9' {
+			break
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package render
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emicklei/dot"
+)
+
+// fakeDot is a shell script that behaves like dot, controlled by the FAKE_DOT environment variable.
+const fakeDot = `#!/bin/sh
+case "$FAKE_DOT" in
+warn)
+	echo "Warning: node a, port p unrecognized" >&2
+	echo "$@"
+	;;
+fail)
+	cat > /dev/null
+	echo "Error: <stdin>: syntax error in line 3 near '}'" >&2
+	echo "  context: digraph { a -> }" >&2
+	exit 1
+	;;
+sleep)
+	exec sleep 5
+	;;
+*)
+	echo "$@"
+	cat
+	;;
+esac
+`
+
+func installFakeDot(t *testing.T, mode string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dot"), []byte(fakeDot), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DOT", mode)
+}
+
+func testGraph() *dot.Graph {
+	g := dot.NewGraph(dot.Directed)
+	g.Node("a").Edge(g.Node("b"))
+	return g
+}
+
+func TestRender(t *testing.T) {
+	installFakeDot(t, "echo")
+	g := testGraph()
+	result, err := Renderer{Engine: Neato, Args: []string{"-Gdpi=300"}}.Render(context.Background(), g, SVG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(result.Output), "-Kneato -Tsvg -Gdpi=300\n"+g.String(); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(result.Warnings), 0; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestRenderToWithWarnings(t *testing.T) {
+	installFakeDot(t, "warn")
+	sb := new(strings.Builder)
+	warnings, err := Renderer{}.RenderTo(context.Background(), sb, testGraph(), Plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sb.String(), "-Kdot -Tplain\n"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(warnings), 1; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	if got, want := warnings[0], (Message{Severity: SeverityWarning, Text: "node a, port p unrecognized"}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	installFakeDot(t, "fail")
+	_, err := Renderer{}.Render(context.Background(), testGraph(), PNG)
+	var renderErr *Error
+	if !errors.As(err, &renderErr) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if got, want := renderErr.ExitCode, 1; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := len(renderErr.Errors()), 1; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	m := renderErr.Errors()[0]
+	if got, want := m.Line, 3; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := m.Text, "<stdin>: syntax error in line 3 near '}'\ncontext: digraph { a -> }"; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := err.Error(), "render: Error: "+m.Text; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
+
+func TestRenderTimeout(t *testing.T) {
+	installFakeDot(t, "sleep")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := Renderer{}.Render(ctx, testGraph(), SVG)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestRenderMissingExecutable(t *testing.T) {
+	_, err := Renderer{Executable: "no-such-graphviz-executable"}.Render(context.Background(), testGraph(), SVG)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var renderErr *Error
+	if errors.As(err, &renderErr) {
+		t.Errorf("unexpected *Error %v", err)
+	}
+}
+
+func TestParseMessages(t *testing.T) {
+	list := parseMessages("Warning: a\r\n\nsomething odd\nError: syntax error in line 12 near 'x'\n")
+	if got, want := len(list), 3; got != want {
+		t.Fatalf("got [%v] want [%v]", got, want)
+	}
+	if got, want := list[1], (Message{Severity: SeverityError, Text: "something odd"}); got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+	if got, want := list[2].Line, 12; got != want {
+		t.Errorf("got [%v] want [%v]", got, want)
+	}
+}
@@ -0,0 +1,317 @@
+package dot
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF    tokenKind = iota
+	tokenID               // unquoted identifier or numeral
+	tokenQuoted           // double-quoted string, without the quotes
+	tokenHTML             // <...> string, without the outer angle brackets
+	tokenLeftBrace
+	tokenRightBrace
+	tokenLeftBracket
+	tokenRightBracket
+	tokenSemicolon
+	tokenComma
+	tokenEqual
+	tokenColon
+	tokenPlus
+	tokenDirectedEdge   // ->
+	tokenUndirectedEdge // --
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	line   int
+	column int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenQuoted:
+		return fmt.Sprintf("%q", t.text)
+	case tokenHTML:
+		return "<" + t.text + ">"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// isKeyword returns true if the token is an unquoted (case-insensitive) DOT keyword.
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenID && strings.EqualFold(t.text, keyword)
+}
+
+// scanner splits DOT source into tokens and skips whitespace and comments.
+type scanner struct {
+	src    string
+	offset int
+	line   int
+	column int
+	// atLineStart is true if only whitespace was read on the current line.
+	atLineStart bool
+}
+
+func newScanner(src string) *scanner {
+	return &scanner{src: src, line: 1, column: 1, atLineStart: true}
+}
+
+func (s *scanner) peekRune() rune {
+	if s.offset >= len(s.src) {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(s.src[s.offset:])
+	return r
+}
+
+func (s *scanner) peekRuneAt(ahead int) rune {
+	offset := s.offset
+	for i := 0; i < ahead; i++ {
+		if offset >= len(s.src) {
+			return -1
+		}
+		_, size := utf8.DecodeRuneInString(s.src[offset:])
+		offset += size
+	}
+	if offset >= len(s.src) {
+		return -1
+	}
+	r, _ := utf8.DecodeRuneInString(s.src[offset:])
+	return r
+}
+
+func (s *scanner) nextRune() rune {
+	if s.offset >= len(s.src) {
+		return -1
+	}
+	r, size := utf8.DecodeRuneInString(s.src[s.offset:])
+	s.offset += size
+	if r == '\n' {
+		s.line++
+		s.column = 1
+		s.atLineStart = true
+	} else {
+		s.column++
+		if r != ' ' && r != '\t' && r != '\r' {
+			s.atLineStart = false
+		}
+	}
+	return r
+}
+
+func (s *scanner) errorf(line, column int, format string, args ...interface{}) error {
+	return &SyntaxError{Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipWhitespaceAndComments skips spaces, C and C++ style comments and lines starting with '#'.
+func (s *scanner) skipWhitespaceAndComments() error {
+	for {
+		r := s.peekRune()
+		switch {
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n' || r == '\f' || r == '\v':
+			s.nextRune()
+		case r == '#' && s.atLineStart:
+			for r := s.peekRune(); r != -1 && r != '\n'; r = s.peekRune() {
+				s.nextRune()
+			}
+		case r == '/' && s.peekRuneAt(1) == '/':
+			for r := s.peekRune(); r != -1 && r != '\n'; r = s.peekRune() {
+				s.nextRune()
+			}
+		case r == '/' && s.peekRuneAt(1) == '*':
+			line, column := s.line, s.column
+			s.nextRune()
+			s.nextRune()
+			for {
+				r := s.nextRune()
+				if r == -1 {
+					return s.errorf(line, column, "unterminated comment")
+				}
+				if r == '*' && s.peekRune() == '/' {
+					s.nextRune()
+					break
+				}
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func isIDStart(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r >= 0x80
+}
+
+func isIDPart(r rune) bool {
+	return isIDStart(r) || isDigit(r)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// next returns the next token or a SyntaxError.
+func (s *scanner) next() (token, error) {
+	if err := s.skipWhitespaceAndComments(); err != nil {
+		return token{}, err
+	}
+	line, column := s.line, s.column
+	tok := token{line: line, column: column}
+	r := s.peekRune()
+	switch {
+	case r == -1:
+		tok.kind = tokenEOF
+		return tok, nil
+	case isIDStart(r):
+		start := s.offset
+		for isIDPart(s.peekRune()) {
+			s.nextRune()
+		}
+		tok.kind = tokenID
+		tok.text = s.src[start:s.offset]
+		return tok, nil
+	case isDigit(r) || r == '.' || (r == '-' && (isDigit(s.peekRuneAt(1)) || s.peekRuneAt(1) == '.')):
+		return s.numeral(tok)
+	case r == '"':
+		return s.quoted(tok)
+	case r == '<':
+		return s.html(tok)
+	case r == '-':
+		s.nextRune()
+		switch s.peekRune() {
+		case '>':
+			s.nextRune()
+			tok.kind, tok.text = tokenDirectedEdge, "->"
+			return tok, nil
+		case '-':
+			s.nextRune()
+			tok.kind, tok.text = tokenUndirectedEdge, "--"
+			return tok, nil
+		}
+		return tok, s.errorf(line, column, "unexpected character '-'")
+	}
+	s.nextRune()
+	tok.text = string(r)
+	switch r {
+	case '{':
+		tok.kind = tokenLeftBrace
+	case '}':
+		tok.kind = tokenRightBrace
+	case '[':
+		tok.kind = tokenLeftBracket
+	case ']':
+		tok.kind = tokenRightBracket
+	case ';':
+		tok.kind = tokenSemicolon
+	case ',':
+		tok.kind = tokenComma
+	case '=':
+		tok.kind = tokenEqual
+	case ':':
+		tok.kind = tokenColon
+	case '+':
+		tok.kind = tokenPlus
+	default:
+		return tok, s.errorf(line, column, "unexpected character %q", r)
+	}
+	return tok, nil
+}
+
+// numeral scans [-]?(.[0-9]+ | [0-9]+(.[0-9]*)?)
+func (s *scanner) numeral(tok token) (token, error) {
+	start := s.offset
+	if s.peekRune() == '-' {
+		s.nextRune()
+	}
+	digits := 0
+	for isDigit(s.peekRune()) {
+		s.nextRune()
+		digits++
+	}
+	if s.peekRune() == '.' {
+		s.nextRune()
+		for isDigit(s.peekRune()) {
+			s.nextRune()
+			digits++
+		}
+	}
+	if digits == 0 {
+		return tok, s.errorf(tok.line, tok.column, "invalid numeral %q", s.src[start:s.offset])
+	}
+	if isIDStart(s.peekRune()) {
+		return tok, s.errorf(s.line, s.column, "identifier cannot start with a digit")
+	}
+	tok.kind = tokenID
+	tok.text = s.src[start:s.offset]
+	return tok, nil
+}
+
+// quoted scans a double-quoted string. Only the escaped quote is unescaped and
+// escaped newlines (line continuations) are removed ; all other escape sequences
+// are kept because they have a meaning to Graphviz (e.g. \n, \l, \N).
+func (s *scanner) quoted(tok token) (token, error) {
+	s.nextRune() // opening quote
+	b := new(strings.Builder)
+	for {
+		r := s.nextRune()
+		switch r {
+		case -1:
+			return tok, s.errorf(tok.line, tok.column, "unterminated string")
+		case '"':
+			tok.kind = tokenQuoted
+			tok.text = b.String()
+			return tok, nil
+		case '\\':
+			switch s.peekRune() {
+			case '"':
+				s.nextRune()
+				b.WriteRune('"')
+			case '\n':
+				s.nextRune()
+			case '\r':
+				s.nextRune()
+				if s.peekRune() == '\n' {
+					s.nextRune()
+				}
+			case '\\':
+				s.nextRune()
+				b.WriteString(`\\`)
+			default:
+				b.WriteRune('\\')
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+}
+
+// html scans a <...> string with balanced angle brackets.
+func (s *scanner) html(tok token) (token, error) {
+	s.nextRune() // opening <
+	start := s.offset
+	depth := 1
+	for {
+		r := s.nextRune()
+		switch r {
+		case -1:
+			return tok, s.errorf(tok.line, tok.column, "unterminated HTML string")
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth == 0 {
+				tok.kind = tokenHTML
+				tok.text = s.src[start : s.offset-1]
+				return tok, nil
+			}
+		}
+	}
+}
@@ -2,13 +2,43 @@ package dot
 
 import "sort"
 
-func (g *Graph) sortedNodesKeys() (keys []string) {
+// insertionOrder returns true if the root graph was created with the InsertionOrder option.
+func (g *Graph) insertionOrder() bool {
+	return g.Root().order == InsertionOrder.Name
+}
+
+// orderedNodesKeys returns the keys of the nodes sorted by id or by creation.
+func (g *Graph) orderedNodesKeys() (keys []string) {
 	for each := range g.nodes {
 		keys = append(keys, each)
 	}
+	if g.insertionOrder() {
+		sort.Slice(keys, func(i, j int) bool {
+			return g.nodes[keys[i]].seq < g.nodes[keys[j]].seq
+		})
+		return
+	}
 	sort.StringSlice(keys).Sort()
 	return
 }
+
+// orderedEdges returns all edges of this graph, sorted by the id of their from node or by creation.
+func (g *Graph) orderedEdges() (edges []Edge) {
+	if g.insertionOrder() {
+		for _, each := range g.edgesFrom {
+			edges = append(edges, each...)
+		}
+		sort.Slice(edges, func(i, j int) bool {
+			return edges[i].seq < edges[j].seq
+		})
+		return
+	}
+	for _, each := range g.sortedEdgesFromKeys() {
+		edges = append(edges, g.edgesFrom[each]...)
+	}
+	return
+}
+
 func (g *Graph) sortedEdgesFromKeys() (keys []string) {
 	for each := range g.edgesFrom {
 		keys = append(keys, each)
@@ -16,10 +46,30 @@ func (g *Graph) sortedEdgesFromKeys() (keys []string) {
 	sort.StringSlice(keys).Sort()
 	return
 }
-func (g *Graph) sortedSubgraphsKeys() (keys []string) {
+
+// orderedSubgraphsKeys returns the keys of the subgraphs sorted by key or by creation.
+func (g *Graph) orderedSubgraphsKeys() (keys []string) {
 	for each := range g.subgraphs {
 		keys = append(keys, each)
 	}
+	if g.insertionOrder() {
+		sort.Slice(keys, func(i, j int) bool {
+			return g.subgraphs[keys[i]].index < g.subgraphs[keys[j]].index
+		})
+		return
+	}
 	sort.StringSlice(keys).Sort()
 	return
 }
+
+// orderedRankGroups returns the names of the rank groups sorted by name or by creation.
+func (g *Graph) orderedRankGroups() (groups []string) {
+	if g.insertionOrder() {
+		return append(groups, g.rankOrder...)
+	}
+	for each := range g.sameRank {
+		groups = append(groups, each)
+	}
+	sort.StringSlice(groups).Sort()
+	return
+}